@@ -0,0 +1,124 @@
+package zkteco
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrActorClosed is returned for commands submitted after Actor.Close.
+var ErrActorClosed = errors.New("actor closed")
+
+// Actor owns a ZKTeco client in a single goroutine and runs queued commands
+// one at a time, in submission order. It is safe for concurrent use.
+type Actor struct {
+	zk    *ZKTeco
+	queue chan actorJob
+	done  chan struct{}
+
+	mu       sync.RWMutex
+	closed   bool
+	closeErr error
+}
+
+type actorJob struct {
+	fn     func(*ZKTeco) (interface{}, error)
+	future *Future
+}
+
+// Future holds the result of a command queued on an Actor.
+type Future struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// Done returns a channel that is closed when the command has finished.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the command has finished and returns its result.
+func (f *Future) Wait() (interface{}, error) {
+	<-f.done
+	return f.value, f.err
+}
+
+// Err blocks until the command has finished and returns its error.
+func (f *Future) Err() error {
+	<-f.done
+	return f.err
+}
+
+func (f *Future) resolve(value interface{}, err error) {
+	f.value = value
+	f.err = err
+	close(f.done)
+}
+
+// NewActor starts an Actor for zk. queueSize bounds the number of pending
+// commands; Do blocks while the queue is full. The client should already be
+// connected, and must not be used directly while the Actor is running.
+func NewActor(zk *ZKTeco, queueSize int) *Actor {
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	a := &Actor{
+		zk:    zk,
+		queue: make(chan actorJob, queueSize),
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *Actor) run() {
+	defer close(a.done)
+	for job := range a.queue {
+		a.runJob(job)
+	}
+	a.closeErr = a.zk.Disconnect()
+}
+
+// runJob runs one command. Client methods return their own panics as
+// errors (see ErrInternal); a panic of the job's code still crashes the
+// program, but a device it disabled is enabled again first.
+func (a *Actor) runJob(job actorJob) {
+	defer a.zk.reenableOnPanic()
+	value, err := job.fn(a.zk)
+	job.future.resolve(value, err)
+}
+
+// Do queues fn to run on the actor goroutine and returns its Future.
+func (a *Actor) Do(fn func(*ZKTeco) (interface{}, error)) *Future {
+	f := &Future{done: make(chan struct{})}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		f.resolve(nil, ErrActorClosed)
+		return f
+	}
+	a.queue <- actorJob{fn: fn, future: f}
+	return f
+}
+
+// Exec queues a command that only returns an error.
+func (a *Actor) Exec(fn func(*ZKTeco) error) *Future {
+	return a.Do(func(zk *ZKTeco) (interface{}, error) {
+		return nil, fn(zk)
+	})
+}
+
+// Close stops accepting commands, waits for the queued ones to finish and
+// disconnects the client. It returns the Disconnect error.
+func (a *Actor) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	<-a.done
+	return a.closeErr
+}
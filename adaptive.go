@@ -0,0 +1,224 @@
+package zkteco
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// AdaptiveTimeout bounds the reply deadlines of WithAdaptiveTimeout.
+type AdaptiveTimeout struct {
+	// Min is the shortest reply deadline. Default is 500ms.
+	Min time.Duration
+	// Max is the longest reply deadline, and the inactivity timeout of the
+	// connection, so it also bounds each chunk of a large transfer. Default
+	// is two minutes.
+	Max time.Duration
+}
+
+// WithAdaptiveTimeout replaces the static WithTimeout deadline of command
+// replies with one derived from the latencies observed on the connection,
+// per command code: the smoothed round-trip time plus four times its
+// variation, as TCP computes retransmission timeouts, bounded by cfg. Every
+// timed-out reply doubles the deadline of its command until a reply arrives
+// in time, so a link that turns slow, such as a TCPMUX or FRP tunnel, is
+// given longer instead of failing each command. Commands not seen yet use
+// the WithTimeout value as their first deadline. Connect dials with
+// cfg.Max.
+func WithAdaptiveTimeout(cfg AdaptiveTimeout) Option {
+	return func(z *ZKTeco) {
+		if cfg.Min <= 0 {
+			cfg.Min = 500 * time.Millisecond
+		}
+		if cfg.Max <= 0 {
+			cfg.Max = 2 * time.Minute
+		}
+		if cfg.Max < cfg.Min {
+			cfg.Max = cfg.Min
+		}
+		z.adaptive = &latencyTracker{cfg: cfg, stats: make(map[uint16]*latencyStats)}
+	}
+}
+
+// CommandLatency is the latency estimate of one command code.
+type CommandLatency struct {
+	Command  uint16
+	Samples  int           // replies received in time
+	Timeouts int           // replies that timed out
+	SRTT     time.Duration // smoothed round-trip time
+	RTTVar   time.Duration // round-trip time variation
+	Timeout  time.Duration // deadline of the next reply
+}
+
+// CommandLatencies returns the latency estimates WithAdaptiveTimeout keeps,
+// one per command code sent, or nil when adaptive timeouts are off.
+func (z *ZKTeco) CommandLatencies() []CommandLatency {
+	if z.adaptive == nil {
+		return nil
+	}
+	return z.adaptive.snapshot(z.timeout)
+}
+
+// latencyTracker keeps the per-command latency estimates.
+type latencyTracker struct {
+	cfg AdaptiveTimeout
+
+	mu    sync.Mutex
+	stats map[uint16]*latencyStats
+	late  map[uint16]bool // reply IDs of timed-out replies that may still arrive
+}
+
+type latencyStats struct {
+	samples  int
+	timeouts int
+	srtt     time.Duration
+	rttvar   time.Duration
+	backoff  int // consecutive timeouts
+}
+
+// timeout returns the deadline for the reply to cmd; initial is used until
+// cmd has been measured.
+func (t *latencyTracker) timeout(cmd uint16, initial time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timeoutLocked(t.stats[cmd], initial)
+}
+
+func (t *latencyTracker) timeoutLocked(s *latencyStats, initial time.Duration) time.Duration {
+	d := initial
+	if s != nil && s.samples > 0 {
+		d = s.srtt + 4*s.rttvar
+	}
+	if s != nil {
+		for i := 0; i < s.backoff && d < t.cfg.Max; i++ {
+			d *= 2
+		}
+	}
+	return min(max(d, t.cfg.Min), t.cfg.Max)
+}
+
+// observe records a reply to cmd received after rtt.
+func (t *latencyTracker) observe(cmd uint16, rtt time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.entry(cmd)
+	if s.samples == 0 {
+		s.srtt = rtt
+		s.rttvar = rtt / 2
+	} else {
+		s.rttvar = (3*s.rttvar + absDuration(s.srtt-rtt)) / 4
+		s.srtt = (7*s.srtt + rtt) / 8
+	}
+	s.samples++
+	s.backoff = 0
+}
+
+// timedOut records a reply to cmd, with reply ID replyID, that did not
+// arrive in time.
+func (t *latencyTracker) timedOut(cmd, replyID uint16) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := t.entry(cmd)
+	s.timeouts++
+	s.backoff++
+	if t.late == nil {
+		t.late = make(map[uint16]bool)
+	}
+	t.late[replyID] = true
+}
+
+// isLate reports whether replyID is that of a timed-out reply, and forgets
+// it.
+func (t *latencyTracker) isLate(replyID uint16) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.late[replyID] {
+		return false
+	}
+	delete(t.late, replyID)
+	return true
+}
+
+// reset forgets the late replies of the previous connection.
+func (t *latencyTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.late = nil
+}
+
+func (t *latencyTracker) entry(cmd uint16) *latencyStats {
+	s := t.stats[cmd]
+	if s == nil {
+		s = &latencyStats{}
+		t.stats[cmd] = s
+	}
+	return s
+}
+
+func (t *latencyTracker) snapshot(initial time.Duration) []CommandLatency {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]CommandLatency, 0, len(t.stats))
+	for cmd, s := range t.stats {
+		out = append(out, CommandLatency{
+			Command:  cmd,
+			Samples:  s.samples,
+			Timeouts: s.timeouts,
+			SRTT:     s.srtt,
+			RTTVar:   s.rttvar,
+			Timeout:  t.timeoutLocked(s, initial),
+		})
+	}
+	return out
+}
+
+// recvReply receives the reply to cmd, sent at sent (on the client's clock)
+// with reply ID replyID, giving up when ctx is done. Over UDP, stray packets are skipped (see
+// strayPacket). With adaptive timeouts the read deadline comes from the
+// latency estimate of cmd, which the reply or its absence then updates. A tight deadline can expire just
+// before the reply arrives, so replies to requests that timed out earlier
+// are skipped when they show up late; the request after a timeout moves on
+// to the next reply ID so the two cannot be confused.
+func (z *ZKTeco) recvReply(ctx context.Context, cmd, replyID uint16, sent time.Time) ([]byte, error) {
+	if z.transport == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	if z.adaptive == nil {
+		resp, err := z.recvReplyTo(ctx, replyID)
+		if isTimeout(err) {
+			// The reply may still come: see below
+			z.replyID = replyID
+			z.lastData = nil
+		}
+		return resp, err
+	}
+	if err := z.armRead(ctx, time.Now().Add(z.adaptive.timeout(cmd, z.timeout))); err != nil {
+		return nil, err
+	}
+	for {
+		resp, err := z.transport.Recv()
+		switch {
+		case err == nil && len(resp) >= 8 && z.adaptive.isLate(binary.LittleEndian.Uint16(resp[6:8])):
+			continue
+		case err == nil && z.strayPacket(replyID, resp):
+			continue
+		case err == nil:
+			z.adaptive.observe(cmd, z.clock.Now().Sub(sent))
+		case isTimeout(err):
+			z.adaptive.timedOut(cmd, replyID)
+			z.replyID = replyID
+			z.lastData = nil
+		}
+		return resp, err
+	}
+}
+
+// isTimeout reports whether err is a read that timed out.
+func isTimeout(err error) bool {
+	var ne interface{ Timeout() bool }
+	return errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout())
+}
@@ -1,9 +1,14 @@
 package zkteco
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"time"
@@ -16,58 +21,433 @@ type Attendance struct {
 	State      int       `json:"state"`
 	RecordTime time.Time `json:"record_time"`
 	Type       int       `json:"type"`
+	WorkCode   int       `json:"work_code,omitempty"`
+
+	// Reader is the index of the reader that took the punch on controllers
+	// with several readers (and doors), 0 for the terminal's own reader.
+	Reader int `json:"reader,omitempty"`
+	// Direction is the direction of Reader set with WithReaderDirections,
+	// or empty when it is not known.
+	Direction Direction `json:"direction,omitempty"`
+	// TimeImplausible is set when RecordTime is outside the window set
+	// with WithRecordTimeWindow, e.g. a year-2000 punch of a device whose
+	// clock battery died.
+	TimeImplausible bool `json:"time_implausible,omitempty"`
+
+	DeviceSerial string `json:"device_serial,omitempty"` // see WithRecordSerial
+	Raw          []byte `json:"raw,omitempty"`           // record bytes; see WithRawRecords
+}
+
+// Direction is the side of a door a reader is mounted on.
+type Direction string
+
+// Directions of Attendance.
+const (
+	DirectionIn  Direction = "in"
+	DirectionOut Direction = "out"
+)
+
+// WithReaderDirections maps reader indexes (Attendance.Reader) to the
+// direction of the punches they take, e.g. {0: DirectionIn, 1: DirectionOut}
+// for a terminal with an exit reader wired to it, so attendance records carry
+// their direction instead of it being guessed from the punch state. Records
+// of readers not in the map have no Direction. Default is none.
+func WithReaderDirections(directions map[int]Direction) Option {
+	return func(z *ZKTeco) {
+		z.readerDirections = directions
+	}
+}
+
+// RecordTimeWindow is the range of plausible attendance record times for
+// WithRecordTimeWindow.
+type RecordTimeWindow struct {
+	NotBefore time.Time     // earliest plausible time; zero means no limit
+	MaxAhead  time.Duration // how far past the local clock a record may be; 0 means no limit
+	Drop      bool          // drop records outside the window instead of flagging them
+}
+
+// contains reports whether t is within the window, at local time now.
+func (w *RecordTimeWindow) contains(t, now time.Time) bool {
+	if !w.NotBefore.IsZero() && t.Before(w.NotBefore) {
+		return false
+	}
+	return w.MaxAhead <= 0 || !t.After(now.Add(w.MaxAhead))
+}
+
+// WithRecordTimeWindow checks downloaded attendance records against a
+// window of plausible times, so punches from a device with a dead clock
+// battery (typically dated 2000) do not pollute downstream systems. Records
+// outside the window are counted in the ParseReport (Implausible) and
+// flagged with TimeImplausible or, with Drop, skipped and reported like
+// unparseable records. Default is no check.
+func WithRecordTimeWindow(w RecordTimeWindow) Option {
+	return func(z *ZKTeco) {
+		z.recordTimeWindow = &w
+	}
 }
 
 // GetAttendances retrieves all attendance records from the device.
 func (z *ZKTeco) GetAttendances() ([]Attendance, error) {
-	allData, err := z.commandData(CMD_ATT_LOG_RRQ, nil)
+	return z.GetAttendancesContext(context.Background())
+}
+
+// GetAttendancesContext is like GetAttendances but aborts the transfer when
+// ctx is canceled, leaving the connection usable.
+func (z *ZKTeco) GetAttendancesContext(ctx context.Context) (_ []Attendance, err error) {
+	defer z.recoverInternal("getAttendancesContext", &err)
+	records, _, err := z.GetAttendancesWithReport(ctx)
+	return records, err
+}
+
+// GetAttendancesWithReport is like GetAttendancesContext but also returns a
+// ParseReport describing the records that could not be parsed and were
+// skipped, so data-quality issues are visible.
+func (z *ZKTeco) GetAttendancesWithReport(ctx context.Context) (_ []Attendance, _ *ParseReport, err error) {
+	defer z.recoverInternal("getAttendancesWithReport", &err)
+	var records []Attendance
+	report, err := z.eachAttendance(ctx, func(att Attendance) error {
+		records = append(records, att)
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return records, report, nil
+}
+
+// EachAttendance downloads the attendance log and calls fn for each record as
+// soon as it is decoded, without buffering the whole transfer. If fn returns
+// an error the transfer is aborted, leaving the connection usable, and the
+// error is returned.
+func (z *ZKTeco) EachAttendance(ctx context.Context, fn func(Attendance) error) (err error) {
+	defer z.recoverInternal("eachAttendance", &err)
+	_, err = z.eachAttendance(ctx, fn, nil)
+	return err
+}
+
+// RecordSink receives attendance records as the download decodes them, e.g.
+// a batch inserter into the integrator's own tables. The download waits
+// while OnAttendance runs, so a slow sink applies backpressure; an error
+// aborts it. A sink that also has a Flush() error method is flushed after
+// the last record.
+type RecordSink interface {
+	OnAttendance(Attendance) error
+}
+
+// StreamAttendances downloads the attendance log into sink, without holding
+// the records in memory. See EachAttendance.
+func (z *ZKTeco) StreamAttendances(ctx context.Context, sink RecordSink) (err error) {
+	defer z.recoverInternal("streamAttendances", &err)
+	if err := z.EachAttendance(ctx, sink.OnAttendance); err != nil {
+		return err
+	}
+	if f, ok := sink.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("getAttendances: flush: %w", err)
+		}
+	}
+	return nil
+}
+
+// TransferProgress describes how far a download has got.
+type TransferProgress struct {
+	Bytes        int           // bytes received
+	TotalBytes   int           // bytes announced by the device
+	Records      int           // records decoded
+	TotalRecords int           // records expected from TotalBytes and the record size
+	Elapsed      time.Duration // since the download started
+	Remaining    time.Duration // estimated from the rate so far; 0 until known
+}
+
+// GetAttendancesProgress is like GetAttendancesContext but calls onProgress
+// after each chunk of the transfer, for progress bars and ETAs in
+// operator-facing tools.
+func (z *ZKTeco) GetAttendancesProgress(ctx context.Context, onProgress func(TransferProgress)) (_ []Attendance, err error) {
+	defer z.recoverInternal("getAttendancesProgress", &err)
+	var records []Attendance
+	_, err = z.eachAttendance(ctx, func(att Attendance) error {
+		records = append(records, att)
+		return nil
+	}, onProgress)
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// eachAttendance downloads the attendance log, calling fn for each record
+// and onProgress, if set, after each chunk. It returns the parse report.
+func (z *ZKTeco) eachAttendance(ctx context.Context, fn func(Attendance) error, onProgress func(TransferProgress)) (*ParseReport, error) {
+	return z.eachAttendanceSlots(ctx, 0, 0, fn, onProgress)
+}
+
+// eachAttendanceSlots is eachAttendance for the table slots start to end
+// (excluded; 0 for the end of the table): earlier slots are not decoded,
+// and the transfer is aborted with errPageFull once end is reached.
+func (z *ZKTeco) eachAttendanceSlots(ctx context.Context, start, end int, fn func(Attendance) error, onProgress func(TransferProgress)) (*ParseReport, error) {
+	var progress TransferProgress
+	dec := newAttendanceDecoder(z.Profile(), func(att Attendance) error {
+		progress.Records++
+		att.DeviceSerial = z.serial
+		att.Direction = z.readerDirections[att.Reader]
+		return fn(att)
+	})
+	dec.window = z.recordTimeWindow
+	dec.now = z.clock.Now()
+	dec.raw = z.rawRecords
+	dec.start, dec.end = start, end
+
+	write := dec.write
+	if onProgress != nil {
+		start := z.clock.Now()
+		first := true
+		write = func(chunk []byte) error {
+			n := len(chunk)
+			if first {
+				first = false
+				// 8-byte header, then the 4-byte table size
+				if n >= 12 {
+					progress.TotalBytes = 4 + int(binary.LittleEndian.Uint32(chunk[8:12]))
+				}
+				n = max(n-8, 0)
+			}
+			if err := dec.write(chunk); err != nil {
+				return err
+			}
+			// The record size is known once the layout is detected
+			progress.TotalRecords = (progress.TotalBytes - 4) / dec.recordSize
+			progress.Bytes += n
+			progress.Elapsed = z.clock.Now().Sub(start)
+			if progress.Bytes > 0 && progress.TotalBytes > progress.Bytes {
+				rate := float64(progress.Elapsed) / float64(progress.Bytes)
+				progress.Remaining = time.Duration(rate * float64(progress.TotalBytes-progress.Bytes))
+			} else {
+				progress.Remaining = 0
+			}
+			onProgress(progress)
+			return nil
+		}
+	}
+
+	err := z.whileDisabled(func() error {
+		if err := z.commandDataChunks(ctx, CMD_ATT_LOG_RRQ, nil, write); err != nil {
+			return err
+		}
+		return dec.close()
+	})
 	if err != nil {
 		return nil, fmt.Errorf("getAttendances: %w", err)
 	}
+	return &dec.report, nil
+}
+
+// WriteAttendancesNDJSON streams the attendance log to w as newline-delimited
+// JSON, one AttendanceDocument per line, writing each record as soon as it
+// is decoded. It suits piping into log shippers without holding the whole
+// log in memory.
+func (z *ZKTeco) WriteAttendancesNDJSON(w io.Writer) error {
+	return z.WriteAttendancesNDJSONContext(context.Background(), w)
+}
+
+// WriteAttendancesNDJSONContext is like WriteAttendancesNDJSON but aborts the
+// transfer when ctx is canceled or a write to w fails, leaving the connection
+// usable. Records already written stay written.
+func (z *ZKTeco) WriteAttendancesNDJSONContext(ctx context.Context, w io.Writer) (err error) {
+	defer z.recoverInternal("writeAttendancesNDJSONContext", &err)
+	enc := json.NewEncoder(w)
+	return z.EachAttendance(ctx, func(att Attendance) error {
+		return enc.Encode(att.Document())
+	})
+}
+
+// attendanceDecoder decodes attendance records incrementally from transfer
+// chunks, carrying partial records over to the next chunk.
+type attendanceDecoder struct {
+	skip       int
+	recordSize int
+	parse      func([]byte) (*Attendance, error)
+	carry      []byte
+	fn         func(Attendance) error
+	detect     bool   // tell 40-byte from 44-byte records; see detectLayout
+	head       []byte // start of the transfer held for detection
+
+	offset int // table offset of the next record
+	report ParseReport
+
+	// Slot range decoded; see eachAttendanceSlots
+	slot, start, end int
+
+	window *RecordTimeWindow // see WithRecordTimeWindow
+	now    time.Time         // local time the window is checked at
+	raw    bool              // keep a copy of each record; see WithRawRecords
+}
 
-	if len(allData) <= 8 {
-		return nil, nil
+func newAttendanceDecoder(p Profile, fn func(Attendance) error) *attendanceDecoder {
+	switch p.AttendanceRecordSize {
+	case 16:
+		// Skip 8-byte header and 4-byte size prefix
+		return &attendanceDecoder{skip: 12, recordSize: 16, parse: parseAttendanceRecord16, fn: fn}
+	case 8:
+		return &attendanceDecoder{skip: 12, recordSize: 8, parse: parseAttendanceRecord8, fn: fn}
+	case 44:
+		return &attendanceDecoder{skip: 12, recordSize: 44, parse: parseAttendanceRecord44, fn: fn}
+	default:
+		// Skip first 10 bytes (8 header + 2 extra) — matches PHP behavior
+		return &attendanceDecoder{skip: 10, recordSize: 40, parse: parseAttendanceRecord, fn: fn, detect: true}
 	}
+}
+
+// detectHead is the start of a transfer detection looks at: the 8-byte
+// header, the 4-byte table size and the first ten 44-byte records.
+const detectHead = 12 + 10*44
 
-	// Skip first 10 bytes (8 header + 2 extra) — matches PHP behavior
-	data := allData
-	if len(data) > 10 {
-		data = data[10:]
+// write consumes the next chunk of the transfer.
+func (d *attendanceDecoder) write(chunk []byte) error {
+	if d.detect {
+		// Hold the start of the transfer until the first records, or the
+		// whole table, are there to detect the layout from
+		d.head = append(d.head, chunk...)
+		if len(d.head) < 12 || len(d.head) < min(detectHead, 12+int(binary.LittleEndian.Uint32(d.head[8:12]))) {
+			return nil
+		}
+		chunk = d.detectLayout()
 	}
+	return d.decode(chunk)
+}
 
-	// Each attendance record is 40 bytes
-	recordSize := 40
-	var records []Attendance
+// close ends the transfer, decoding a start too short for the layout to
+// have been detected, e.g. of a truncated table.
+func (d *attendanceDecoder) close() error {
+	if !d.detect {
+		return nil
+	}
+	return d.decode(d.detectLayout())
+}
 
-	for i := 0; i+recordSize <= len(data); i += recordSize {
-		rec := data[i : i+recordSize]
-		att := parseAttendanceRecord(rec)
-		if att != nil {
-			records = append(records, *att)
+// detectLayout tells 40-byte from 44-byte records from the start of the
+// transfer held in d.head, and returns it for decoding.
+func (d *attendanceDecoder) detectLayout() []byte {
+	d.detect = false
+	head := d.head
+	d.head = nil
+	// 8-byte header, then the 4-byte table size
+	if len(head) >= 12 {
+		size := int(binary.LittleEndian.Uint32(head[8:12]))
+		if detectAttendanceRecordSize(size, head[12:]) == 44 {
+			d.skip, d.recordSize, d.parse = 12, 44, parseAttendanceRecord44
 		}
 	}
+	return head
+}
 
-	return records, nil
+// decode consumes a chunk once the layout is known.
+func (d *attendanceDecoder) decode(chunk []byte) error {
+	if d.skip > 0 {
+		if len(chunk) <= d.skip {
+			d.skip -= len(chunk)
+			d.offset += len(chunk)
+			return nil
+		}
+		chunk = chunk[d.skip:]
+		d.offset += d.skip
+		d.skip = 0
+	}
+
+	if len(d.carry) > 0 {
+		need := d.recordSize - len(d.carry)
+		if len(chunk) < need {
+			d.carry = append(d.carry, chunk...)
+			return nil
+		}
+		d.carry = append(d.carry, chunk[:need]...)
+		chunk = chunk[need:]
+		if err := d.emit(d.carry); err != nil {
+			return err
+		}
+		d.carry = d.carry[:0]
+	}
+
+	for len(chunk) >= d.recordSize {
+		if err := d.emit(chunk[:d.recordSize]); err != nil {
+			return err
+		}
+		chunk = chunk[d.recordSize:]
+	}
+
+	d.carry = append(d.carry, chunk...)
+	return nil
+}
+
+func (d *attendanceDecoder) emit(rec []byte) error {
+	offset := d.offset
+	d.offset += len(rec)
+	slot := d.slot
+	d.slot++
+	if d.end > 0 && slot >= d.end {
+		return errPageFull
+	}
+	if slot < d.start {
+		return nil
+	}
+
+	att, err := d.parse(rec)
+	if errors.Is(err, errEmptyRecord) {
+		return nil
+	}
+	if err != nil {
+		d.report.add(offset, rec, err)
+		return nil
+	}
+	if d.window != nil && !d.window.contains(att.RecordTime, d.now) {
+		d.report.Implausible++
+		if d.window.Drop {
+			d.report.add(offset, rec, fmt.Errorf("record time %s outside plausible window", att.RecordTime.Format(time.DateTime)))
+			return nil
+		}
+		att.TimeImplausible = true
+	}
+	if d.raw {
+		att.Raw = bytes.Clone(rec)
+	}
+	d.report.Parsed++
+	return d.fn(*att)
+}
+
+// ParseAttendances parses a raw attendance log as returned by the device
+// (including the 8-byte packet header) using the record layout of profile p.
+// Records that cannot be parsed are skipped and described in the report.
+func ParseAttendances(allData []byte, p Profile) ([]Attendance, *ParseReport) {
+	var records []Attendance
+	dec := newAttendanceDecoder(p, func(att Attendance) error {
+		records = append(records, att)
+		return nil
+	})
+	dec.write(allData)
+	dec.close()
+	return records, &dec.report
+}
+
+// ParseAttendanceRecord parses a 40-byte attendance record as found in the
+// attendance log.
+func ParseAttendanceRecord(rec []byte) (*Attendance, error) {
+	return parseAttendanceRecord(rec)
 }
 
 // parseAttendanceRecord parses a 40-byte attendance record.
 // Uses the same hex-based parsing as the PHP package for compatibility.
-func parseAttendanceRecord(rec []byte) *Attendance {
+func parseAttendanceRecord(rec []byte) (*Attendance, error) {
 	if len(rec) < 39 {
-		return nil
+		return nil, errShortRecord(len(rec), 39)
 	}
 
 	hexStr := hex.EncodeToString(rec[:39])
-	if len(hexStr) < 68 {
-		return nil
-	}
 
 	// UID: bytes 2-3 (hex offset 4-7)
 	uidLo, _ := strconv.ParseInt(hexStr[4:6], 16, 64)
 	uidHi, _ := strconv.ParseInt(hexStr[6:8], 16, 64)
 	uid := int(uidHi*256 + uidLo)
 	if uid == 0 {
-		return nil
+		return nil, errEmptyRecord
 	}
 
 	// UserID: bytes 4-12 (hex offset 8-25), 9 bytes ASCII
@@ -92,12 +472,125 @@ func parseAttendanceRecord(rec []byte) *Attendance {
 		State:      int(state),
 		RecordTime: recordTime,
 		Type:       int(typ),
+		Reader:     int(rec[34]), // first reserved byte
+	}, nil
+}
+
+// detectAttendanceRecordSize tells 40-byte records from the 44-byte records
+// with a trailing work code that some Push-SDK-era firmwares send, using the
+// table size and, when both sizes fit, the records at hand.
+func detectAttendanceRecordSize(size int, records []byte) int {
+	fits40, fits44 := size%40 == 0, size%44 == 0
+	switch {
+	case fits44 && !fits40:
+		return 44
+	case fits40 && fits44:
+		if plausibleAttendanceRecords(records, 44) > plausibleAttendanceRecords(records, 40) {
+			return 44
+		}
+	}
+	return 40
+}
+
+// plausibleAttendanceRecords counts the records among the first ten at the
+// given stride that have a UID and a printable, NUL-padded user ID.
+func plausibleAttendanceRecords(records []byte, stride int) int {
+	n := 0
+	for i := 0; i < 10 && (i+1)*stride <= len(records); i++ {
+		rec := records[i*stride:]
+		if binary.LittleEndian.Uint16(rec[0:2]) != 0 && plausibleUserID(rec[2:26]) {
+			n++
+		}
+	}
+	return n
+}
+
+// plausibleUserID reports whether b is printable ASCII followed by NULs.
+func plausibleUserID(b []byte) bool {
+	end := false
+	for _, c := range b {
+		switch {
+		case c == 0:
+			end = true
+		case end || c < 0x20 || c > 0x7E:
+			return false
+		}
+	}
+	return b[0] != 0
+}
+
+// parseAttendanceRecord44 parses a 44-byte attendance record:
+// uid(2) + user ID(24) + state(1) + timestamp(4) + type(1) + reader(1) +
+// reserved(7) + workcode(4).
+func parseAttendanceRecord44(rec []byte) (*Attendance, error) {
+	if len(rec) < 44 {
+		return nil, errShortRecord(len(rec), 44)
+	}
+
+	uid := int(binary.LittleEndian.Uint16(rec[0:2]))
+	if uid == 0 {
+		return nil, errEmptyRecord
+	}
+
+	return &Attendance{
+		UID:        uid,
+		UserID:     strings.TrimRight(string(rec[2:26]), "\x00"),
+		State:      int(rec[26]),
+		RecordTime: decodeTime(binary.LittleEndian.Uint32(rec[27:31])),
+		Type:       int(rec[31]),
+		Reader:     int(rec[32]),
+		WorkCode:   int(binary.LittleEndian.Uint32(rec[40:44])),
+	}, nil
+}
+
+// parseAttendanceRecord16 parses a 16-byte attendance record:
+// user ID(4) + timestamp(4) + state(1) + type(1) + reserved(2) + workcode(4).
+// The numeric user ID doubles as the UID on these firmwares.
+func parseAttendanceRecord16(rec []byte) (*Attendance, error) {
+	if len(rec) < 16 {
+		return nil, errShortRecord(len(rec), 16)
+	}
+
+	uid := int(binary.LittleEndian.Uint32(rec[0:4]))
+	if uid == 0 {
+		return nil, errEmptyRecord
+	}
+
+	return &Attendance{
+		UID:        uid,
+		UserID:     strconv.Itoa(uid),
+		State:      int(rec[8]),
+		RecordTime: decodeTime(binary.LittleEndian.Uint32(rec[4:8])),
+		Type:       int(rec[9]),
+		WorkCode:   int(binary.LittleEndian.Uint32(rec[12:16])),
+	}, nil
+}
+
+// parseAttendanceRecord8 parses an 8-byte attendance record:
+// uid(2) + state(1) + timestamp(4) + type(1).
+func parseAttendanceRecord8(rec []byte) (*Attendance, error) {
+	if len(rec) < 8 {
+		return nil, errShortRecord(len(rec), 8)
+	}
+
+	uid := int(binary.LittleEndian.Uint16(rec[0:2]))
+	if uid == 0 {
+		return nil, errEmptyRecord
 	}
+
+	return &Attendance{
+		UID:        uid,
+		UserID:     strconv.Itoa(uid),
+		State:      int(rec[2]),
+		RecordTime: decodeTime(binary.LittleEndian.Uint32(rec[3:7])),
+		Type:       int(rec[7]),
+	}, nil
 }
 
 // ClearAttendance clears all attendance records.
 // WARNING: This is destructive!
-func (z *ZKTeco) ClearAttendance() error {
+func (z *ZKTeco) ClearAttendance() (err error) {
+	defer z.recoverInternal("clearAttendance", &err)
 	resp, err := z.command(CMD_CLEAR_ATT_LOG, nil, "general")
 	if err != nil {
 		return fmt.Errorf("clearAttendance: %w", err)
@@ -107,18 +600,79 @@ func (z *ZKTeco) ClearAttendance() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("clearAttendance: error response %d", pkt.Command)
+		return fmt.Errorf("clearAttendance: error response %s", commandString(pkt.Command))
+	}
+	return nil
+}
+
+// ErrAttendanceChanged is returned by SafeClearAttendance when the
+// attendance log holds records that were not downloaded.
+var ErrAttendanceChanged = errors.New("attendance log changed since download")
+
+// SafeClearAttendance clears the attendance log only if it still matches the
+// last download: expectedCount records, none newer than newestSeen. With the
+// device disabled so no punch can arrive in between, it checks the log count
+// and then the newest record, and fails with ErrAttendanceChanged instead of
+// clearing punches that were never synced. A device already disabled is left
+// disabled; otherwise it is enabled again and its data refreshed.
+func (z *ZKTeco) SafeClearAttendance(expectedCount int, newestSeen time.Time) (err error) {
+	defer z.recoverInternal("safeClearAttendance", &err)
+	checkAndClear := func() error {
+		mem, err := z.GetMemoryInfo()
+		if err != nil {
+			return err
+		}
+		if mem.LogCount != expectedCount {
+			return fmt.Errorf("%w: %d records, expected %d", ErrAttendanceChanged, mem.LogCount, expectedCount)
+		}
+
+		var newest time.Time
+		err = z.EachAttendance(context.Background(), func(a Attendance) error {
+			if a.RecordTime.After(newest) {
+				newest = a.RecordTime
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if newest.After(newestSeen) {
+			return fmt.Errorf("%w: newest record at %s, last seen %s", ErrAttendanceChanged,
+				newest.Format(time.DateTime), newestSeen.Format(time.DateTime))
+		}
+		return z.ClearAttendance()
+	}
+
+	if z.isDisabled() {
+		err = checkAndClear()
+	} else {
+		err = z.WithDeviceDisabled(context.Background(), checkAndClear)
+	}
+	if err != nil {
+		return fmt.Errorf("safeClearAttendance: %w", err)
 	}
 	return nil
 }
 
 // GetFingerprints retrieves fingerprint data for a user.
-func (z *ZKTeco) GetFingerprints(uid int) (map[int][]byte, error) {
+func (z *ZKTeco) GetFingerprints(uid int) (_ map[int][]byte, err error) {
+	defer z.recoverInternal("getFingerprints", &err)
 	result := make(map[int][]byte)
+	err = z.whileDisabled(func() error {
+		z.readFingerprints(uid, result)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getFingerprints: %w", err)
+	}
+	return result, nil
+}
 
+// readFingerprints reads the templates of the ten fingers of uid into result.
+func (z *ZKTeco) readFingerprints(uid int, result map[int][]byte) {
 	for finger := 0; finger <= 9; finger++ {
 		data := []byte{byte(uid & 0xFF), byte((uid >> 8) & 0xFF), byte(finger)}
-		allData, err := z.commandData(CMD_USER_TEMP_RRQ, data)
+		allData, err := z.commandData(context.Background(), CMD_USER_TEMP_RRQ, data)
 		if err != nil {
 			continue // No fingerprint for this finger
 		}
@@ -143,6 +697,4 @@ func (z *ZKTeco) GetFingerprints(uid int) (map[int][]byte, error) {
 			}
 		}
 	}
-
-	return result, nil
 }
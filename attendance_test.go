@@ -0,0 +1,77 @@
+package zkteco
+
+import (
+	"strconv"
+	"testing"
+)
+
+// decodeAttendances decodes the chunks of an attendance transfer.
+func decodeAttendances(p Profile, chunks ...[]byte) []Attendance {
+	var records []Attendance
+	dec := newAttendanceDecoder(p, func(att Attendance) error {
+		records = append(records, att)
+		return nil
+	})
+	for _, chunk := range chunks {
+		dec.write(chunk)
+	}
+	dec.close()
+	return records
+}
+
+func TestAttendanceDecoderSplits(t *testing.T) {
+	var ten44 [][]byte
+	var tenIDs []string
+	for i := range 10 {
+		userID := strconv.Itoa(100 * (i + 1))
+		ten44 = append(ten44, testAttendanceRecord44(i+1, userID, testPunch, 7))
+		tenIDs = append(tenIDs, userID)
+	}
+	two40 := sizePrefixed(testAttendanceRecord(1, "100", testPunch), testAttendanceRecord(2, "200", testPunch))
+	two44 := sizePrefixed(testAttendanceRecord44(1, "100", testPunch, 7), testAttendanceRecord44(2, "200", testPunch, 7))
+	two16 := sizePrefixed(testAttendanceRecord16(100, testPunch), testAttendanceRecord16(200, testPunch))
+
+	tests := []struct {
+		name     string
+		profile  Profile
+		table    []byte
+		want     []string // user IDs
+		workCode int
+	}{
+		{"40-byte", ProfileDefault, two40, []string{"100", "200"}, 0},
+		{"44-byte detected from the size", ProfileDefault, two44, []string{"100", "200"}, 7},
+		// 440 bytes are ten 44-byte or eleven 40-byte records
+		{"44-byte detected from the records", ProfileDefault, sizePrefixed(ten44...), tenIDs, 7},
+		{"16-byte", ProfileLegacy, two16, []string{"100", "200"}, 0},
+		{"truncated 40-byte", ProfileDefault, two40[:len(two40)-15], []string{"100"}, 0},
+		{"truncated 44-byte", ProfileDefault, two44[:len(two44)-10], []string{"100"}, 7},
+		{"truncated 16-byte", ProfileLegacy, two16[:len(two16)-5], []string{"100"}, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// The first chunk starts with the 8-byte packet header
+			data := append(make([]byte, 8), tc.table...)
+			check := func(how string, records []Attendance) {
+				t.Helper()
+				if len(records) != len(tc.want) {
+					t.Fatalf("%s: decoded %d records, want %d", how, len(records), len(tc.want))
+				}
+				for i, att := range records {
+					if att.UserID != tc.want[i] || !att.RecordTime.Equal(testPunch) || att.WorkCode != tc.workCode {
+						t.Fatalf("%s: record %d = %s at %s, work code %d; want %s at %s, work code %d",
+							how, i, att.UserID, att.RecordTime, att.WorkCode, tc.want[i], testPunch, tc.workCode)
+					}
+				}
+			}
+
+			for split := 0; split <= len(data); split++ {
+				check("split at "+strconv.Itoa(split), decodeAttendances(tc.profile, data[:split], data[split:]))
+			}
+			var bytewise [][]byte
+			for i := range data {
+				bytewise = append(bytewise, data[i:i+1])
+			}
+			check("byte by byte", decodeAttendances(tc.profile, bytewise...))
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package zkteco
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeviceSpec is the desired state of a device, checked by Audit. Zero fields
+// are not checked.
+type DeviceSpec struct {
+	// Options maps device option keys (as for GetDeviceData) to their
+	// expected values.
+	Options map[string]string `json:"options,omitempty"`
+	// Users summarizes the expected user table.
+	Users *UsersSpec `json:"users,omitempty"`
+	// TimeTolerance is the largest accepted difference between the device
+	// clock and the host clock.
+	TimeTolerance time.Duration `json:"time_tolerance,omitempty"`
+}
+
+// UsersSpec summarizes the expected user table of a DeviceSpec.
+type UsersSpec struct {
+	// Count is the expected number of users.
+	Count *int `json:"count,omitempty"`
+	// Required lists user IDs that must be enrolled.
+	Required []string `json:"required,omitempty"`
+	// MaxAdmins is the largest accepted number of users with the admin role.
+	MaxAdmins *int `json:"max_admins,omitempty"`
+}
+
+// Kinds of Deviation.
+const (
+	DeviationOption = "option"
+	DeviationUsers  = "users"
+	DeviationTime   = "time"
+)
+
+// Deviation is one difference between a device and its DeviceSpec.
+type Deviation struct {
+	Kind     string `json:"kind"` // DeviationOption, DeviationUsers or DeviationTime
+	Key      string `json:"key"`  // option key, or the users/time check
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+
+	// Remediated is set when Audit fixed the deviation; RemediationError
+	// when it tried and failed.
+	Remediated       bool   `json:"remediated,omitempty"`
+	RemediationError string `json:"remediation_error,omitempty"`
+}
+
+func (d Deviation) String() string {
+	return fmt.Sprintf("%s %s: expected %s, got %s", d.Kind, d.Key, d.Expected, d.Actual)
+}
+
+// AuditReport is the result of Audit.
+type AuditReport struct {
+	DeviceSerial string      `json:"device_serial,omitempty"`
+	CheckedAt    time.Time   `json:"checked_at"`
+	Deviations   []Deviation `json:"deviations"`
+}
+
+// OK reports whether the device matched its spec, or every deviation was
+// remediated.
+func (r *AuditReport) OK() bool {
+	for _, d := range r.Deviations {
+		if !d.Remediated {
+			return false
+		}
+	}
+	return true
+}
+
+// Audit compares the device with spec and reports each deviation. With
+// remediate, differing options are written back (followed by one options
+// reload) and a drifted clock is set to the host time; user table deviations
+// are only reported. The returned error is set when the device state could
+// not be read.
+func (z *ZKTeco) Audit(spec DeviceSpec, remediate bool) (_ *AuditReport, err error) {
+	defer z.recoverInternal("audit", &err)
+	report := &AuditReport{CheckedAt: z.clock.Now(), DeviceSerial: z.serial}
+
+	if err := z.auditOptions(spec.Options, remediate, report); err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	if spec.Users != nil {
+		if err := z.auditUsers(spec.Users, report); err != nil {
+			return nil, fmt.Errorf("audit: %w", err)
+		}
+	}
+	if spec.TimeTolerance > 0 {
+		if err := z.auditTime(spec.TimeTolerance, remediate, report); err != nil {
+			return nil, fmt.Errorf("audit: %w", err)
+		}
+	}
+	return report, nil
+}
+
+func (z *ZKTeco) auditOptions(options map[string]string, remediate bool, report *AuditReport) error {
+	if len(options) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values, err := z.getDeviceOptions(keys)
+	if err != nil {
+		return err
+	}
+
+	var changed []int
+	for i, key := range keys {
+		if values[i] == options[key] {
+			continue
+		}
+		report.Deviations = append(report.Deviations, Deviation{
+			Kind:     DeviationOption,
+			Key:      key,
+			Expected: options[key],
+			Actual:   values[i],
+		})
+		changed = append(changed, len(report.Deviations)-1)
+	}
+	if !remediate || len(changed) == 0 {
+		return nil
+	}
+
+	var written []int
+	for _, i := range changed {
+		d := &report.Deviations[i]
+		data := []byte(fmt.Sprintf("%s=%s", d.Key, d.Expected))
+		if err := z.ackCommand("audit", CMD_OPTIONS_WRQ, data); err != nil {
+			d.RemediationError = err.Error()
+			continue
+		}
+		written = append(written, i)
+	}
+	if len(written) == 0 {
+		return nil
+	}
+
+	refreshErr := z.ackCommand("audit", CMD_REFRESHOPTION, nil)
+	for _, i := range written {
+		if refreshErr != nil {
+			report.Deviations[i].RemediationError = refreshErr.Error()
+		} else {
+			report.Deviations[i].Remediated = true
+		}
+	}
+	return nil
+}
+
+func (z *ZKTeco) auditUsers(spec *UsersSpec, report *AuditReport) error {
+	users, err := z.GetUsers()
+	if err != nil {
+		return err
+	}
+
+	if spec.Count != nil && len(users) != *spec.Count {
+		report.Deviations = append(report.Deviations, Deviation{
+			Kind:     DeviationUsers,
+			Key:      "count",
+			Expected: strconv.Itoa(*spec.Count),
+			Actual:   strconv.Itoa(len(users)),
+		})
+	}
+
+	if len(spec.Required) > 0 {
+		enrolled := make(map[string]bool, len(users))
+		for _, u := range users {
+			enrolled[u.UserID] = true
+		}
+		var missing []string
+		for _, id := range spec.Required {
+			if !enrolled[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			report.Deviations = append(report.Deviations, Deviation{
+				Kind:     DeviationUsers,
+				Key:      "required",
+				Expected: "enrolled",
+				Actual:   "missing " + strings.Join(missing, ","),
+			})
+		}
+	}
+
+	if spec.MaxAdmins != nil {
+		admins := 0
+		for _, u := range users {
+			if u.Role == LEVEL_ADMIN {
+				admins++
+			}
+		}
+		if admins > *spec.MaxAdmins {
+			report.Deviations = append(report.Deviations, Deviation{
+				Kind:     DeviationUsers,
+				Key:      "max_admins",
+				Expected: strconv.Itoa(*spec.MaxAdmins),
+				Actual:   strconv.Itoa(admins),
+			})
+		}
+	}
+	return nil
+}
+
+func (z *ZKTeco) auditTime(tolerance time.Duration, remediate bool, report *AuditReport) error {
+	deviceTime, err := z.GetTime()
+	if err != nil {
+		return err
+	}
+	drift := deviceTime.Sub(z.clock.Now())
+	if absDuration(drift) <= tolerance {
+		return nil
+	}
+
+	d := Deviation{
+		Kind:     DeviationTime,
+		Key:      "drift",
+		Expected: "within " + tolerance.String(),
+		Actual:   drift.Round(time.Second).String(),
+	}
+	if remediate {
+		if err := z.SetTime(z.clock.Now()); err != nil {
+			d.RemediationError = err.Error()
+		} else {
+			d.Remediated = true
+		}
+	}
+	report.Deviations = append(report.Deviations, d)
+	return nil
+}
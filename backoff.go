@@ -0,0 +1,84 @@
+package zkteco
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff decides how long to wait before reconnecting to a device. attempt
+// counts the failed attempts so far, starting at 1. Some firmwares lock up
+// when hammered with connections while they reboot, so reconnect loops
+// should space out their attempts.
+type Backoff interface {
+	Delay(attempt int) time.Duration
+}
+
+// ExponentialBackoff multiplies the delay by Multiplier after each failed
+// attempt, from Initial up to Max, and spreads each delay randomly by up to
+// ±Jitter of its value so devices restarted together are not reconnected in
+// lockstep.
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64 // fraction of the delay, between 0 and 1
+}
+
+// DefaultBackoff starts at one second and doubles up to one minute, with
+// 20% jitter.
+var DefaultBackoff Backoff = ExponentialBackoff{
+	Initial:    time.Second,
+	Max:        time.Minute,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+// Delay returns the delay before the attempt following the attempt-th
+// failure.
+func (b ExponentialBackoff) Delay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt-1))
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	if d < 0 {
+		return 0
+	}
+	return time.Duration(d)
+}
+
+// ConstantBackoff waits the same time before every attempt.
+type ConstantBackoff time.Duration
+
+// Delay returns b.
+func (b ConstantBackoff) Delay(int) time.Duration {
+	return time.Duration(b)
+}
+
+// ConnectWithBackoff calls Connect until it succeeds, waiting as b says
+// between attempts (DefaultBackoff if b is nil). It returns the last Connect
+// error once ctx is done.
+func (z *ZKTeco) ConnectWithBackoff(ctx context.Context, b Backoff) (err error) {
+	defer z.recoverInternal("connectWithBackoff", &err)
+	if b == nil {
+		b = DefaultBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		err := z.Connect()
+		if err == nil {
+			return nil
+		}
+
+		if !sleep(ctx, z.clock, b.Delay(attempt)) {
+			return fmt.Errorf("connect: giving up after %d attempts: %w", attempt, err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package zkteco
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+)
+
+// ErrDeviceBusy is returned when the device is serving another client: it
+// answered CMD_ACK_RETRY, or dropped the connection as soon as it was opened.
+// Schedulers can test for it with errors.Is and try again later.
+var ErrDeviceBusy = errors.New("device busy")
+
+// busyRetryInterval is the wait between attempts while the device is busy.
+const busyRetryInterval = time.Second
+
+// WithBusyWait makes Connect and commands retry for up to d while the device
+// is busy, before failing with ErrDeviceBusy. Default is 0 (fail at once).
+func WithBusyWait(d time.Duration) Option {
+	return func(z *ZKTeco) {
+		z.busyWait = d
+	}
+}
+
+// isBusyResponse reports whether resp is a CMD_ACK_RETRY reply.
+func isBusyResponse(resp []byte) bool {
+	return len(resp) >= 2 && binary.LittleEndian.Uint16(resp[0:2]) == CMD_ACK_RETRY
+}
+
+// busyError marks err as ErrDeviceBusy when the connection was closed or
+// reset during the handshake, which is how devices that accept a single
+// client turn away the others.
+func busyError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) {
+		return fmt.Errorf("%w: %w", ErrDeviceBusy, err)
+	}
+	return err
+}
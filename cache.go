@@ -0,0 +1,66 @@
+package zkteco
+
+import "time"
+
+// versionCacheKey caches the CMD_VERSION answer alongside the options; option
+// keys never contain spaces.
+const versionCacheKey = "firmware version"
+
+// WithCache caches the device options read one at a time (SerialNumber,
+// Platform, GetDeviceData, the thresholds, ...) and the firmware version for
+// ttl, so they can be called freely without a round trip each time. The
+// cache belongs to the connection: it is emptied by Connect and by every
+// option write or device clear. Changes made on the terminal's own menu show
+// up once ttl expires. Bulk and monitoring reads (Audit, WatchOptions,
+// DumpOptions, GetDeviceSnapshot) always query the device.
+func WithCache(ttl time.Duration) Option {
+	return func(z *ZKTeco) {
+		z.cacheTTL = ttl
+	}
+}
+
+type cachedValue struct {
+	value   string
+	expires time.Time
+}
+
+// cached returns the cached value of key, if any and not expired.
+func (z *ZKTeco) cached(key string) (string, bool) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	c, ok := z.cache[key]
+	if !ok || z.clock.Now().After(c.expires) {
+		return "", false
+	}
+	return c.value, true
+}
+
+// setCached caches value under key, when caching is enabled.
+func (z *ZKTeco) setCached(key, value string) {
+	if z.cacheTTL <= 0 {
+		return
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.cache == nil {
+		z.cache = make(map[string]cachedValue)
+	}
+	z.cache[key] = cachedValue{value, z.clock.Now().Add(z.cacheTTL)}
+}
+
+// InvalidateCache empties the WithCache cache, e.g. after changing the
+// device from another client.
+func (z *ZKTeco) InvalidateCache() {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.cache = nil
+}
+
+// invalidatesCache lists the commands after which cached values may be stale.
+var invalidatesCache = map[uint16]bool{
+	CMD_OPTIONS_WRQ:   true,
+	CMD_REFRESHOPTION: true,
+	CMD_CLEAR_DATA:    true,
+	CMD_RESTART:       true,
+	CMD_UPDATEFILE:    true,
+}
@@ -0,0 +1,268 @@
+package zkteco
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of a client and of what runs on it: busy
+// retries, keepalive, cache expiry, listen timeouts, command latencies,
+// event and record timestamps, clock checks and sync loops. Tests set a FakeClock with
+// WithClock to run timeout, drift and scheduling logic deterministically,
+// without waiting. Network deadlines always follow the real clock.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a time.Timer of a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a time.Ticker of a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// SystemClock is the Clock of the host, the default.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// WithClock sets the clock of the client (see Clock). Default is
+// SystemClock.
+func WithClock(c Clock) Option {
+	return func(z *ZKTeco) {
+		if c != nil {
+			z.clock = c
+		}
+	}
+}
+
+// Clock returns the clock of the client, for components that keep time
+// alongside it.
+func (z *ZKTeco) Clock() Clock {
+	return z.clock
+}
+
+// sleep waits for d on c, or until ctx is done; it reports whether d passed.
+func sleep(ctx context.Context, c Clock, d time.Duration) bool {
+	t := c.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ClockSource is the TimeSource of c; ClockSource(SystemClock) is HostClock.
+func ClockSource(c Clock) TimeSource {
+	return clockSource{c}
+}
+
+type clockSource struct{ c Clock }
+
+func (s clockSource) Now() (time.Time, error) {
+	return s.c.Now(), nil
+}
+
+// FakeClock is a Clock that only moves when told to. Advance runs time
+// forward, firing the timers and ticks that fall due in order; Set also
+// jumps back, e.g. to simulate a host clock correction. Timers and tickers
+// deliver like the time package's: a tick that finds the channel full is
+// dropped.
+//
+//	clock := zkteco.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+//	zk := zkteco.NewZKTeco(host, port, zkteco.WithClock(clock))
+//	go zk.RunTimeSync(ctx, cfg)
+//	clock.BlockUntil(1)         // the loop waits for its next check
+//	clock.Advance(6 * time.Hour) // and runs it at once
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // signaled when timers are added or removed
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+// NewFakeClock creates a FakeClock reading now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current fake time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d, firing the timers and ticks due
+// until then.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for len(c.waiters) > 0 && !c.waiters[0].when.After(end) {
+		t := c.waiters[0]
+		if t.when.After(c.now) {
+			c.now = t.when
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+		if t.period > 0 {
+			t.when = t.when.Add(t.period)
+		} else {
+			c.removeLocked(t)
+		}
+		c.sortLocked()
+	}
+	if end.After(c.now) {
+		c.now = end
+	}
+}
+
+// Set moves the clock to now, forward as Advance does or backward without
+// firing anything.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	if !now.After(c.now) {
+		c.now = now
+		c.mu.Unlock()
+		return
+	}
+	d := now.Sub(c.now)
+	c.mu.Unlock()
+	c.Advance(d)
+}
+
+// BlockUntil waits until n timers and tickers are pending, so a test knows
+// the code under test is waiting on the clock before advancing it.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+// NewTimer creates a timer firing once the clock has advanced by d.
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// NewTicker creates a ticker firing every time the clock has advanced by d.
+// It panics if d is not positive, as time.NewTicker does.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("zkteco: non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	t.reset(d, d)
+	return fakeTicker{t}
+}
+
+func (c *FakeClock) addLocked(t *fakeTimer) {
+	c.waiters = append(c.waiters, t)
+	c.sortLocked()
+	c.cond.Broadcast()
+}
+
+// removeLocked removes t and reports whether it was pending.
+func (c *FakeClock) removeLocked(t *fakeTimer) bool {
+	for i, w := range c.waiters {
+		if w == t {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			c.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+func (c *FakeClock) sortLocked() {
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].when.Before(c.waiters[j].when)
+	})
+}
+
+// fakeTimer is a timer of a FakeClock, or a ticker if period is set.
+type fakeTimer struct {
+	clock  *FakeClock
+	c      chan time.Time
+	when   time.Time
+	period time.Duration
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.removeLocked(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	return t.reset(d, 0)
+}
+
+func (t *fakeTimer) reset(d, period time.Duration) bool {
+	c := t.clock
+	c.mu.Lock()
+	active := c.removeLocked(t)
+	t.when = c.now.Add(d)
+	t.period = period
+	if d <= 0 && period == 0 {
+		select {
+		case t.c <- c.now:
+		default:
+		}
+		c.mu.Unlock()
+		return active
+	}
+	c.addLocked(t)
+	c.mu.Unlock()
+	return active
+}
+
+type fakeTicker struct{ t *fakeTimer }
+
+func (t fakeTicker) C() <-chan time.Time { return t.t.c }
+
+func (t fakeTicker) Stop() { t.t.Stop() }
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("zkteco: non-positive interval for Ticker.Reset")
+	}
+	t.t.reset(d, d)
+}
@@ -0,0 +1,126 @@
+package zkteco
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/0mithun/go-zkteco/protocol"
+)
+
+var testClockStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// answerTime answers CMD_GET_TIME with reply, and the rest with CMD_ACK_OK.
+func answerTime(reply uint16) func(pkt *protocol.Packet) [][]byte {
+	return func(pkt *protocol.Packet) [][]byte {
+		if pkt.Command != CMD_GET_TIME {
+			return [][]byte{devicePacket(CMD_ACK_OK, pkt.ReplyID, nil)}
+		}
+		data := binary.LittleEndian.AppendUint32(nil, encodeTime(testClockStart))
+		return [][]byte{devicePacket(reply, pkt.ReplyID, data)}
+	}
+}
+
+func TestKeepaliveSkipsBusyConnection(t *testing.T) {
+	clock := NewFakeClock(testClockStart)
+	dev := newMemTransport(answerTime(CMD_ACK_OK))
+	zk := NewZKTeco("device", 4370, WithTransport(dev), WithClock(clock), WithKeepalive(time.Minute))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+	clock.BlockUntil(1)
+
+	// A command half-way through the interval postpones the heartbeat
+	clock.Advance(30 * time.Second)
+	if _, err := zk.GetTime(); err != nil {
+		t.Fatalf("GetTime: %v", err)
+	}
+	clock.Advance(30 * time.Second)
+	clock.BlockUntil(1)
+	if n := dev.count(CMD_GET_TIME); n != 1 {
+		t.Fatalf("CMD_GET_TIME sent %d times, want 1: no heartbeat 30s after a command", n)
+	}
+
+	// A full interval of silence sends it
+	clock.Advance(30 * time.Second)
+	clock.BlockUntil(1)
+	if n := dev.count(CMD_GET_TIME); n != 2 {
+		t.Fatalf("CMD_GET_TIME sent %d times, want 2 after an idle minute", n)
+	}
+}
+
+func TestBusyWaitGivesUp(t *testing.T) {
+	clock := NewFakeClock(testClockStart)
+	dev := newMemTransport(answerTime(CMD_ACK_RETRY))
+	zk := NewZKTeco("device", 4370, WithTransport(dev), WithClock(clock), WithBusyWait(3*time.Second))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := zk.GetTime()
+		done <- err
+	}()
+
+	// Attempts at 0s, 1s and 2s; a fourth would end past the 3s wait
+	for range 2 {
+		clock.BlockUntil(1)
+		clock.Advance(busyRetryInterval)
+	}
+	if err := <-done; !errors.Is(err, ErrDeviceBusy) {
+		t.Fatalf("GetTime = %v, want ErrDeviceBusy", err)
+	}
+	if n := dev.count(CMD_GET_TIME); n != 3 {
+		t.Errorf("CMD_GET_TIME sent %d times, want 3", n)
+	}
+}
+
+func TestTraceDurationFollowsClock(t *testing.T) {
+	clock := NewFakeClock(testClockStart)
+	dev := newMemTransport(answerTime(CMD_ACK_OK))
+	zk := NewZKTeco("device", 4370, WithTransport(dev), WithClock(clock), WithTrace(4))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+
+	if _, err := zk.GetTime(); err != nil {
+		t.Fatalf("GetTime: %v", err)
+	}
+	trace := zk.Trace()
+	if len(trace) != 2 {
+		t.Fatalf("Trace has %d entries, want 2", len(trace))
+	}
+	if last := trace[1]; !last.Time.Equal(testClockStart) || last.Duration != 0 {
+		t.Errorf("trace entry at %s for %s, want %s for 0s", last.Time, last.Duration, testClockStart)
+	}
+}
+
+func TestSelfTestClockCheckFollowsClock(t *testing.T) {
+	clock := NewFakeClock(testClockStart)
+	// The device clock runs with the fake one
+	dev := newMemTransport(func(pkt *protocol.Packet) [][]byte {
+		var data []byte
+		if pkt.Command == CMD_GET_TIME {
+			data = binary.LittleEndian.AppendUint32(nil, encodeTime(clock.Now()))
+		}
+		return [][]byte{devicePacket(CMD_ACK_OK, pkt.ReplyID, data)}
+	})
+	zk := NewZKTeco("device", 4370, WithTransport(dev), WithClock(clock))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+
+	done := make(chan *SelfTest, 1)
+	go func() { done <- zk.RunSelfTest() }()
+	clock.BlockUntil(1)
+	clock.Advance(selfTestClockWait)
+	if result := <-done; !result.Passed() {
+		t.Errorf("RunSelfTest failed:\n%s", result)
+	}
+}
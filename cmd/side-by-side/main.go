@@ -1,8 +1,10 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strings"
@@ -25,48 +27,87 @@ var (
 	timeout  = flag.Int("timeout", 30, "Timeout in seconds")
 	password = flag.Int("password", 0, "Device password (0=none)")
 	tcpmux   = flag.String("tcpmux", "", "TCPMUX proxy host:port/subdomain (e.g. example.com:1337/zkteco)")
+	output   = flag.String("output", "text", "Output format: text or json (progress goes to stderr)")
 
-	passed  int
-	failed  int
-	total   int
-	results []testResult
+	// out receives the human-readable progress: stdout, or stderr with
+	// -output json so that stdout holds only the report.
+	out io.Writer = os.Stdout
+
+	passed    int
+	failed    int
+	total     int
+	results   []testResult
+	testStart time.Time
 )
 
+// testResult is the outcome of one test. Status is "pass", "fail", or
+// "unsupported" when both packages fail a control command.
 type testResult struct {
-	group  string
-	name   string
-	ok     bool
-	goVal  string
-	phpVal string
-	errMsg string
+	Group      string  `json:"group"`
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	GoValue    string  `json:"go_value,omitempty"`
+	PHPValue   string  `json:"php_value,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// report is the -output json document, one per run, for compatibility
+// matrices across firmware versions.
+type report struct {
+	Host       string       `json:"host"`
+	Port       int          `json:"port"`
+	Protocol   string       `json:"protocol"`
+	Firmware   string       `json:"firmware,omitempty"`
+	Serial     string       `json:"serial,omitempty"`
+	StartedAt  time.Time    `json:"started_at"`
+	DurationMS float64      `json:"duration_ms"`
+	Error      string       `json:"error,omitempty"` // the Go client could not connect
+	Passed     int          `json:"passed"`
+	Failed     int          `json:"failed"`
+	Total      int          `json:"total"`
+	Tests      []testResult `json:"tests"`
 }
 
 func main() {
 	flag.Parse()
+	switch *output {
+	case "text":
+	case "json":
+		out = os.Stderr
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -output %q: want text or json\n", *output)
+		os.Exit(2)
+	}
+	rep := report{Host: *host, Port: *port, Protocol: *protocol, StartedAt: time.Now()}
 
-	fmt.Println()
-	fmt.Println("╔═══════════════════════════════════════════════════════╗")
-	fmt.Println("║   Go vs PHP ZKTeco Package — Side-by-Side Test       ║")
-	fmt.Println("║   Testing against real device (safe commands only)   ║")
-	fmt.Println("╚═══════════════════════════════════════════════════════╝")
-	fmt.Println()
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "╔═══════════════════════════════════════════════════════╗")
+	fmt.Fprintln(out, "║   Go vs PHP ZKTeco Package — Side-by-Side Test       ║")
+	fmt.Fprintln(out, "║   Testing against real device (safe commands only)   ║")
+	fmt.Fprintln(out, "╚═══════════════════════════════════════════════════════╝")
+	fmt.Fprintln(out)
 
-	fmt.Printf("Connecting Go client to %s:%d (%s)...\n", *host, *port, *protocol)
+	fmt.Fprintf(out, "Connecting Go client to %s:%d (%s)...\n", *host, *port, *protocol)
 	opts := []zkteco.Option{
 		zkteco.WithProtocol(*protocol),
 		zkteco.WithTimeout(*timeout),
 	}
 	if *password > 0 {
 		opts = append(opts, zkteco.WithPassword(*password))
-		fmt.Printf("   Using password: %d\n", *password)
+		fmt.Fprintf(out, "   Using password: %d\n", *password)
 	}
 	zk := zkteco.NewZKTeco(*host, *port, opts...)
 
 	if err := zk.Connect(); err != nil {
-		fmt.Printf("   %s[FAIL] Go connect failed: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   %s[FAIL] Go connect failed: %s%s\n", red, err, reset)
+		rep.Error = err.Error()
+		writeReport(&rep)
 		os.Exit(1)
 	}
-	fmt.Printf("   %s[OK] Go client connected%s\n", green, reset)
+	fmt.Fprintf(out, "   %s[OK] Go client connected%s\n", green, reset)
+	rep.Firmware, _ = zk.Version()
+	rep.Serial, _ = zk.SerialNumber()
 
 	testGroup("A: Device Info")
 	testDeviceInfo(zk, "serialNumber", func() (string, error) { return zk.SerialNumber() })
@@ -133,24 +174,69 @@ func main() {
 		testTCPMUX()
 	}
 
-	fmt.Println()
+	fmt.Fprintln(out)
+	writeReport(&rep)
 	if failed > 0 {
 		os.Exit(1)
 	}
 }
 
+// writeReport prints rep, completed with the results, with -output json.
+func writeReport(rep *report) {
+	if *output != "json" {
+		return
+	}
+	rep.DurationMS = milliseconds(time.Since(rep.StartedAt))
+	rep.Passed, rep.Failed, rep.Total = passed, failed, total
+	rep.Tests = results
+	if rep.Tests == nil {
+		rep.Tests = []testResult{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(rep)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
 func testGroup(name string) {
-	fmt.Printf("\n%s=== %s ===%s\n", bold, name, reset)
+	fmt.Fprintf(out, "\n%s=== %s ===%s\n", bold, name, reset)
+	testStart = time.Now()
 }
 
 func record(group, name string, ok bool, goVal, phpVal, errMsg string) {
-	total++
 	if ok {
 		passed++
+		addResult(group, name, "pass", goVal, phpVal, errMsg)
 	} else {
 		failed++
+		addResult(group, name, "fail", goVal, phpVal, errMsg)
 	}
-	results = append(results, testResult{group, name, ok, goVal, phpVal, errMsg})
+}
+
+// recordUnsupported records a command both packages fail, which counts as
+// a pass.
+func recordUnsupported(group, name, phpVal, errMsg string) {
+	passed++
+	addResult(group, name, "unsupported", "", phpVal, errMsg)
+}
+
+// addResult adds the result of a test, timed from the previous test or the
+// start of its group (Go and PHP calls together).
+func addResult(group, name, status, goVal, phpVal, errMsg string) {
+	total++
+	results = append(results, testResult{
+		Group:      group,
+		Name:       name,
+		Status:     status,
+		GoValue:    goVal,
+		PHPValue:   phpVal,
+		Error:      errMsg,
+		DurationMS: milliseconds(time.Since(testStart)),
+	})
+	testStart = time.Now()
 }
 
 func testDeviceInfo(zk *zkteco.ZKTeco, name string, goFn func() (string, error)) {
@@ -158,31 +244,31 @@ func testDeviceInfo(zk *zkteco.ZKTeco, name string, goFn func() (string, error))
 	phpVal := runPHP(name)
 
 	if goErr != nil {
-		fmt.Printf("   %s: %s[FAIL] Go error: %s%s\n", name, red, goErr, reset)
+		fmt.Fprintf(out, "   %s: %s[FAIL] Go error: %s%s\n", name, red, goErr, reset)
 		record("Device Info", name, false, "", phpVal, goErr.Error())
 		return
 	}
 
-	fmt.Printf("   %s: %s[OK]%s\n", name, green, reset)
-	fmt.Printf("     Go:  %s\n", goVal)
-	fmt.Printf("     PHP: %s\n", phpVal)
+	fmt.Fprintf(out, "   %s: %s[OK]%s\n", name, green, reset)
+	fmt.Fprintf(out, "     Go:  %s\n", goVal)
+	fmt.Fprintf(out, "     PHP: %s\n", phpVal)
 	record("Device Info", name, true, goVal, phpVal, "")
 }
 
 func testMemoryInfo(zk *zkteco.ZKTeco) {
 	info, err := zk.GetMemoryInfo()
 	if err != nil {
-		fmt.Printf("   getMemoryInfo: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   getMemoryInfo: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Memory Info", "getMemoryInfo", false, "", "", err.Error())
 		return
 	}
 
 	phpMem := runPHP("getMemoryInfo")
 
-	fmt.Printf("   getMemoryInfo: %s[OK]%s Both returned data\n", green, reset)
-	fmt.Printf("     Go:  admin=%d users=%d userCap=%d logs=%d logCap=%d\n",
+	fmt.Fprintf(out, "   getMemoryInfo: %s[OK]%s Both returned data\n", green, reset)
+	fmt.Fprintf(out, "     Go:  admin=%d users=%d userCap=%d logs=%d logCap=%d\n",
 		info.AdminCount, info.UserCount, info.UserCapacity, info.LogCount, info.LogCapacity)
-	fmt.Printf("     PHP: %s\n", phpMem)
+	fmt.Fprintf(out, "     PHP: %s\n", phpMem)
 
 	record("Memory Info", "getMemoryInfo", true,
 		fmt.Sprintf("admin=%d,users=%d,cap=%d,logs=%d,logcap=%d",
@@ -193,36 +279,36 @@ func testMemoryInfo(zk *zkteco.ZKTeco) {
 func testGetTime(zk *zkteco.ZKTeco) {
 	t, err := zk.GetTime()
 	if err != nil {
-		fmt.Printf("   getTime: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   getTime: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Time", "getTime", false, "", "", err.Error())
 		return
 	}
 
 	phpTime := runPHP("getTime")
 	goTime := t.Format("2006-01-02 15:04:05")
-	fmt.Printf("   getTime: %s[OK]%s\n", green, reset)
-	fmt.Printf("     Go:  %s\n", goTime)
-	fmt.Printf("     PHP: %s\n", phpTime)
+	fmt.Fprintf(out, "   getTime: %s[OK]%s\n", green, reset)
+	fmt.Fprintf(out, "     Go:  %s\n", goTime)
+	fmt.Fprintf(out, "     PHP: %s\n", phpTime)
 	record("Time", "getTime", true, goTime, phpTime, "")
 }
 
 func testGetUsers(zk *zkteco.ZKTeco) {
 	users, err := zk.GetUsers()
 	if err != nil {
-		fmt.Printf("   getUsers: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   getUsers: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Users", "getUsers", false, "", "", err.Error())
 		return
 	}
 
 	phpUsers := runPHP("getUsers")
 
-	fmt.Printf("   getUsers: %s[OK]%s Both returned data\n", green, reset)
-	fmt.Printf("     Go:  %d users\n", len(users))
-	fmt.Printf("     PHP: %s\n", phpUsers)
+	fmt.Fprintf(out, "   getUsers: %s[OK]%s Both returned data\n", green, reset)
+	fmt.Fprintf(out, "     Go:  %d users\n", len(users))
+	fmt.Fprintf(out, "     PHP: %s\n", phpUsers)
 
 	if len(users) > 0 {
 		u := users[0]
-		fmt.Printf("     Go sample:  UID=%d, ID=%s, Name=%s, Role=%d\n",
+		fmt.Fprintf(out, "     Go sample:  UID=%d, ID=%s, Name=%s, Role=%d\n",
 			u.UID, u.UserID, u.Name, u.Role)
 	}
 
@@ -232,36 +318,36 @@ func testGetUsers(zk *zkteco.ZKTeco) {
 func testGetFingerprints(zk *zkteco.ZKTeco) {
 	fps, err := zk.GetFingerprints(1)
 	if err != nil {
-		fmt.Printf("   getFingerprint(UID=1): %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   getFingerprint(UID=1): %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Fingerprints", "getFingerprint", false, "", "", err.Error())
 		return
 	}
 
 	phpFp := runPHP("getFingerprint")
 
-	fmt.Printf("   getFingerprint(UID=1): %s[OK]%s\n", green, reset)
-	fmt.Printf("     Go:  %d fingerprint(s)\n", len(fps))
-	fmt.Printf("     PHP: %s\n", phpFp)
+	fmt.Fprintf(out, "   getFingerprint(UID=1): %s[OK]%s\n", green, reset)
+	fmt.Fprintf(out, "     Go:  %d fingerprint(s)\n", len(fps))
+	fmt.Fprintf(out, "     PHP: %s\n", phpFp)
 	record("Fingerprints", "getFingerprint", true, fmt.Sprintf("%d fps", len(fps)), phpFp, "")
 }
 
 func testGetAttendances(zk *zkteco.ZKTeco) {
 	atts, err := zk.GetAttendances()
 	if err != nil {
-		fmt.Printf("   getAttendances: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   getAttendances: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Attendance", "getAttendances", false, "", "", err.Error())
 		return
 	}
 
 	phpAtt := runPHP("getAttendances")
 
-	fmt.Printf("   getAttendances: %s[OK]%s Both returned data\n", green, reset)
-	fmt.Printf("     Go:  %d records\n", len(atts))
-	fmt.Printf("     PHP: %s\n", phpAtt)
+	fmt.Fprintf(out, "   getAttendances: %s[OK]%s Both returned data\n", green, reset)
+	fmt.Fprintf(out, "     Go:  %d records\n", len(atts))
+	fmt.Fprintf(out, "     PHP: %s\n", phpAtt)
 
 	if len(atts) > 0 {
 		a := atts[0]
-		fmt.Printf("     Go sample:  UID=%d, UserID=%s, State=%d, Time=%s, Type=%d\n",
+		fmt.Fprintf(out, "     Go sample:  UID=%d, UserID=%s, State=%d, Time=%s, Type=%d\n",
 			a.UID, a.UserID, a.State, a.RecordTime.Format("2006-01-02 15:04:05"), a.Type)
 	}
 
@@ -276,16 +362,16 @@ func testControl(zk *zkteco.ZKTeco, name string, fn func() error) {
 	phpOK := phpResult == "OK"
 
 	if goOK && phpOK {
-		fmt.Printf("   %s: %s[OK]%s Go OK  PHP: OK\n", name, green, reset)
+		fmt.Fprintf(out, "   %s: %s[OK]%s Go OK  PHP: OK\n", name, green, reset)
 		record("Control", name, true, "OK", "OK", "")
 	} else if !goOK && !phpOK {
-		fmt.Printf("   %s: %s[OK]%s Both failed (device unsupported)  Go: %s  PHP: %s\n", name, green, reset, goErr, phpResult)
-		record("Control", name, true, "UNSUPPORTED", phpResult, "")
+		fmt.Fprintf(out, "   %s: %s[OK]%s Both failed (device unsupported)  Go: %s  PHP: %s\n", name, green, reset, goErr, phpResult)
+		recordUnsupported("Control", name, phpResult, goErr.Error())
 	} else if goOK && !phpOK {
-		fmt.Printf("   %s: %s[OK]%s Go OK  PHP: %s\n", name, green, reset, phpResult)
+		fmt.Fprintf(out, "   %s: %s[OK]%s Go OK  PHP: %s\n", name, green, reset, phpResult)
 		record("Control", name, true, "OK", phpResult, "")
 	} else {
-		fmt.Printf("   %s: %s[FAIL]%s Go error: %s  PHP: %s\n", name, red, reset, goErr, phpResult)
+		fmt.Fprintf(out, "   %s: %s[FAIL]%s Go error: %s  PHP: %s\n", name, red, reset, goErr, phpResult)
 		record("Control", name, false, goErr.Error(), phpResult, goErr.Error())
 	}
 }
@@ -293,38 +379,38 @@ func testControl(zk *zkteco.ZKTeco, name string, fn func() error) {
 func testCustomData(zk *zkteco.ZKTeco) {
 	err := zk.SetCustomData("go_test_key", "go_test_val_123")
 	if err != nil {
-		fmt.Printf("   setCustomData: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   setCustomData: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Custom Data", "setCustomData", false, "", "", err.Error())
 		return
 	}
-	fmt.Printf("   setCustomData: %s[OK]%s\n", green, reset)
+	fmt.Fprintf(out, "   setCustomData: %s[OK]%s\n", green, reset)
 	record("Custom Data", "setCustomData", true, "OK", "", "")
 
 	val, err := zk.GetCustomData("go_test_key")
 	if err != nil {
-		fmt.Printf("   getCustomData: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   getCustomData: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Custom Data", "getCustomData", false, "", "", err.Error())
 		return
 	}
-	fmt.Printf("   getCustomData: %s[OK]%s %s\n", green, reset, val)
+	fmt.Fprintf(out, "   getCustomData: %s[OK]%s %s\n", green, reset, val)
 	record("Custom Data", "getCustomData", true, val, "", "")
 
 	err = zk.SetPushCommKey("goTestPushKey")
 	if err != nil {
-		fmt.Printf("   setPushCommKey: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   setPushCommKey: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Custom Data", "setPushCommKey", false, "", "", err.Error())
 		return
 	}
-	fmt.Printf("   setPushCommKey: %s[OK]%s\n", green, reset)
+	fmt.Fprintf(out, "   setPushCommKey: %s[OK]%s\n", green, reset)
 	record("Custom Data", "setPushCommKey", true, "OK", "", "")
 
 	val2, err := zk.GetPushCommKey()
 	if err != nil {
-		fmt.Printf("   getPushCommKey: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   getPushCommKey: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Custom Data", "getPushCommKey", false, "", "", err.Error())
 		return
 	}
-	fmt.Printf("   getPushCommKey: %s[OK]%s %s\n", green, reset, val2)
+	fmt.Fprintf(out, "   getPushCommKey: %s[OK]%s %s\n", green, reset, val2)
 	record("Custom Data", "getPushCommKey", true, val2, "", "")
 }
 
@@ -334,46 +420,46 @@ func testRealtimeRegistration(zk *zkteco.ZKTeco) {
 	var eventCount int
 	callback := func(event zkteco.RealTimeEvent) {
 		eventCount++
-		fmt.Printf("     Event: type=%d name=%s user=%s time=%s\n",
+		fmt.Fprintf(out, "     Event: type=%d name=%s user=%s time=%s\n",
 			event.EventType, event.EventName, event.UserID, event.Time.Format("15:04:05"))
 	}
 
-	fmt.Printf("   registerEvents: listening for 3s...\n")
+	fmt.Fprintf(out, "   registerEvents: listening for 3s...\n")
 	err := zk.GetRealTimeLogs(callback, 3*time.Second)
 	if err != nil {
-		fmt.Printf("   registerEvents: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   registerEvents: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Realtime", "registerEvents", false, "", "", err.Error())
 		return
 	}
 
-	fmt.Printf("   registerEvents: %s[OK]%s Received %d events in 3s\n", green, reset, eventCount)
+	fmt.Fprintf(out, "   registerEvents: %s[OK]%s Received %d events in 3s\n", green, reset, eventCount)
 	record("Realtime", "registerEvents", true, fmt.Sprintf("%d events", eventCount), "", "")
 }
 
 func testPasswordAuth() {
 	// Connect with password (separate connection to verify auth flow)
-	fmt.Printf("   Connecting with password=%d...\n", *password)
+	fmt.Fprintf(out, "   Connecting with password=%d...\n", *password)
 	zk2 := zkteco.NewZKTeco(*host, *port,
 		zkteco.WithProtocol(*protocol),
 		zkteco.WithTimeout(*timeout),
 		zkteco.WithPassword(*password),
 	)
 	if err := zk2.Connect(); err != nil {
-		fmt.Printf("   passwordAuth: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   passwordAuth: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Password", "passwordAuth", false, "", "", err.Error())
 		return
 	}
 
 	serial, err := zk2.SerialNumber()
 	if err != nil {
-		fmt.Printf("   passwordAuth: %s[FAIL] could not get serial after auth: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   passwordAuth: %s[FAIL] could not get serial after auth: %s%s\n", red, err, reset)
 		record("Password", "passwordAuth", false, "", "", err.Error())
 		zk2.Disconnect()
 		return
 	}
 	zk2.Disconnect()
 
-	fmt.Printf("   passwordAuth: %s[OK]%s serial=%s\n", green, reset, serial)
+	fmt.Fprintf(out, "   passwordAuth: %s[OK]%s serial=%s\n", green, reset, serial)
 	record("Password", "passwordAuth", true, serial, "", "")
 }
 
@@ -381,7 +467,7 @@ func testTCPMUX() {
 	// Parse tcpmux flag: host:port/subdomain
 	parts := strings.SplitN(*tcpmux, "/", 2)
 	if len(parts) != 2 {
-		fmt.Printf("   tcpmux: %s[FAIL] invalid format, expected host:port/subdomain%s\n", red, reset)
+		fmt.Fprintf(out, "   tcpmux: %s[FAIL] invalid format, expected host:port/subdomain%s\n", red, reset)
 		record("TCPMUX", "tcpmux", false, "", "", "invalid format")
 		return
 	}
@@ -389,7 +475,7 @@ func testTCPMUX() {
 	subdomain := parts[1]
 	hp := strings.SplitN(hostPort, ":", 2)
 	if len(hp) != 2 {
-		fmt.Printf("   tcpmux: %s[FAIL] invalid host:port%s\n", red, reset)
+		fmt.Fprintf(out, "   tcpmux: %s[FAIL] invalid host:port%s\n", red, reset)
 		record("TCPMUX", "tcpmux", false, "", "", "invalid host:port")
 		return
 	}
@@ -397,7 +483,7 @@ func testTCPMUX() {
 	proxyPort := 0
 	fmt.Sscanf(hp[1], "%d", &proxyPort)
 
-	fmt.Printf("   Connecting via TCPMUX proxy %s:%d subdomain=%s...\n", proxyHost, proxyPort, subdomain)
+	fmt.Fprintf(out, "   Connecting via TCPMUX proxy %s:%d subdomain=%s...\n", proxyHost, proxyPort, subdomain)
 	opts := []zkteco.Option{
 		zkteco.WithTCPMUX(proxyHost, proxyPort, subdomain),
 		zkteco.WithTimeout(*timeout),
@@ -407,32 +493,32 @@ func testTCPMUX() {
 	}
 	zk3 := zkteco.NewZKTeco(*host, *port, opts...)
 	if err := zk3.Connect(); err != nil {
-		fmt.Printf("   tcpmux: %s[FAIL] %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   tcpmux: %s[FAIL] %s%s\n", red, err, reset)
 		record("TCPMUX", "tcpmux", false, "", "", err.Error())
 		return
 	}
 
 	serial, err := zk3.SerialNumber()
 	if err != nil {
-		fmt.Printf("   tcpmux: %s[FAIL] could not get serial: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   tcpmux: %s[FAIL] could not get serial: %s%s\n", red, err, reset)
 		record("TCPMUX", "tcpmux", false, "", "", err.Error())
 		zk3.Disconnect()
 		return
 	}
 	zk3.Disconnect()
 
-	fmt.Printf("   tcpmux: %s[OK]%s serial=%s\n", green, reset, serial)
+	fmt.Fprintf(out, "   tcpmux: %s[OK]%s serial=%s\n", green, reset, serial)
 	record("TCPMUX", "tcpmux", true, serial, "", "")
 }
 
 func testDisconnect(zk *zkteco.ZKTeco) {
 	err := zk.Disconnect()
 	if err != nil {
-		fmt.Printf("   disconnect: %s[FAIL] Go error: %s%s\n", red, err, reset)
+		fmt.Fprintf(out, "   disconnect: %s[FAIL] Go error: %s%s\n", red, err, reset)
 		record("Disconnect", "disconnect", false, "", "", err.Error())
 		return
 	}
-	fmt.Printf("   disconnect: %s[OK]%s\n", green, reset)
+	fmt.Fprintf(out, "   disconnect: %s[OK]%s\n", green, reset)
 	record("Disconnect", "disconnect", true, "OK", "", "")
 }
 
@@ -509,29 +595,29 @@ func runPHP(command string) string {
 }
 
 func printSummary() {
-	fmt.Println()
-	fmt.Println("========================================")
-	fmt.Println("         SIDE-BY-SIDE SUMMARY           ")
-	fmt.Println("========================================")
-	fmt.Println()
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "========================================")
+	fmt.Fprintln(out, "         SIDE-BY-SIDE SUMMARY           ")
+	fmt.Fprintln(out, "========================================")
+	fmt.Fprintln(out)
 
 	if failed == 0 {
-		fmt.Printf("  %sPassed:   %d/%d (100%%)%s\n", green, passed, total, reset)
+		fmt.Fprintf(out, "  %sPassed:   %d/%d (100%%)%s\n", green, passed, total, reset)
 	} else {
-		fmt.Printf("  Passed:   %s%d/%d%s\n", green, passed, total, reset)
+		fmt.Fprintf(out, "  Passed:   %s%d/%d%s\n", green, passed, total, reset)
 	}
-	fmt.Printf("  Failed:   %s%d%s\n", red, failed, reset)
-	fmt.Println()
+	fmt.Fprintf(out, "  Failed:   %s%d%s\n", red, failed, reset)
+	fmt.Fprintln(out)
 
 	if failed == 0 {
-		fmt.Printf("  %s[OK] All Go package commands match PHP package behavior!%s\n", green, reset)
+		fmt.Fprintf(out, "  %s[OK] All Go package commands match PHP package behavior!%s\n", green, reset)
 	} else {
-		fmt.Println("  Failed tests:")
+		fmt.Fprintln(out, "  Failed tests:")
 		for _, r := range results {
-			if !r.ok {
-				fmt.Printf("    [FAIL] %s/%s: %s\n", r.group, r.name, r.errMsg)
+			if r.Status == "fail" {
+				fmt.Fprintf(out, "    [FAIL] %s/%s: %s\n", r.Group, r.Name, r.Error)
 			}
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(out)
 }
@@ -1,60 +1,105 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	zkteco "github.com/0mithun/go-zkteco"
 )
 
+var output = flag.String("output", "text", "Output format: text or json (progress goes to stderr)")
+
+// step is the outcome of one feature check.
+type step struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"` // "pass" or "fail"
+	Value      string  `json:"value,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+var (
+	out   io.Writer = os.Stdout
+	steps []step
+)
+
+// run prints the check name, times fn and records its outcome.
+func run(name string, fn func() (string, error)) error {
+	fmt.Fprintf(out, "%d. %s... ", len(steps)+1, name)
+	start := time.Now()
+	val, err := fn()
+	s := step{Name: name, Status: "pass", Value: val, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+	if err != nil {
+		s.Status, s.Error = "fail", err.Error()
+		fmt.Fprintf(out, "FAIL: %s\n", err)
+	} else if val != "" {
+		fmt.Fprintf(out, "OK: %s\n", val)
+	} else {
+		fmt.Fprintln(out, "OK")
+	}
+	steps = append(steps, s)
+	return err
+}
+
 func main() {
-	fmt.Println("=== Testing New Features ===")
+	flag.Parse()
+	switch *output {
+	case "text":
+	case "json":
+		out = os.Stderr
+	default:
+		fmt.Fprintf(os.Stderr, "invalid -output %q: want text or json\n", *output)
+		os.Exit(2)
+	}
 
-	fmt.Print("1. Connect... ")
+	fmt.Fprintln(out, "=== Testing New Features ===")
 	zk := zkteco.NewZKTeco("frp.utso.app", 7001,
 		zkteco.WithProtocol("tcp"),
 		zkteco.WithTimeout(30),
 	)
-	if err := zk.Connect(); err != nil {
-		fmt.Printf("FAIL: %s\n", err)
-		return
+	if run("Connect", func() (string, error) { return "", zk.Connect() }) == nil {
+		runChecks(zk)
 	}
-	fmt.Println("OK")
+	fmt.Fprintln(out, "\n=== Done ===")
 
-	fmt.Print("2. Memory info... ")
-	info, err := zk.GetMemoryInfo()
-	if err != nil {
-		fmt.Printf("FAIL: %s\n", err)
-	} else {
-		fmt.Printf("OK: admin=%d users=%d userCap=%d logs=%d logCap=%d\n",
-			info.AdminCount, info.UserCount, info.UserCapacity, info.LogCount, info.LogCapacity)
+	failed := false
+	for _, s := range steps {
+		failed = failed || s.Status == "fail"
 	}
-
-	fmt.Print("3. Serial... ")
-	serial, err := zk.SerialNumber()
-	if err != nil {
-		fmt.Printf("FAIL: %s\n", err)
-	} else {
-		fmt.Printf("OK: %s\n", serial)
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(steps)
 	}
-
-	fmt.Print("4. Realtime (3s)... ")
-	var ec int
-	err = zk.GetRealTimeLogs(func(e zkteco.RealTimeEvent) {
-		ec++
-		fmt.Printf("\n   Event: %s user=%s", e.EventName, e.UserID)
-	}, 3*time.Second)
-	if err != nil {
-		fmt.Printf("FAIL: %s\n", err)
-	} else {
-		fmt.Printf("OK: %d events\n", ec)
+	if failed {
+		os.Exit(1)
 	}
+}
 
-	fmt.Print("5. Disconnect... ")
-	if err := zk.Disconnect(); err != nil {
-		fmt.Printf("FAIL: %s\n", err)
-	} else {
-		fmt.Println("OK")
-	}
-	fmt.Println("\n=== Done ===")
+func runChecks(zk *zkteco.ZKTeco) {
+	run("Memory info", func() (string, error) {
+		info, err := zk.GetMemoryInfo()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("admin=%d users=%d userCap=%d logs=%d logCap=%d",
+			info.AdminCount, info.UserCount, info.UserCapacity, info.LogCount, info.LogCapacity), nil
+	})
+
+	run("Serial", zk.SerialNumber)
+
+	run("Realtime (3s)", func() (string, error) {
+		var ec int
+		err := zk.GetRealTimeLogs(func(e zkteco.RealTimeEvent) {
+			ec++
+			fmt.Fprintf(out, "\n   Event: %s user=%s", e.EventName, e.UserID)
+		}, 3*time.Second)
+		return fmt.Sprintf("%d events", ec), err
+	})
+
+	run("Disconnect", func() (string, error) { return "", zk.Disconnect() })
 }
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	zkteco "github.com/0mithun/go-zkteco"
+	"github.com/0mithun/go-zkteco/fleet"
+)
+
+// probe is the state of one device read in a probe round.
+type probe struct {
+	device   fleet.Device
+	err      error
+	memory   *zkteco.MemoryInfo
+	drift    time.Duration
+	duration time.Duration
+	at       time.Time
+}
+
+// exporter probes the devices of store and serves the last round as metrics.
+type exporter struct {
+	store       fleet.Store
+	concurrency int
+	clientOpts  []zkteco.Option
+
+	mu       sync.Mutex
+	probes   []probe
+	loadErr  error
+	rounds   int
+	lastDone time.Time
+}
+
+// run probes the devices every interval until ctx is canceled.
+func (e *exporter) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.probeAll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeAll re-reads the devices and probes each of them, concurrency at a
+// time.
+func (e *exporter) probeAll(ctx context.Context) {
+	devices, err := e.store.List()
+	if err != nil {
+		log.Printf("load devices: %s", err)
+		e.mu.Lock()
+		e.loadErr = err
+		e.mu.Unlock()
+		return
+	}
+
+	probes := make([]probe, len(devices))
+	sem := make(chan struct{}, max(e.concurrency, 1))
+	var wg sync.WaitGroup
+	for i, d := range devices {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		wg.Add(1)
+		go func(i int, d fleet.Device) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			probes[i] = e.probe(d)
+		}(i, d)
+	}
+	wg.Wait()
+
+	e.mu.Lock()
+	e.probes = probes
+	e.loadErr = nil
+	e.rounds++
+	e.lastDone = time.Now()
+	e.mu.Unlock()
+}
+
+// probe connects to d and reads its memory usage and clock.
+func (e *exporter) probe(d fleet.Device) (p probe) {
+	p = probe{device: d, at: time.Now()}
+	defer func() {
+		p.duration = time.Since(p.at)
+		if p.err != nil {
+			log.Printf("%s %s (%s): %s", d.Tenant, d.Serial, d.Address, p.err)
+		}
+	}()
+
+	zk, err := d.Client(e.clientOpts...)
+	if err != nil {
+		p.err = err
+		return p
+	}
+	if p.err = zk.Connect(); p.err != nil {
+		return p
+	}
+	defer zk.Disconnect()
+
+	if p.memory, p.err = zk.GetMemoryInfo(); p.err != nil {
+		return p
+	}
+	deviceTime, err := zk.GetTime()
+	if err != nil {
+		p.err = err
+		return p
+	}
+	p.drift = deviceTime.Sub(time.Now())
+	return p
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	probes, loadErr, rounds, lastDone := e.probes, e.loadErr, e.rounds, e.lastDone
+	e.mu.Unlock()
+
+	var buf bytes.Buffer
+	m := metricWriter{w: &buf}
+
+	m.header("zkteco_exporter_rounds_total", "counter", "Probe rounds completed.")
+	m.sample("zkteco_exporter_rounds_total", nil, float64(rounds))
+	m.header("zkteco_exporter_last_round_timestamp_seconds", "gauge", "Time the last probe round completed.")
+	m.sample("zkteco_exporter_last_round_timestamp_seconds", nil, unixSeconds(lastDone))
+	m.header("zkteco_exporter_devices_file_ok", "gauge", "Whether the devices file could be read in the last round.")
+	m.sample("zkteco_exporter_devices_file_ok", nil, boolValue(loadErr == nil))
+
+	gauges := []struct {
+		name, help string
+		value      func(p probe) (float64, bool)
+	}{
+		{"zkteco_up", "Whether the device could be reached and probed.", func(p probe) (float64, bool) {
+			return boolValue(p.err == nil), true
+		}},
+		{"zkteco_probe_duration_seconds", "Time taken to probe the device.", func(p probe) (float64, bool) {
+			return p.duration.Seconds(), true
+		}},
+		{"zkteco_users", "Users enrolled on the device.", memoryValue(func(m *zkteco.MemoryInfo) int { return m.UserCount })},
+		{"zkteco_user_capacity", "Users the device can hold.", memoryValue(func(m *zkteco.MemoryInfo) int { return m.UserCapacity })},
+		{"zkteco_attendance_records", "Attendance records stored on the device.", memoryValue(func(m *zkteco.MemoryInfo) int { return m.LogCount })},
+		{"zkteco_attendance_capacity", "Attendance records the device can hold.", memoryValue(func(m *zkteco.MemoryInfo) int { return m.LogCapacity })},
+		{"zkteco_fingerprints", "Fingerprint templates enrolled on the device.", memoryValue(func(m *zkteco.MemoryInfo) int { return m.FingerprintCount })},
+		{"zkteco_clock_drift_seconds", "Device clock minus exporter clock.", func(p probe) (float64, bool) {
+			return p.drift.Seconds(), p.err == nil
+		}},
+		{"zkteco_last_probe_timestamp_seconds", "Time the device was last probed.", func(p probe) (float64, bool) {
+			return unixSeconds(p.at), true
+		}},
+	}
+	for _, g := range gauges {
+		m.header(g.name, "gauge", g.help)
+		for _, p := range probes {
+			if v, ok := g.value(p); ok {
+				m.sample(g.name, deviceLabels(p.device), v)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// memoryValue returns a metric value read from the memory info of a probe.
+func memoryValue(get func(m *zkteco.MemoryInfo) int) func(p probe) (float64, bool) {
+	return func(p probe) (float64, bool) {
+		if p.memory == nil {
+			return 0, false
+		}
+		return float64(get(p.memory)), true
+	}
+}
+
+func deviceLabels(d fleet.Device) [][2]string {
+	return [][2]string{{"tenant", d.Tenant}, {"serial", d.Serial}, {"address", d.Address}}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func unixSeconds(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
+
+// metricWriter writes the Prometheus text exposition format.
+type metricWriter struct {
+	w *bytes.Buffer
+}
+
+func (m metricWriter) header(name, typ, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (m metricWriter) sample(name string, labels [][2]string, value float64) {
+	m.w.WriteString(name)
+	if len(labels) > 0 {
+		m.w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				m.w.WriteByte(',')
+			}
+			fmt.Fprintf(m.w, "%s=\"%s\"", l[0], labelEscaper.Replace(l[1]))
+		}
+		m.w.WriteByte('}')
+	}
+	fmt.Fprintf(m.w, " %g\n", value)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,60 @@
+// Command zk-exporter probes the devices of a fleet registry file on an
+// interval and serves the results as Prometheus metrics.
+//
+// Usage:
+//
+//	zk-exporter -devices devices.json [-listen :9370] [-interval 1m]
+//
+// The devices file is the JSON file of a fleet.FileStore, re-read before
+// every probe round so devices can be added without a restart. Each round
+// connects to every device and reads its memory usage and clock; metrics are
+// labelled with the tenant, serial number and address of the device.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	zkteco "github.com/0mithun/go-zkteco"
+	"github.com/0mithun/go-zkteco/fleet"
+)
+
+func main() {
+	devices := flag.String("devices", "devices.json", "Fleet registry file (JSON list of devices)")
+	listen := flag.String("listen", ":9370", "Address to serve /metrics on")
+	interval := flag.Duration("interval", time.Minute, "Time between probe rounds")
+	timeout := flag.Int("timeout", 10, "Device timeout in seconds")
+	concurrency := flag.Int("concurrency", 10, "Devices probed at once")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	e := &exporter{
+		store:       fleet.NewFileStore(*devices),
+		concurrency: *concurrency,
+		clientOpts:  []zkteco.Option{zkteco.WithTimeout(*timeout)},
+	}
+	go e.run(ctx, *interval)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	srv := &http.Server{Addr: *listen, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	log.Printf("serving metrics of %s on %s", *devices, *listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "zk-exporter: %s\n", err)
+		os.Exit(1)
+	}
+}
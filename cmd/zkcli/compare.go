@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	zkteco "github.com/0mithun/go-zkteco"
+)
+
+func runCompare(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	var dev deviceFlags
+	dev.register(fs)
+	with := fs.String("with", "", "Second device, host or host:port (other connection flags are shared)")
+	withPassword := fs.Int("with-password", -1, "Password of the second device (default -password)")
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Parse(args)
+
+	if *with == "" {
+		return fmt.Errorf("-with is required")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("invalid -format %q", *format)
+	}
+	other := dev
+	other.host = *with
+	if host, port, err := net.SplitHostPort(*with); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("invalid -with %q: bad port", *with)
+		}
+		other.host, other.port = host, p
+	}
+	if *withPassword >= 0 {
+		other.password = *withPassword
+	}
+
+	a, err := dev.connect()
+	if err != nil {
+		return err
+	}
+	defer a.Disconnect()
+	b, err := other.connect()
+	if err != nil {
+		return err
+	}
+	defer b.Disconnect()
+
+	diff, err := zkteco.CompareDevices(a, b)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diff); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(diff)
+	}
+	if !diff.Converged() {
+		return errors.New("devices differ")
+	}
+	if *format == "text" {
+		fmt.Println("devices match")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	zkteco "github.com/0mithun/go-zkteco"
+)
+
+// deviceFlags are the connection flags shared by all commands.
+type deviceFlags struct {
+	host     string
+	port     int
+	protocol string
+	timeout  int
+	password int
+	tcpmux   string
+}
+
+func (d *deviceFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&d.host, "host", "192.168.1.201", "Device host")
+	fs.IntVar(&d.port, "port", 4370, "Device port")
+	fs.StringVar(&d.protocol, "protocol", "tcp", "Protocol: tcp or udp")
+	fs.IntVar(&d.timeout, "timeout", 25, "Timeout in seconds")
+	fs.IntVar(&d.password, "password", 0, "Device password (0=none)")
+	fs.StringVar(&d.tcpmux, "tcpmux", "", "TCPMUX proxy host:port/subdomain (e.g. example.com:1337/zkteco)")
+}
+
+// connect creates a client from the flags and connects it.
+func (d *deviceFlags) connect() (*zkteco.ZKTeco, error) {
+	opts := []zkteco.Option{
+		zkteco.WithProtocol(d.protocol),
+		zkteco.WithTimeout(d.timeout),
+	}
+	if d.password > 0 {
+		opts = append(opts, zkteco.WithPassword(d.password))
+	}
+	if d.tcpmux != "" {
+		host, port, sub, err := parseTCPMUX(d.tcpmux)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, zkteco.WithTCPMUX(host, port, sub))
+	}
+
+	zk := zkteco.NewZKTeco(d.host, d.port, opts...)
+	if err := zk.Connect(); err != nil {
+		return nil, err
+	}
+	return zk, nil
+}
+
+// parseTCPMUX parses "host:port/subdomain".
+func parseTCPMUX(s string) (string, int, string, error) {
+	addr, sub, ok := strings.Cut(s, "/")
+	if !ok || sub == "" {
+		return "", 0, "", fmt.Errorf("invalid -tcpmux %q: want host:port/subdomain", s)
+	}
+	idx := strings.LastIndex(addr, ":")
+	if idx < 0 {
+		return "", 0, "", fmt.Errorf("invalid -tcpmux %q: missing port", s)
+	}
+	port, err := strconv.Atoi(addr[idx+1:])
+	if err != nil {
+		return "", 0, "", fmt.Errorf("invalid -tcpmux %q: bad port", s)
+	}
+	return addr[:idx], port, sub, nil
+}
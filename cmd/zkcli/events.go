@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	zkteco "github.com/0mithun/go-zkteco"
+)
+
+var eventFlags = map[string]int{
+	"attlog":       zkteco.EF_ATTLOG,
+	"finger":       zkteco.EF_FINGER,
+	"enrolluser":   zkteco.EF_ENROLLUSER,
+	"enrollfinger": zkteco.EF_ENROLLFINGER,
+	"button":       zkteco.EF_BUTTON,
+	"unlock":       zkteco.EF_UNLOCK,
+	"card":         zkteco.EF_HIDNUM,
+	"verify":       zkteco.EF_VERIFY,
+	"fpftr":        zkteco.EF_FPFTR,
+	"alarm":        zkteco.EF_ALARM,
+	"doorclose":    zkteco.EF_DOOR_CLOSE,
+	"exitbutton":   zkteco.EF_EXIT_BUTTON,
+	"remoteopen":   zkteco.EF_REMOTE_OPEN,
+}
+
+func runEventsTail(args []string) error {
+	fs := flag.NewFlagSet("events tail", flag.ExitOnError)
+	var dev deviceFlags
+	dev.register(fs)
+	events := fs.String("events", "attlog", "Comma-separated events: "+eventNames())
+	format := fs.String("format", "table", "Output format: table or json")
+	follow := fs.Bool("follow", false, "Keep listening until interrupted")
+	duration := fs.Duration("duration", 30*time.Second, "How long to listen (ignored with -follow)")
+	fs.Parse(args)
+
+	mask, err := parseEventMask(*events)
+	if err != nil {
+		return err
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("invalid -format %q", *format)
+	}
+
+	zk, err := dev.connect()
+	if err != nil {
+		return err
+	}
+	defer zk.Disconnect()
+
+	timeout := *duration
+	if *follow {
+		timeout = 0
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	return zk.ListenEvents(func(e zkteco.RealTimeEvent) error {
+		if *format == "json" {
+			return enc.Encode(e.Document())
+		}
+		fmt.Println(formatEvent(e))
+		return nil
+	}, mask, timeout)
+}
+
+func formatEvent(e zkteco.RealTimeEvent) string {
+	line := fmt.Sprintf("%s  %-14s", e.Time.Format("2006-01-02 15:04:05"), e.EventName)
+	if e.UserID != "" {
+		line += "  user=" + e.UserID
+	}
+	switch e.EventType {
+	case zkteco.EF_ATTLOG:
+		line += "  state=" + zkteco.StateName(e.State) + "  type=" + zkteco.TypeName(e.Type)
+	case zkteco.EF_FINGER, zkteco.EF_ENROLLFINGER, zkteco.EF_FPFTR:
+		line += fmt.Sprintf("  finger=%d", e.FingerIndex)
+	case zkteco.EF_VERIFY:
+		line += fmt.Sprintf("  verified=%t code=%d score=%d", e.Verified, e.VerifyCode, e.Score)
+	case zkteco.EF_BUTTON:
+		line += fmt.Sprintf("  button=%d", e.ButtonID)
+	case zkteco.EF_UNLOCK:
+		line += fmt.Sprintf("  door=%d type=%d", e.DoorID, e.UnlockType)
+	case zkteco.EF_HIDNUM:
+		line += fmt.Sprintf("  card=%d", e.CardNo)
+	case zkteco.EF_ALARM:
+		line += fmt.Sprintf("  alarm=%d", e.AlarmType)
+	}
+	return line
+}
+
+func parseEventMask(s string) (int, error) {
+	mask := 0
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if name == "all" {
+			for _, flag := range eventFlags {
+				mask |= flag
+			}
+			continue
+		}
+		flag, ok := eventFlags[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown event %q", name)
+		}
+		mask |= flag
+	}
+	if mask == 0 {
+		return 0, fmt.Errorf("no events selected")
+	}
+	return mask, nil
+}
+
+func eventNames() string {
+	return "attlog, finger, enrolluser, enrollfinger, button, unlock, card, verify, fpftr, alarm, doorclose, exitbutton, remoteopen, all"
+}
@@ -0,0 +1,79 @@
+// Command zkcli is a command-line tool for ZKTeco devices.
+//
+// Usage:
+//
+//	zkcli <command> <subcommand> [flags]
+//
+// Commands:
+//
+//	compare       diff the users and templates of two devices
+//	events tail   print real-time events as they happen
+//	proxy         share one device connection between several clients
+//	raw           send an arbitrary command and dump the reply
+//	selftest      run the device self-tests (sensor, clock, voice)
+//	shell         interactive console on one device connection
+//	users import  sync the device users with a CSV or JSON file
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type command struct {
+	name string
+	help string
+	run  func(args []string) error
+}
+
+var commands = []command{
+	{"compare", "diff the users and templates of two devices", runCompare},
+	{"events tail", "print real-time events as they happen", runEventsTail},
+	{"proxy", "share one device connection between several clients", runProxy},
+	{"raw", "send an arbitrary command and dump the reply", runRaw},
+	{"selftest", "run the device self-tests (sensor, clock, voice)", runSelfTest},
+	{"shell", "interactive console on one device connection", runShell},
+	{"users import", "sync the device users with a CSV or JSON file", runUsersImport},
+}
+
+func main() {
+	args := os.Args[1:]
+	for _, cmd := range commands {
+		if matched, rest := matchCommand(cmd.name, args); matched {
+			if err := cmd.run(rest); err != nil {
+				fmt.Fprintf(os.Stderr, "zkcli %s: %s\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+// matchCommand reports whether args start with the words of name and
+// returns the remaining arguments.
+func matchCommand(name string, args []string) (bool, []string) {
+	words := strings.Fields(name)
+	if len(args) < len(words) {
+		return false, nil
+	}
+	for i, w := range words {
+		if args[i] != w {
+			return false, nil
+		}
+	}
+	return true, args[len(words):]
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: zkcli <command> [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", cmd.name, cmd.help)
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'zkcli <command> -h' for the command flags.")
+}
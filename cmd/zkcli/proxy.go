@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	zkteco "github.com/0mithun/go-zkteco"
+)
+
+func runProxy(args []string) error {
+	fs := flag.NewFlagSet("proxy", flag.ExitOnError)
+	var dev deviceFlags
+	dev.register(fs)
+	listen := fs.String("listen", ":4370", "Address clients connect to (TCP)")
+	clientPassword := fs.Int("client-password", 0, "Password clients must authenticate with (0=none)")
+	idle := fs.Duration("idle", 5*time.Minute, "Disconnect clients idle for this long (0=never)")
+	fs.Parse(args)
+
+	zk, err := dev.connect()
+	if err != nil {
+		return err
+	}
+
+	proxy := zkteco.NewProxy(zk,
+		zkteco.WithProxyPassword(*clientPassword),
+		zkteco.WithProxyIdleTimeout(*idle),
+	)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		proxy.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "proxying %s:%d on %s\n", dev.host, dev.port, *listen)
+	if err := proxy.ListenAndServe(*listen); err != nil && !errors.Is(err, net.ErrClosed) {
+		proxy.Close()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"strconv"
+
+	zkteco "github.com/0mithun/go-zkteco"
+	"github.com/0mithun/go-zkteco/protocol"
+)
+
+func runRaw(args []string) error {
+	fs := flag.NewFlagSet("raw", flag.ExitOnError)
+	var dev deviceFlags
+	dev.register(fs)
+	cmdFlag := fs.String("cmd", "", "Command code, decimal or 0x-prefixed hex (e.g. 0x000B)")
+	data := fs.String("data", "", "Command data as a string (e.g. ~SerialNumber)")
+	hexData := fs.String("hex", "", "Command data as hex bytes, instead of -data")
+	fs.Parse(args)
+
+	if *cmdFlag == "" {
+		return fmt.Errorf("-cmd is required")
+	}
+	cmd, err := strconv.ParseUint(*cmdFlag, 0, 16)
+	if err != nil {
+		return fmt.Errorf("invalid -cmd %q: %w", *cmdFlag, err)
+	}
+	payload := []byte(*data)
+	if *hexData != "" {
+		if *data != "" {
+			return fmt.Errorf("-data and -hex are exclusive")
+		}
+		if payload, err = hex.DecodeString(*hexData); err != nil {
+			return fmt.Errorf("invalid -hex: %w", err)
+		}
+	}
+
+	zk, err := dev.connect()
+	if err != nil {
+		return err
+	}
+	defer zk.Disconnect()
+
+	reply, large, err := zk.RawCommand(uint16(cmd), payload)
+	if err != nil {
+		return err
+	}
+	pkt, err := protocol.ParsePacket(reply)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("command    %s %d (0x%04X)\n", zkteco.CommandName(pkt.Command), pkt.Command, pkt.Command)
+	fmt.Printf("checksum   0x%04X\n", pkt.Checksum)
+	fmt.Printf("session    %d\n", pkt.SessionID)
+	fmt.Printf("reply ID   %d\n", pkt.ReplyID)
+	fmt.Printf("data       %d bytes %q\n", len(pkt.Data), pkt.Data)
+	fmt.Println()
+	fmt.Print(hex.Dump(reply))
+	if large != nil {
+		fmt.Printf("\nlarge data %d bytes\n", len(large))
+		fmt.Print(hex.Dump(large))
+	}
+	return nil
+}
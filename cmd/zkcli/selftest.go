@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// selfTestCheck is a check of the -format json output of selftest.
+type selfTestCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "pass" or "fail"
+	Error  string `json:"error,omitempty"`
+}
+
+func newSelfTestCheck(name string, err error) selfTestCheck {
+	if err != nil {
+		return selfTestCheck{Name: name, Status: "fail", Error: err.Error()}
+	}
+	return selfTestCheck{Name: name, Status: "pass"}
+}
+
+func runSelfTest(args []string) error {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	var dev deviceFlags
+	dev.register(fs)
+	sweep := fs.Int("voice-sweep", -1, "Also play voice prompts 0 to N, one by one (-1 = none)")
+	pause := fs.Duration("voice-pause", 2*time.Second, "Pause between voice prompts of the sweep")
+	format := fs.String("format", "text", "Output format: text or json")
+	fs.Parse(args)
+
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("invalid -format %q", *format)
+	}
+
+	zk, err := dev.connect()
+	if err != nil {
+		return err
+	}
+	defer zk.Disconnect()
+
+	start := time.Now()
+	result := zk.RunSelfTest()
+	checks := []selfTestCheck{
+		newSelfTestCheck("sensor", result.Sensor),
+		newSelfTestCheck("clock", result.Clock),
+		newSelfTestCheck("voice", result.Voice),
+	}
+	if *format == "text" {
+		fmt.Print(result)
+		if !result.DeviceTime.IsZero() {
+			fmt.Printf("device time %s\n", result.DeviceTime.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	failed := 0
+	for i := 0; i <= *sweep; i++ {
+		err := zk.TestVoice(i)
+		checks = append(checks, newSelfTestCheck(fmt.Sprintf("voice %d", i), err))
+		if err != nil {
+			failed++
+		}
+		if *format == "text" {
+			if err != nil {
+				fmt.Printf("voice %d FAIL: %s\n", i, err)
+			} else {
+				fmt.Printf("voice %d played\n", i)
+			}
+		}
+		if i < *sweep {
+			time.Sleep(*pause)
+		}
+	}
+
+	if *format == "json" {
+		doc := struct {
+			Host       string          `json:"host"`
+			DeviceTime *time.Time      `json:"device_time,omitempty"`
+			DurationMS int64           `json:"duration_ms"`
+			Checks     []selfTestCheck `json:"checks"`
+		}{Host: dev.host, DurationMS: time.Since(start).Milliseconds(), Checks: checks}
+		if !result.DeviceTime.IsZero() {
+			doc.DeviceTime = &result.DeviceTime
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+
+	if !result.Passed() || failed > 0 {
+		return errors.New("self-test failed")
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	zkteco "github.com/0mithun/go-zkteco"
+	"golang.org/x/term"
+)
+
+// shellCommand is a command of the zkcli shell.
+type shellCommand struct {
+	usage string
+	help  string
+	subs  []string // completed second words
+	run   func(s *shell, args []string) error
+}
+
+var shellCommands map[string]shellCommand
+
+func init() {
+	// Set in init as the help command refers to the table.
+	shellCommands = map[string]shellCommand{
+		"info":      {"info", "device identity and memory usage", nil, (*shell).info},
+		"users":     {"users", "list the enrolled users", nil, (*shell).users},
+		"att":       {"att [n]", "show the last n attendance records (default 20)", nil, (*shell).att},
+		"time":      {"time [set now|<RFC 3339 time>]", "show or set the device clock", []string{"set"}, (*shell).time},
+		"option":    {"option get <key> | option set <key> <value>", "read or write a device option", []string{"get", "set"}, (*shell).option},
+		"unlock":    {"unlock [seconds]", "open the door lock (default 3 seconds)", nil, (*shell).unlock},
+		"reconnect": {"reconnect", "drop the connection and connect again", nil, (*shell).reconnect},
+		"help":      {"help", "list the commands", nil, (*shell).help},
+		"exit":      {"exit", "disconnect and leave the shell", nil, nil},
+	}
+}
+
+// errExit ends the shell.
+var errExit = errors.New("exit")
+
+type shell struct {
+	dev deviceFlags
+	zk  *zkteco.ZKTeco
+	out io.Writer
+}
+
+func runShell(args []string) error {
+	fs := flag.NewFlagSet("shell", flag.ExitOnError)
+	s := &shell{out: os.Stdout}
+	s.dev.register(fs)
+	fs.Parse(args)
+
+	zk, err := s.dev.connect()
+	if err != nil {
+		return err
+	}
+	s.zk = zk
+	defer func() { s.zk.Disconnect() }()
+
+	prompt := s.dev.host + "> "
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return s.runLines(bufio.NewScanner(os.Stdin))
+	}
+
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, prompt)
+	t.AutoCompleteCallback = complete
+	s.out = t
+	fmt.Fprintf(t, "Connected to %s. Type 'help' for the commands, Tab to complete.\n", s.dev.host)
+	for {
+		line, err := t.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := s.exec(line); err != nil {
+			if err == errExit {
+				return nil
+			}
+			fmt.Fprintf(t, "error: %s\n", err)
+		}
+	}
+}
+
+// runLines runs the commands read from a pipe, stopping at the first error.
+func (s *shell) runLines(scanner *bufio.Scanner) error {
+	for scanner.Scan() {
+		if err := s.exec(scanner.Text()); err != nil {
+			if err == errExit {
+				return nil
+			}
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// exec runs one command line.
+func (s *shell) exec(line string) error {
+	args := strings.Fields(line)
+	if len(args) == 0 {
+		return nil
+	}
+	cmd, ok := shellCommands[args[0]]
+	switch {
+	case args[0] == "quit" || args[0] == "exit":
+		return errExit
+	case !ok:
+		return fmt.Errorf("unknown command %q, try 'help'", args[0])
+	}
+	return cmd.run(s, args[1:])
+}
+
+// complete is the Tab handler of the terminal: it completes the command
+// word, or the subcommand word after "time" and "option", when the prefix
+// matches a single candidate. Ambiguous prefixes are left alone.
+func complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' || pos != len(line) {
+		return "", 0, false
+	}
+	words := strings.Fields(line)
+	if strings.HasSuffix(line, " ") || len(words) == 0 {
+		words = append(words, "")
+	}
+
+	var candidates []string
+	switch len(words) {
+	case 1:
+		for name := range shellCommands {
+			candidates = append(candidates, name)
+		}
+	case 2:
+		candidates = shellCommands[words[0]].subs
+	}
+	prefix := words[len(words)-1]
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) != 1 {
+		return "", 0, false
+	}
+	completed := line[:len(line)-len(prefix)] + matches[0] + " "
+	return completed, len(completed), true
+}
+
+func (s *shell) help([]string) error {
+	names := make([]string, 0, len(shellCommands))
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", shellCommands[name].usage, shellCommands[name].help)
+	}
+	return w.Flush()
+}
+
+func (s *shell) info([]string) error {
+	info, err := s.zk.GetDeviceInfo()
+	if err != nil {
+		return err
+	}
+	mem, err := s.zk.GetMemoryInfo()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "serial\t%s\n", info.SerialNumber)
+	fmt.Fprintf(w, "name\t%s\n", info.DeviceName)
+	fmt.Fprintf(w, "platform\t%s\n", info.Platform)
+	fmt.Fprintf(w, "firmware\t%s\n", info.FirmwareVersion)
+	fmt.Fprintf(w, "users\t%d / %d\n", mem.UserCount, mem.UserCapacity)
+	fmt.Fprintf(w, "fingerprints\t%d / %d\n", mem.FingerprintCount, mem.FingerprintCapacity)
+	fmt.Fprintf(w, "records\t%d / %d\n", mem.LogCount, mem.LogCapacity)
+	return w.Flush()
+}
+
+func (s *shell) users([]string) error {
+	users, err := s.zk.GetUsers()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "UID\tUSER ID\tNAME\tROLE\tCARD")
+	for _, u := range users {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\n", u.UID, u.UserID, u.Name, u.Role, u.CardNo)
+	}
+	fmt.Fprintf(w, "%d users\n", len(users))
+	return w.Flush()
+}
+
+func (s *shell) att(args []string) error {
+	n := 20
+	if len(args) > 0 {
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid count %q", args[0])
+		}
+		n = v
+	}
+	records, err := s.zk.GetAttendances()
+	if err != nil {
+		return err
+	}
+	total := len(records)
+	records = records[max(0, total-n):]
+
+	w := tabwriter.NewWriter(s.out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tUSER ID\tSTATE\tTYPE")
+	for _, a := range records {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\n", a.RecordTime.Format("2006-01-02 15:04:05"), a.UserID, a.State, a.Type)
+	}
+	fmt.Fprintf(w, "%d of %d records\n", len(records), total)
+	return w.Flush()
+}
+
+func (s *shell) time(args []string) error {
+	if len(args) == 0 {
+		t, err := s.zk.GetTime()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "%s (drift %s)\n", t.Format("2006-01-02 15:04:05"), t.Sub(time.Now()).Round(time.Second))
+		return nil
+	}
+	if args[0] != "set" || len(args) != 2 {
+		return fmt.Errorf("usage: %s", shellCommands["time"].usage)
+	}
+	t := time.Now()
+	if args[1] != "now" {
+		var err error
+		if t, err = time.Parse(time.RFC3339, args[1]); err != nil {
+			return fmt.Errorf("invalid time %q: %w", args[1], err)
+		}
+	}
+	if err := s.zk.SetTime(t); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "clock set to %s\n", t.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+func (s *shell) option(args []string) error {
+	switch {
+	case len(args) == 2 && args[0] == "get":
+		value, err := s.zk.GetDeviceData(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "%s=%s\n", args[1], value)
+		return nil
+	case len(args) >= 3 && args[0] == "set":
+		value := strings.Join(args[2:], " ")
+		if err := s.zk.SetDeviceData(args[1], value); err != nil {
+			return err
+		}
+		fmt.Fprintf(s.out, "%s=%s\n", args[1], value)
+		return nil
+	}
+	return fmt.Errorf("usage: %s", shellCommands["option"].usage)
+}
+
+func (s *shell) unlock(args []string) error {
+	seconds := 3.0
+	if len(args) > 0 {
+		v, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid duration %q", args[0])
+		}
+		seconds = v
+	}
+	if err := s.zk.UnlockDoor(time.Duration(seconds * float64(time.Second))); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.out, "door unlocked for %gs\n", seconds)
+	return nil
+}
+
+func (s *shell) reconnect([]string) error {
+	s.zk.Disconnect()
+	return s.zk.Connect()
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	zkteco "github.com/0mithun/go-zkteco"
+)
+
+func runUsersImport(args []string) error {
+	fs := flag.NewFlagSet("users import", flag.ExitOnError)
+	var dev deviceFlags
+	dev.register(fs)
+	file := fs.String("file", "", "CSV or JSON file with the users (columns/fields: user_id, name, password, role, card_no, uid)")
+	format := fs.String("format", "", "File format: csv or json (default from the file extension)")
+	remove := fs.Bool("delete", false, "Remove device users missing from the file")
+	yes := fs.Bool("yes", false, "Apply the changes instead of only showing them")
+	fs.Parse(args)
+
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+	users, err := readUsersFile(*file, *format)
+	if err != nil {
+		return err
+	}
+	if err := validateUsers(users); err != nil {
+		return err
+	}
+
+	zk, err := dev.connect()
+	if err != nil {
+		return err
+	}
+	defer zk.Disconnect()
+
+	changes, err := zk.DiffUsers(users, *remove)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		fmt.Println("device is up to date")
+		return nil
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if !*yes {
+		fmt.Printf("\n%d changes planned; run with -yes to apply them\n", len(changes))
+		return nil
+	}
+
+	applied, err := zk.ApplyUserChanges(changes)
+	fmt.Printf("\n%d of %d changes applied\n", applied, len(changes))
+	return err
+}
+
+// readUsersFile reads users from a CSV file with a header row, or a JSON
+// array of users.
+func readUsersFile(path, format string) ([]zkteco.User, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch format {
+	case "json":
+		var users []zkteco.User
+		if err := json.NewDecoder(f).Decode(&users); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return users, nil
+	case "csv":
+		users, err := readUsersCSV(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return users, nil
+	default:
+		return nil, fmt.Errorf("invalid -format %q: want csv or json", format)
+	}
+}
+
+func readUsersCSV(r io.Reader) ([]zkteco.User, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	cols := make(map[string]int, len(header))
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := cols["user_id"]; !ok {
+		return nil, fmt.Errorf("header: missing user_id column")
+	}
+
+	var users []zkteco.User
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return users, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		number := func(name string) (int, error) {
+			s := field(name)
+			if s == "" {
+				return 0, nil
+			}
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				return 0, fmt.Errorf("line %d: invalid %s %q", line, name, s)
+			}
+			return n, nil
+		}
+
+		u := zkteco.User{UserID: field("user_id"), Name: field("name"), Password: field("password")}
+		if u.UID, err = number("uid"); err != nil {
+			return nil, err
+		}
+		if u.CardNo, err = number("card_no"); err != nil {
+			return nil, err
+		}
+		switch role := strings.ToLower(field("role")); role {
+		case "", "user", "0":
+			u.Role = zkteco.LEVEL_USER
+		case "admin", "14":
+			u.Role = zkteco.LEVEL_ADMIN
+		default:
+			return nil, fmt.Errorf("line %d: invalid role %q: want user or admin", line, role)
+		}
+		users = append(users, u)
+	}
+}
+
+// validateUsers checks the users against the limits of the device records.
+func validateUsers(users []zkteco.User) error {
+	var errs []error
+	seen := make(map[string]bool, len(users))
+	for i, u := range users {
+		where := fmt.Sprintf("user %d (%s)", i+1, u.UserID)
+		switch {
+		case u.UserID == "":
+			errs = append(errs, fmt.Errorf("user %d: empty user_id", i+1))
+		case len(u.UserID) > 9:
+			errs = append(errs, fmt.Errorf("%s: user_id longer than 9 characters", where))
+		case seen[u.UserID]:
+			errs = append(errs, fmt.Errorf("%s: duplicate user_id", where))
+		}
+		seen[u.UserID] = true
+		if len(u.Name) > 24 {
+			errs = append(errs, fmt.Errorf("%s: name longer than 24 characters", where))
+		}
+		if len(u.Password) > 8 {
+			errs = append(errs, fmt.Errorf("%s: password longer than 8 characters", where))
+		}
+		if u.Role != zkteco.LEVEL_USER && u.Role != zkteco.LEVEL_ADMIN {
+			errs = append(errs, fmt.Errorf("%s: invalid role %d", where, u.Role))
+		}
+		if u.UID < 0 || u.UID > 0xFFFF || u.CardNo < 0 {
+			errs = append(errs, fmt.Errorf("%s: invalid uid or card_no", where))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,178 @@
+package zkteco
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UserDifference is a user enrolled on both devices of CompareDevices with
+// different records.
+type UserDifference struct {
+	UserID string   `json:"user_id"`
+	A      User     `json:"a"`
+	B      User     `json:"b"`
+	Fields []string `json:"fields"` // "name", "password", "role" or "card"
+}
+
+// Kinds of TemplateDifference.
+const (
+	TemplateOnlyOnA   = "only_on_a"
+	TemplateOnlyOnB   = "only_on_b"
+	TemplateDifferent = "different"
+)
+
+// TemplateDifference is a finger of a user enrolled on both devices of
+// CompareDevices whose template is missing on one of them or differs.
+type TemplateDifference struct {
+	UserID      string `json:"user_id"`
+	FingerIndex int    `json:"finger_index"`
+	Kind        string `json:"kind"` // TemplateOnlyOnA, TemplateOnlyOnB or TemplateDifferent
+}
+
+// DeviceDiff is the result of CompareDevices. Users are matched by user ID,
+// since the same user may have a different UID on each device.
+type DeviceDiff struct {
+	OnlyOnA   []User               `json:"only_on_a"`
+	OnlyOnB   []User               `json:"only_on_b"`
+	Users     []UserDifference     `json:"users"`
+	Templates []TemplateDifference `json:"templates"`
+}
+
+// Converged reports whether the two devices have the same users and
+// templates.
+func (d *DeviceDiff) Converged() bool {
+	return len(d.OnlyOnA) == 0 && len(d.OnlyOnB) == 0 && len(d.Users) == 0 && len(d.Templates) == 0
+}
+
+// String returns the differences one per line, in the style of a diff: "<"
+// for A, ">" for B and "~" for records that differ.
+func (d *DeviceDiff) String() string {
+	var b strings.Builder
+	for _, u := range d.OnlyOnA {
+		fmt.Fprintf(&b, "< user %s %q (uid %d)\n", u.UserID, u.Name, u.UID)
+	}
+	for _, u := range d.OnlyOnB {
+		fmt.Fprintf(&b, "> user %s %q (uid %d)\n", u.UserID, u.Name, u.UID)
+	}
+	for _, u := range d.Users {
+		fmt.Fprintf(&b, "~ user %s: %s differ\n", u.UserID, strings.Join(u.Fields, ", "))
+	}
+	for _, t := range d.Templates {
+		switch t.Kind {
+		case TemplateOnlyOnA:
+			fmt.Fprintf(&b, "< template %s finger %d\n", t.UserID, t.FingerIndex)
+		case TemplateOnlyOnB:
+			fmt.Fprintf(&b, "> template %s finger %d\n", t.UserID, t.FingerIndex)
+		default:
+			fmt.Fprintf(&b, "~ template %s finger %d differs\n", t.UserID, t.FingerIndex)
+		}
+	}
+	return b.String()
+}
+
+// CompareDevices downloads the users of a and b, and the fingerprint
+// templates of the users enrolled on both, and returns how they differ, to
+// check that cloning or syncing one device to another converged. Templates
+// of users enrolled on one device only are not downloaded.
+func CompareDevices(a, b *ZKTeco) (*DeviceDiff, error) {
+	usersA, err := a.GetUsers()
+	if err != nil {
+		return nil, fmt.Errorf("compareDevices: a: %w", err)
+	}
+	usersB, err := b.GetUsers()
+	if err != nil {
+		return nil, fmt.Errorf("compareDevices: b: %w", err)
+	}
+
+	diff := &DeviceDiff{}
+	byID := make(map[string]User, len(usersB))
+	for _, u := range usersB {
+		byID[u.UserID] = u
+	}
+	var common [][2]User
+	for _, ua := range usersA {
+		ub, ok := byID[ua.UserID]
+		if !ok {
+			diff.OnlyOnA = append(diff.OnlyOnA, ua)
+			continue
+		}
+		delete(byID, ua.UserID)
+		common = append(common, [2]User{ua, ub})
+		if fields := userFieldDiff(ua, ub); len(fields) > 0 {
+			diff.Users = append(diff.Users, UserDifference{UserID: ua.UserID, A: ua, B: ub, Fields: fields})
+		}
+	}
+	for _, u := range byID {
+		diff.OnlyOnB = append(diff.OnlyOnB, u)
+	}
+	sort.Slice(diff.OnlyOnA, func(i, j int) bool { return diff.OnlyOnA[i].UserID < diff.OnlyOnA[j].UserID })
+	sort.Slice(diff.OnlyOnB, func(i, j int) bool { return diff.OnlyOnB[i].UserID < diff.OnlyOnB[j].UserID })
+	sort.Slice(diff.Users, func(i, j int) bool { return diff.Users[i].UserID < diff.Users[j].UserID })
+	sort.Slice(common, func(i, j int) bool { return common[i][0].UserID < common[j][0].UserID })
+
+	templatesA, err := readTemplates(a, common, 0)
+	if err != nil {
+		return nil, fmt.Errorf("compareDevices: a: %w", err)
+	}
+	templatesB, err := readTemplates(b, common, 1)
+	if err != nil {
+		return nil, fmt.Errorf("compareDevices: b: %w", err)
+	}
+	for i, pair := range common {
+		for finger := 0; finger <= 9; finger++ {
+			ta, okA := templatesA[i][finger]
+			tb, okB := templatesB[i][finger]
+			kind := ""
+			switch {
+			case okA && !okB:
+				kind = TemplateOnlyOnA
+			case okB && !okA:
+				kind = TemplateOnlyOnB
+			case okA && !bytes.Equal(ta, tb):
+				kind = TemplateDifferent
+			}
+			if kind != "" {
+				diff.Templates = append(diff.Templates, TemplateDifference{
+					UserID:      pair[0].UserID,
+					FingerIndex: finger,
+					Kind:        kind,
+				})
+			}
+		}
+	}
+	return diff, nil
+}
+
+// userFieldDiff lists the fields of the user records a and b that differ.
+func userFieldDiff(a, b User) []string {
+	var fields []string
+	if a.Name != b.Name {
+		fields = append(fields, "name")
+	}
+	if a.Password != b.Password {
+		fields = append(fields, "password")
+	}
+	if a.Role != b.Role {
+		fields = append(fields, "role")
+	}
+	if a.CardNo != b.CardNo {
+		fields = append(fields, "card")
+	}
+	return fields
+}
+
+// readTemplates reads, with z disabled, the templates of the side-th user of
+// each pair of users.
+func readTemplates(z *ZKTeco, users [][2]User, side int) ([]map[int][]byte, error) {
+	templates := make([]map[int][]byte, len(users))
+	err := z.whileDisabled(func() error {
+		for i, pair := range users {
+			templates[i] = make(map[int][]byte)
+			z.readFingerprints(pair[side].UID, templates[i])
+		}
+		return nil
+	})
+	return templates, err
+}
@@ -1,5 +1,7 @@
 package zkteco
 
+import "fmt"
+
 // Command codes
 const (
 	CMD_CONNECT        = 1000
@@ -11,15 +13,24 @@ const (
 	CMD_SLEEP          = 1006
 	CMD_RESUME         = 1007
 	CMD_TEST_TEMP      = 1011
+	CMD_REFRESHDATA    = 1013
+	CMD_REFRESHOPTION  = 1014
 	CMD_TESTVOICE      = 1017
 	CMD_CHANGE_SPEED   = 1101
 
-	CMD_WRITE_LCD = 66
-	CMD_CLEAR_LCD = 67
+	CMD_WRITE_LCD    = 66
+	CMD_CLEAR_LCD    = 67
+	CMD_SMS_WRQ      = 70
+	CMD_SMS_RRQ      = 71
+	CMD_DELETE_SMS   = 72
+	CMD_UDATA_WRQ    = 73
+	CMD_DELETE_UDATA = 74
+	CMD_TMP_WRITE    = 87
 
 	CMD_ACK_OK     = 2000
 	CMD_ACK_ERROR  = 2001
 	CMD_ACK_DATA   = 2002
+	CMD_ACK_RETRY  = 2003 // device busy, try again later
 	CMD_ACK_UNAUTH = 2005
 	CMD_ACK_AUTH   = 1102
 
@@ -27,6 +38,10 @@ const (
 	CMD_DATA         = 1501
 	CMD_FREE_DATA    = 1502
 
+	CMD_UPDATEFILE    = 1700
+	CMD_DELETEFILE    = 1701
+	CMD_READFILE_DATA = 1702
+
 	CMD_USER_TEMP_RRQ    = 9
 	CMD_USER_TEMP_WRQ    = 10
 	CMD_DEVICE           = 11
@@ -37,6 +52,7 @@ const (
 	CMD_DELETE_USER      = 18
 	CMD_DELETE_USER_TEMP = 19
 	CMD_CLEAR_ADMIN      = 20
+	CMD_UNLOCK           = 31
 	CMD_GET_FREE_SIZES   = 50
 
 	CMD_GET_TIME = 201
@@ -62,6 +78,16 @@ const (
 const (
 	LEVEL_USER  = 0
 	LEVEL_ADMIN = 14
+
+	// USER_DISABLED is the flag bit of the role that blocks a user; see
+	// DisableUser.
+	USER_DISABLED = 1
+)
+
+// Short message tags
+const (
+	SMS_PERSONAL = 253 // shown only to users it is assigned to
+	SMS_PUBLIC   = 254 // shown to everyone
 )
 
 // Attendance states
@@ -89,9 +115,16 @@ const (
 	EF_ENROLLFINGER = 8
 	EF_BUTTON       = 16
 	EF_UNLOCK       = 32
+	EF_HIDNUM       = 64 // card swiped
 	EF_VERIFY       = 128
 	EF_FPFTR        = 256
 	EF_ALARM        = 512
+
+	// Door events of access-control firmwares; other devices ignore them.
+	// Their payload is passed in RawData.
+	EF_DOOR_CLOSE  = 1024
+	EF_EXIT_BUTTON = 2048 // door opened with the exit button
+	EF_REMOTE_OPEN = 4096 // door opened remotely (software or intercom)
 )
 
 // StateName returns a human-readable name for an attendance state.
@@ -127,3 +160,72 @@ func TypeName(typ int) string {
 		return "Unknown"
 	}
 }
+
+// commandNames are the names returned by CommandName.
+var commandNames = map[uint16]string{
+	CMD_CONNECT:          "CONNECT",
+	CMD_EXIT:             "EXIT",
+	CMD_ENABLE_DEVICE:    "ENABLE_DEVICE",
+	CMD_DISABLE_DEVICE:   "DISABLE_DEVICE",
+	CMD_RESTART:          "RESTART",
+	CMD_POWEROFF:         "POWEROFF",
+	CMD_SLEEP:            "SLEEP",
+	CMD_RESUME:           "RESUME",
+	CMD_TEST_TEMP:        "TEST_TEMP",
+	CMD_REFRESHDATA:      "REFRESHDATA",
+	CMD_REFRESHOPTION:    "REFRESHOPTION",
+	CMD_TESTVOICE:        "TESTVOICE",
+	CMD_CHANGE_SPEED:     "CHANGE_SPEED",
+	CMD_WRITE_LCD:        "WRITE_LCD",
+	CMD_CLEAR_LCD:        "CLEAR_LCD",
+	CMD_SMS_WRQ:          "SMS_WRQ",
+	CMD_SMS_RRQ:          "SMS_RRQ",
+	CMD_DELETE_SMS:       "DELETE_SMS",
+	CMD_UDATA_WRQ:        "UDATA_WRQ",
+	CMD_DELETE_UDATA:     "DELETE_UDATA",
+	CMD_TMP_WRITE:        "TMP_WRITE",
+	CMD_ACK_OK:           "ACK_OK",
+	CMD_ACK_ERROR:        "ACK_ERROR",
+	CMD_ACK_DATA:         "ACK_DATA",
+	CMD_ACK_RETRY:        "ACK_RETRY",
+	CMD_ACK_UNAUTH:       "ACK_UNAUTH",
+	CMD_ACK_AUTH:         "ACK_AUTH",
+	CMD_PREPARE_DATA:     "PREPARE_DATA",
+	CMD_DATA:             "DATA",
+	CMD_FREE_DATA:        "FREE_DATA",
+	CMD_UPDATEFILE:       "UPDATEFILE",
+	CMD_DELETEFILE:       "DELETEFILE",
+	CMD_READFILE_DATA:    "READFILE_DATA",
+	CMD_USER_TEMP_RRQ:    "USER_TEMP_RRQ",
+	CMD_USER_TEMP_WRQ:    "USER_TEMP_WRQ",
+	CMD_DEVICE:           "DEVICE",
+	CMD_OPTIONS_WRQ:      "OPTIONS_WRQ",
+	CMD_ATT_LOG_RRQ:      "ATT_LOG_RRQ",
+	CMD_CLEAR_DATA:       "CLEAR_DATA",
+	CMD_CLEAR_ATT_LOG:    "CLEAR_ATT_LOG",
+	CMD_DELETE_USER:      "DELETE_USER",
+	CMD_DELETE_USER_TEMP: "DELETE_USER_TEMP",
+	CMD_CLEAR_ADMIN:      "CLEAR_ADMIN",
+	CMD_UNLOCK:           "UNLOCK",
+	CMD_GET_FREE_SIZES:   "GET_FREE_SIZES",
+	CMD_GET_TIME:         "GET_TIME",
+	CMD_SET_TIME:         "SET_TIME",
+	CMD_REG_EVENT:        "REG_EVENT",
+	CMD_VERSION:          "VERSION",
+	CMD_SET_USER:         "SET_USER",
+}
+
+// CommandName returns the name of a command or reply code without the CMD_
+// prefix, e.g. "ACK_UNAUTH" for CMD_ACK_UNAUTH, or "UNKNOWN".
+func CommandName(cmd uint16) string {
+	if name, ok := commandNames[cmd]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+// commandString formats cmd for error and log messages, e.g.
+// "ACK_UNAUTH (2005)".
+func commandString(cmd uint16) string {
+	return fmt.Sprintf("%s (%d)", CommandName(cmd), cmd)
+}
@@ -0,0 +1,130 @@
+package zkteco
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// interruptDeadline is a read deadline in the past, set to make a pending
+// read return at once.
+var interruptDeadline = time.Unix(1, 0)
+
+// Do runs fn with a client bound to ctx: the calls fn makes on it give up
+// when ctx is canceled or its deadline passes, instead of waiting up to the
+// client timeout for a hung device. The call waiting for the device returns
+// an error wrapping ctx.Err(). A reply still in flight is then drained, for
+// at most half a second of silence, so the connection stays usable; large
+// transfers are aborted as with GetUsersContext.
+//
+//	err := zk.Do(ctx, func(zk *zkteco.ZKTeco) error {
+//	    t, err := zk.GetTime()
+//	    ...
+//	})
+//
+// The bound client shares the connection of z; calls made on z itself, by
+// fn or by other goroutines, do not see ctx.
+func (z *ZKTeco) Do(ctx context.Context, fn func(zk *ZKTeco) error) error {
+	if ctx.Done() == nil {
+		return fn(z)
+	}
+	return z.finishCall(ctx, fn(z.bind(ctx)))
+}
+
+// bind returns a ZKTeco sharing the client of z whose calls see ctx.
+func (z *ZKTeco) bind(ctx context.Context) *ZKTeco {
+	return &ZKTeco{client: z.client, ctx: ctx}
+}
+
+// callContext returns ctx, or the context Do bound z to when ctx is never
+// done, e.g. the context.Background() of a method without a context.
+func (z *ZKTeco) callContext(ctx context.Context) context.Context {
+	if ctx.Done() == nil && z.ctx != nil {
+		return z.ctx
+	}
+	return ctx
+}
+
+// finishCall completes a call made with ctx that returned err: if ctx ended
+// it, the reply still in flight is drained and err is made to wrap
+// ctx.Err().
+func (z *ZKTeco) finishCall(ctx context.Context, err error) error {
+	ctxErr := ctx.Err()
+	if d, ok := ctx.Deadline(); ok && ctxErr == nil && isTimeout(err) && !time.Now().Before(d) {
+		// The read deadline passed just before the context noticed
+		ctxErr = context.DeadlineExceeded
+	}
+	if err == nil || ctxErr == nil {
+		return err
+	}
+	z.mu.Lock()
+	if z.transport != nil {
+		z.drain()
+	}
+	z.mu.Unlock()
+	if err == ctxErr {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ctxErr, err)
+}
+
+// armRead sets the read deadline d (zero for the client timeout alone),
+// capped by the deadline of ctx. It returns the error of ctx, checked after
+// the update so a cancellation racing with it is not lost.
+func (z *ZKTeco) armRead(ctx context.Context, d time.Time) error {
+	if cd, ok := ctx.Deadline(); ok && (d.IsZero() || cd.Before(d)) {
+		d = cd
+	}
+	z.transport.SetReadDeadline(d)
+	return ctx.Err()
+}
+
+// ConnectContext is like Connect but gives up when ctx is done, including
+// while dialing with the built-in transports (see ContextDialer) and during
+// WithBusyWait retries.
+func (z *ZKTeco) ConnectContext(ctx context.Context) (err error) {
+	defer z.recoverInternal("connectContext", &err)
+	return z.finishCall(ctx, z.bind(ctx).connectRetry())
+}
+
+// DisconnectContext is like Disconnect but stops waiting for the device to
+// acknowledge when ctx is done. The connection is closed either way.
+func (z *ZKTeco) DisconnectContext(ctx context.Context) (err error) {
+	defer z.recoverInternal("disconnectContext", &err)
+	if !z.connected() {
+		return nil
+	}
+	err = z.finishCall(ctx, z.bind(ctx).Disconnect())
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.transport != nil {
+		// Canceled before Disconnect got to close it
+		z.closeTransport()
+	}
+	return err
+}
+
+// ListenEventsContext is like ListenEvents without a timeout: it listens
+// until ctx is done, returning ctx.Err(), or the handler stops it.
+func (z *ZKTeco) ListenEventsContext(ctx context.Context, handler EventHandler, eventMask int) (err error) {
+	defer z.recoverInternal("listenEventsContext", &err)
+	return z.finishCall(ctx, z.bind(ctx).listenEvents(handler, eventMask, 0, ctx.Err))
+}
+
+// GetRealTimeEventsContext is like GetRealTimeEvents but listens until ctx
+// is done, returning ctx.Err().
+func (z *ZKTeco) GetRealTimeEventsContext(ctx context.Context, callback EventCallback, eventMask int) (err error) {
+	defer z.recoverInternal("getRealTimeEventsContext", &err)
+	return z.ListenEventsContext(ctx, func(event RealTimeEvent) error {
+		callback(event)
+		return nil
+	}, eventMask)
+}
+
+// GetTimeContext is like GetTime but gives up when ctx is done. See Do for
+// other calls.
+func (z *ZKTeco) GetTimeContext(ctx context.Context) (t time.Time, err error) {
+	defer z.recoverInternal("getTimeContext", &err)
+	t, err = z.bind(ctx).GetTime()
+	return t, z.finishCall(ctx, err)
+}
@@ -1,12 +1,16 @@
 package zkteco
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"time"
 )
 
 // EnableDevice enables the device (resumes normal operation).
-func (z *ZKTeco) EnableDevice() error {
+func (z *ZKTeco) EnableDevice() (err error) {
+	defer z.recoverInternal("enableDevice", &err)
 	resp, err := z.command(CMD_ENABLE_DEVICE, nil, "general")
 	if err != nil {
 		return err
@@ -16,13 +20,14 @@ func (z *ZKTeco) EnableDevice() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("enableDevice: error response %d", pkt.Command)
+		return fmt.Errorf("enableDevice: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // DisableDevice disables the device (shows "working..." on screen).
-func (z *ZKTeco) DisableDevice() error {
+func (z *ZKTeco) DisableDevice() (err error) {
+	defer z.recoverInternal("disableDevice", &err)
 	data := []byte{0x00, 0x00}
 	resp, err := z.command(CMD_DISABLE_DEVICE, data, "general")
 	if err != nil {
@@ -33,13 +38,74 @@ func (z *ZKTeco) DisableDevice() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("disableDevice: error response %d", pkt.Command)
+		return fmt.Errorf("disableDevice: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
+// RefreshData makes the device reload its data after bulk writes.
+func (z *ZKTeco) RefreshData() (err error) {
+	defer z.recoverInternal("refreshData", &err)
+	return z.ackCommand("refreshData", CMD_REFRESHDATA, nil)
+}
+
+// WithDeviceDisabled disables the device, runs fn and then re-enables the
+// device and refreshes its data, whether fn succeeds, fails or panics. Use it
+// around bulk writes instead of pairing DisableDevice and EnableDevice by
+// hand. fn is not run if ctx is already done.
+func (z *ZKTeco) WithDeviceDisabled(ctx context.Context, fn func() error) (err error) {
+	defer z.recoverInternal("withDeviceDisabled", &err)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("withDeviceDisabled: %w", err)
+	}
+	if err := z.DisableDevice(); err != nil {
+		return fmt.Errorf("withDeviceDisabled: %w", err)
+	}
+
+	defer func() {
+		enableErr := z.EnableDevice()
+		refreshErr := z.RefreshData()
+		if enableErr != nil {
+			err = errors.Join(err, fmt.Errorf("withDeviceDisabled: enable: %w", enableErr))
+		}
+		if refreshErr != nil {
+			err = errors.Join(err, fmt.Errorf("withDeviceDisabled: %w", refreshErr))
+		}
+	}()
+
+	return fn()
+}
+
+// WithAutoDisable makes GetUsers, the attendance downloads and the
+// fingerprint template transfers disable the device for their duration and
+// enable it again afterwards, as the official SDK recommends, so punches made
+// during a long transfer cannot interleave with it. Calls made while the
+// device is already disabled leave it disabled.
+func WithAutoDisable() Option {
+	return func(z *ZKTeco) {
+		z.autoDisable = true
+	}
+}
+
+// whileDisabled runs fn with the device disabled when WithAutoDisable is set.
+func (z *ZKTeco) whileDisabled(fn func() error) (err error) {
+	if !z.autoDisable || z.isDisabled() {
+		return fn()
+	}
+	if err := z.DisableDevice(); err != nil {
+		return err
+	}
+	defer func() {
+		if enableErr := z.EnableDevice(); enableErr != nil {
+			err = errors.Join(err, fmt.Errorf("enable: %w", enableErr))
+		}
+	}()
+	return fn()
+}
+
 // Restart restarts the device.
-func (z *ZKTeco) Restart() error {
+func (z *ZKTeco) Restart() (err error) {
+	defer z.recoverInternal("restart", &err)
 	data := []byte{0x00, 0x00}
 	resp, err := z.command(CMD_RESTART, data, "general")
 	if err != nil {
@@ -50,13 +116,14 @@ func (z *ZKTeco) Restart() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("restart: error response %d", pkt.Command)
+		return fmt.Errorf("restart: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // Shutdown powers off the device.
-func (z *ZKTeco) Shutdown() error {
+func (z *ZKTeco) Shutdown() (err error) {
+	defer z.recoverInternal("shutdown", &err)
 	data := []byte{0x00, 0x00}
 	resp, err := z.command(CMD_POWEROFF, data, "general")
 	if err != nil {
@@ -67,13 +134,14 @@ func (z *ZKTeco) Shutdown() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("shutdown: error response %d", pkt.Command)
+		return fmt.Errorf("shutdown: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // Sleep puts the device to sleep.
-func (z *ZKTeco) Sleep() error {
+func (z *ZKTeco) Sleep() (err error) {
+	defer z.recoverInternal("sleep", &err)
 	data := []byte{0x00, 0x00}
 	resp, err := z.command(CMD_SLEEP, data, "general")
 	if err != nil {
@@ -84,13 +152,14 @@ func (z *ZKTeco) Sleep() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("sleep: error response %d", pkt.Command)
+		return fmt.Errorf("sleep: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // Resume wakes the device from sleep.
-func (z *ZKTeco) Resume() error {
+func (z *ZKTeco) Resume() (err error) {
+	defer z.recoverInternal("resume", &err)
 	data := []byte{0x00, 0x00}
 	resp, err := z.command(CMD_RESUME, data, "general")
 	if err != nil {
@@ -101,13 +170,22 @@ func (z *ZKTeco) Resume() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("resume: error response %d", pkt.Command)
+		return fmt.Errorf("resume: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
+// UnlockDoor opens the door lock for d, rounded down to tenths of a second.
+func (z *ZKTeco) UnlockDoor(d time.Duration) (err error) {
+	defer z.recoverInternal("unlockDoor", &err)
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, uint32(d/(100*time.Millisecond)))
+	return z.ackCommand("unlockDoor", CMD_UNLOCK, data)
+}
+
 // TestVoice plays a voice/sound by index.
-func (z *ZKTeco) TestVoice(index int) error {
+func (z *ZKTeco) TestVoice(index int) (err error) {
+	defer z.recoverInternal("testVoice", &err)
 	data := make([]byte, 4)
 	binary.LittleEndian.PutUint32(data, uint32(index))
 	resp, err := z.command(CMD_TESTVOICE, data, "general")
@@ -119,13 +197,14 @@ func (z *ZKTeco) TestVoice(index int) error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("testVoice: error response %d", pkt.Command)
+		return fmt.Errorf("testVoice: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // WriteLCD writes a message to the device LCD display.
-func (z *ZKTeco) WriteLCD(message string) error {
+func (z *ZKTeco) WriteLCD(message string) (err error) {
+	defer z.recoverInternal("writeLCD", &err)
 	rank := 2
 	data := make([]byte, 0, 4+len(message))
 	data = append(data, byte(rank), byte(rank>>8), 0x00, ' ')
@@ -140,13 +219,14 @@ func (z *ZKTeco) WriteLCD(message string) error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("writeLCD: error response %d", pkt.Command)
+		return fmt.Errorf("writeLCD: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // ClearLCD clears the LCD display.
-func (z *ZKTeco) ClearLCD() error {
+func (z *ZKTeco) ClearLCD() (err error) {
+	defer z.recoverInternal("clearLCD", &err)
 	resp, err := z.command(CMD_CLEAR_LCD, nil, "general")
 	if err != nil {
 		return err
@@ -156,7 +236,7 @@ func (z *ZKTeco) ClearLCD() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("clearLCD: error response %d", pkt.Command)
+		return fmt.Errorf("clearLCD: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
@@ -0,0 +1,182 @@
+package zkteco
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DedupeStore remembers the records already delivered downstream, so a
+// forwarder (webhook, message queue) restarted after a crash, or fed both
+// real-time events and a Replay, does not post the same punch twice.
+type DedupeStore interface {
+	// Seen reports whether key was marked.
+	Seen(key string) (bool, error)
+	// Mark records key as delivered.
+	Mark(key string) error
+}
+
+// Key identifies the punch across downloads and real-time events: device
+// serial (see WithRecordSerial), user ID and record time.
+func (a Attendance) Key() string {
+	return fmt.Sprintf("%s/%s/%d", a.DeviceSerial, a.UserID, a.RecordTime.Unix())
+}
+
+// Key identifies the event for a DedupeStore. Attendance events have the key
+// of the matching Attendance record.
+func (e RealTimeEvent) Key() string {
+	if e.EventType == EF_ATTLOG {
+		return fmt.Sprintf("%s/%s/%d", e.DeviceSerial, e.UserID, e.Time.Unix())
+	}
+	return fmt.Sprintf("%s/%s/%s/%d", e.DeviceSerial, e.EventName, e.UserID, e.Time.UnixNano())
+}
+
+// Dedupe wraps handler so it is called once per event key: events already
+// marked in store are skipped, and an event is marked once handler returns
+// nil. Events that could not be decoded are passed through unmarked.
+func Dedupe(store DedupeStore, handler EventHandler) EventHandler {
+	return func(e RealTimeEvent) error {
+		if e.DecodeError != "" {
+			return handler(e)
+		}
+		key := e.Key()
+		seen, err := store.Seen(key)
+		if err != nil {
+			return fmt.Errorf("dedupe: %w", err)
+		}
+		if seen {
+			return nil
+		}
+		if err := handler(e); err != nil {
+			return err
+		}
+		if err := store.Mark(key); err != nil {
+			return fmt.Errorf("dedupe: %w", err)
+		}
+		return nil
+	}
+}
+
+// FileDedupeStore is a DedupeStore kept in an append-only file of keys,
+// synced on every Mark. It remembers the last max keys; the file is
+// compacted when it grows to twice that.
+type FileDedupeStore struct {
+	path string
+	max  int
+
+	mu    sync.Mutex
+	f     *os.File
+	keys  map[string]bool
+	order []string
+	lines int // lines in the file
+}
+
+// OpenFileDedupeStore opens the store at path, creating it if needed, and
+// loads its keys. max is the number of keys remembered (default 100000).
+func OpenFileDedupeStore(path string, max int) (*FileDedupeStore, error) {
+	if max <= 0 {
+		max = 100000
+	}
+	s := &FileDedupeStore{path: path, max: max, keys: make(map[string]bool)}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("dedupe store: %w", err)
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		s.lines++
+		s.add(scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("dedupe store %s: %w", path, err)
+	}
+	s.f = f
+	return s, nil
+}
+
+// Seen reports whether key was marked.
+func (s *FileDedupeStore) Seen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[key], nil
+}
+
+// Mark appends key to the file.
+func (s *FileDedupeStore) Mark(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys[key] {
+		return nil
+	}
+	if _, err := s.f.WriteString(key + "\n"); err != nil {
+		return fmt.Errorf("dedupe store %s: %w", s.path, err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("dedupe store %s: %w", s.path, err)
+	}
+	s.lines++
+	s.add(key)
+	if s.lines >= 2*s.max {
+		return s.compact()
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *FileDedupeStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+// add remembers key, forgetting the oldest key beyond max.
+func (s *FileDedupeStore) add(key string) {
+	if key == "" || s.keys[key] {
+		return
+	}
+	s.keys[key] = true
+	s.order = append(s.order, key)
+	if len(s.order) > s.max {
+		delete(s.keys, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// compact rewrites the file with the remembered keys only, atomically.
+func (s *FileDedupeStore) compact() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("dedupe store %s: %w", s.path, err)
+	}
+	w := bufio.NewWriter(tmp)
+	for _, key := range s.order {
+		w.WriteString(key + "\n")
+	}
+	err = w.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("dedupe store %s: compact: %w", s.path, err)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("dedupe store %s: %w", s.path, err)
+	}
+	s.f.Close()
+	s.f = f
+	s.lines = len(s.order)
+	return nil
+}
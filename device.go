@@ -2,24 +2,84 @@ package zkteco
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
-// getDeviceOption sends CMD_DEVICE with a key and returns the value.
+// ErrUnknownOption is returned when the device refuses to read an option,
+// usually because the firmware does not have it.
+var ErrUnknownOption = errors.New("unknown device option")
+
+// getDeviceOption returns the value of a device option, from the WithCache
+// cache if possible.
 func (z *ZKTeco) getDeviceOption(key string) (string, error) {
+	if value, ok := z.cached(key); ok {
+		return value, nil
+	}
+	return z.queryDeviceOption(key)
+}
+
+// queryDeviceOption sends CMD_DEVICE with a key and returns the value.
+func (z *ZKTeco) queryDeviceOption(key string) (string, error) {
 	resp, err := z.command(CMD_DEVICE, []byte(key), "general")
 	if err != nil {
 		return "", err
 	}
+	value, err := parseDeviceOption(key, resp)
+	if err != nil {
+		return "", err
+	}
+	z.setCached(key, value)
+	return value, nil
+}
+
+// getDeviceOptions reads several device options, pipelining the queries (see
+// commandPipeline). Values are returned in the order of keys. The cache is
+// bypassed but refreshed.
+func (z *ZKTeco) getDeviceOptions(keys []string) ([]string, error) {
+	values, errs := z.getDeviceOptionsEach(keys)
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// getDeviceOptionsEach is like getDeviceOptions but returns an error per key.
+func (z *ZKTeco) getDeviceOptionsEach(keys []string) ([]string, []error) {
+	cmds := make([]pipelinedCommand, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipelinedCommand{CMD_DEVICE, []byte(key)}
+	}
+	resps := z.commandPipeline(cmds)
+
+	values := make([]string, len(keys))
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		if resps[i] == nil {
+			values[i], errs[i] = z.queryDeviceOption(key)
+			continue
+		}
+		values[i], errs[i] = parseDeviceOption(key, resps[i])
+		if errs[i] == nil {
+			z.setCached(key, values[i])
+		}
+	}
+	return values, errs
+}
 
+// parseDeviceOption extracts the value from a CMD_DEVICE response.
+func parseDeviceOption(key string, resp []byte) (string, error) {
 	pkt, err := parsePacket(resp)
 	if err != nil {
 		return "", err
 	}
 
 	if pkt.Command != CMD_ACK_OK && pkt.Command != CMD_ACK_DATA {
-		return "", fmt.Errorf("device option %q: error response %d", key, pkt.Command)
+		return "", fmt.Errorf("device option %q: %w (response %s)", key, ErrUnknownOption, commandString(pkt.Command))
 	}
 
 	value := string(pkt.Data)
@@ -30,12 +90,39 @@ func (z *ZKTeco) getDeviceOption(key string) (string, error) {
 	return value, nil
 }
 
+// getIntOption reads a numeric device option.
+func (z *ZKTeco) getIntOption(key string) (int, error) {
+	value, err := z.getDeviceOption(key)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("option %q: invalid number %q", key, value)
+	}
+	return n, nil
+}
+
 // Version returns the firmware version.
-func (z *ZKTeco) Version() (string, error) {
+func (z *ZKTeco) Version() (_ string, err error) {
+	defer z.recoverInternal("version", &err)
+	if version, ok := z.cached(versionCacheKey); ok {
+		return version, nil
+	}
 	resp, err := z.command(CMD_VERSION, nil, "general")
 	if err != nil {
 		return "", err
 	}
+	version, err := parseVersion(resp)
+	if err != nil {
+		return "", err
+	}
+	z.setCached(versionCacheKey, version)
+	return version, nil
+}
+
+// parseVersion extracts the firmware version from a CMD_VERSION response.
+func parseVersion(resp []byte) (string, error) {
 	pkt, err := parsePacket(resp)
 	if err != nil {
 		return "", err
@@ -44,117 +131,258 @@ func (z *ZKTeco) Version() (string, error) {
 }
 
 // SerialNumber returns the device serial number.
-func (z *ZKTeco) SerialNumber() (string, error) {
+func (z *ZKTeco) SerialNumber() (_ string, err error) {
+	defer z.recoverInternal("serialNumber", &err)
 	return z.getDeviceOption("~SerialNumber")
 }
 
 // DeviceName returns the device name.
-func (z *ZKTeco) DeviceName() (string, error) {
+func (z *ZKTeco) DeviceName() (_ string, err error) {
+	defer z.recoverInternal("deviceName", &err)
 	return z.getDeviceOption("~DeviceName")
 }
 
 // DeviceID returns the device ID.
-func (z *ZKTeco) DeviceID() (string, error) {
+func (z *ZKTeco) DeviceID() (_ string, err error) {
+	defer z.recoverInternal("deviceID", &err)
 	return z.getDeviceOption("DeviceID")
 }
 
 // VendorName returns the vendor/OEM name.
-func (z *ZKTeco) VendorName() (string, error) {
+func (z *ZKTeco) VendorName() (_ string, err error) {
+	defer z.recoverInternal("vendorName", &err)
 	return z.getDeviceOption("~OEMVendor")
 }
 
 // Platform returns the device platform.
-func (z *ZKTeco) Platform() (string, error) {
+func (z *ZKTeco) Platform() (_ string, err error) {
+	defer z.recoverInternal("platform", &err)
 	return z.getDeviceOption("~Platform")
 }
 
 // OSVersion returns the OS version.
-func (z *ZKTeco) OSVersion() (string, error) {
+func (z *ZKTeco) OSVersion() (_ string, err error) {
+	defer z.recoverInternal("oSVersion", &err)
 	return z.getDeviceOption("~OS")
 }
 
 // FMVersion returns the fingerprint module version.
-func (z *ZKTeco) FMVersion() (string, error) {
+func (z *ZKTeco) FMVersion() (_ string, err error) {
+	defer z.recoverInternal("fMVersion", &err)
 	return z.getDeviceOption("~ZKFPVersion")
 }
 
 // SSR returns the SSR info.
-func (z *ZKTeco) SSR() (string, error) {
+func (z *ZKTeco) SSR() (_ string, err error) {
+	defer z.recoverInternal("sSR", &err)
 	return z.getDeviceOption("~SSR")
 }
 
 // PinWidth returns the PIN width.
-func (z *ZKTeco) PinWidth() (string, error) {
+func (z *ZKTeco) PinWidth() (_ string, err error) {
+	defer z.recoverInternal("pinWidth", &err)
 	return z.getDeviceOption("~PIN2Width")
 }
 
 // FaceFunctionOn returns the face function status.
-func (z *ZKTeco) FaceFunctionOn() (string, error) {
+func (z *ZKTeco) FaceFunctionOn() (_ string, err error) {
+	defer z.recoverInternal("faceFunctionOn", &err)
 	return z.getDeviceOption("FaceFunOn")
 }
 
 // WorkCode returns the work code info.
-func (z *ZKTeco) WorkCode() (string, error) {
+func (z *ZKTeco) WorkCode() (_ string, err error) {
+	defer z.recoverInternal("workCode", &err)
 	return z.getDeviceOption("WorkCode")
 }
 
+// BuildTime returns the firmware build date.
+func (z *ZKTeco) BuildTime() (_ string, err error) {
+	defer z.recoverInternal("buildTime", &err)
+	return z.getDeviceOption("~BuildTime")
+}
+
+// DeviceType returns the vendor device type code.
+func (z *ZKTeco) DeviceType() (_ string, err error) {
+	defer z.recoverInternal("deviceType", &err)
+	return z.getDeviceOption("~DeviceType")
+}
+
+// OEMCode returns the OEM customization code.
+func (z *ZKTeco) OEMCode() (_ string, err error) {
+	defer z.recoverInternal("oEMCode", &err)
+	return z.getDeviceOption("~OEMCode")
+}
+
+// DeviceInfo aggregates the device identity options.
+type DeviceInfo struct {
+	SerialNumber    string `json:"serial_number"`
+	DeviceName      string `json:"device_name"`
+	DeviceID        string `json:"device_id"`
+	VendorName      string `json:"vendor_name"`
+	Platform        string `json:"platform"`
+	FirmwareVersion string `json:"firmware_version"`
+	OSVersion       string `json:"os_version"`
+	FMVersion       string `json:"fm_version"`
+	BuildTime       string `json:"build_time"`
+	DeviceType      string `json:"device_type"`
+	OEMCode         string `json:"oem_code"`
+}
+
+// GetDeviceInfo returns the device identity in one struct.
+// Serial number and firmware version are required; the other fields are left
+// empty when the firmware does not support them.
+func (z *ZKTeco) GetDeviceInfo() (_ *DeviceInfo, err error) {
+	defer z.recoverInternal("getDeviceInfo", &err)
+	info := &DeviceInfo{}
+
+	if info.SerialNumber, err = z.SerialNumber(); err != nil {
+		return nil, fmt.Errorf("getDeviceInfo: serial number: %w", err)
+	}
+	if info.FirmwareVersion, err = z.Version(); err != nil {
+		return nil, fmt.Errorf("getDeviceInfo: version: %w", err)
+	}
+
+	info.DeviceName, _ = z.DeviceName()
+	info.DeviceID, _ = z.DeviceID()
+	info.VendorName, _ = z.VendorName()
+	info.Platform, _ = z.Platform()
+	info.OSVersion, _ = z.OSVersion()
+	info.FMVersion, _ = z.FMVersion()
+	info.BuildTime, _ = z.BuildTime()
+	info.DeviceType, _ = z.DeviceType()
+	info.OEMCode, _ = z.OEMCode()
+
+	return info, nil
+}
+
 // MemoryInfo holds device memory/capacity information.
+// Fields the firmware does not report are left zero.
 type MemoryInfo struct {
 	AdminCount   int
 	UserCount    int
 	UserCapacity int
 	LogCount     int
 	LogCapacity  int
+
+	PasswordUsers        int // users with a password
+	FingerprintCount     int
+	FingerprintCapacity  int
+	FaceCount            int
+	FaceCapacity         int
+	UserAvailable        int // remaining user slots
+	LogAvailable         int // remaining log slots
+	FingerprintAvailable int // remaining fingerprint slots
 }
 
-// GetMemoryInfo returns memory usage and capacity info.
-func (z *ZKTeco) GetMemoryInfo() (*MemoryInfo, error) {
+// Indexes into the free sizes vector.
+const (
+	freeSizeUsers          = 4
+	freeSizeFingerprints   = 6
+	freeSizeLogs           = 8
+	freeSizePasswords      = 10
+	freeSizeAdmins         = 12
+	freeSizeFingerprintCap = 14
+	freeSizeUserCap        = 15
+	freeSizeLogCap         = 16
+	freeSizeFingerprintAv  = 17
+	freeSizeUserAv         = 18
+	freeSizeLogAv          = 19
+	freeSizeFaces          = 20
+	freeSizeFaceCap        = 22
+)
+
+// GetFreeSizesRaw returns the raw free sizes vector: one little-endian int per
+// 4 bytes of the CMD_GET_FREE_SIZES response (usually 20, or 23 on firmwares
+// with face recognition).
+func (z *ZKTeco) GetFreeSizesRaw() (_ []uint32, err error) {
+	defer z.recoverInternal("getFreeSizesRaw", &err)
 	resp, err := z.command(CMD_GET_FREE_SIZES, nil, "general")
 	if err != nil {
 		return nil, err
 	}
+	return parseFreeSizes(resp)
+}
 
+// parseFreeSizes decodes a CMD_GET_FREE_SIZES response.
+func parseFreeSizes(resp []byte) ([]uint32, error) {
 	pkt, err := parsePacket(resp)
 	if err != nil {
 		return nil, err
 	}
 
 	if pkt.Command != CMD_ACK_OK && pkt.Command != CMD_ACK_DATA {
-		return nil, fmt.Errorf("getMemoryInfo: error response %d", pkt.Command)
+		return nil, fmt.Errorf("getFreeSizes: error response %s", commandString(pkt.Command))
 	}
 
-	data := pkt.Data
-	if len(data) < 68 {
-		return nil, fmt.Errorf("getMemoryInfo: response too short: %d bytes", len(data))
+	sizes := make([]uint32, len(pkt.Data)/4)
+	for i := range sizes {
+		sizes[i] = binary.LittleEndian.Uint32(pkt.Data[i*4:])
 	}
+	return sizes, nil
+}
 
-	info := &MemoryInfo{}
-	if len(data) > 51 {
-		info.AdminCount = int(binary.LittleEndian.Uint32(data[48:52]))
-	}
-	if len(data) > 19 {
-		info.UserCount = int(binary.LittleEndian.Uint32(data[16:20]))
-	}
-	if len(data) > 63 {
-		info.UserCapacity = int(binary.LittleEndian.Uint32(data[60:64]))
+// GetMemoryInfo returns memory usage and capacity info.
+func (z *ZKTeco) GetMemoryInfo() (_ *MemoryInfo, err error) {
+	defer z.recoverInternal("getMemoryInfo", &err)
+	sizes, err := z.GetFreeSizesRaw()
+	if err != nil {
+		return nil, err
 	}
-	if len(data) > 35 {
-		info.LogCount = int(binary.LittleEndian.Uint32(data[32:36]))
+	return memoryInfoFromSizes(sizes)
+}
+
+// memoryInfoFromSizes maps the free sizes vector to a MemoryInfo.
+func memoryInfoFromSizes(sizes []uint32) (*MemoryInfo, error) {
+	if len(sizes) <= freeSizeLogCap {
+		return nil, fmt.Errorf("getMemoryInfo: response too short: %d bytes", len(sizes)*4)
 	}
-	if len(data) > 67 {
-		info.LogCapacity = int(binary.LittleEndian.Uint32(data[64:68]))
+
+	field := func(i int) int {
+		if i < len(sizes) {
+			return int(sizes[i])
+		}
+		return 0
 	}
 
-	return info, nil
+	return &MemoryInfo{
+		AdminCount:   field(freeSizeAdmins),
+		UserCount:    field(freeSizeUsers),
+		UserCapacity: field(freeSizeUserCap),
+		LogCount:     field(freeSizeLogs),
+		LogCapacity:  field(freeSizeLogCap),
+
+		PasswordUsers:        field(freeSizePasswords),
+		FingerprintCount:     field(freeSizeFingerprints),
+		FingerprintCapacity:  field(freeSizeFingerprintCap),
+		FaceCount:            field(freeSizeFaces),
+		FaceCapacity:         field(freeSizeFaceCap),
+		UserAvailable:        field(freeSizeUserAv),
+		LogAvailable:         field(freeSizeLogAv),
+		FingerprintAvailable: field(freeSizeFingerprintAv),
+	}, nil
 }
 
 // GetDeviceData gets a raw device option by key.
-func (z *ZKTeco) GetDeviceData(key string) (string, error) {
+func (z *ZKTeco) GetDeviceData(key string) (_ string, err error) {
+	defer z.recoverInternal("getDeviceData", &err)
 	return z.getDeviceOption(key)
 }
 
+// SetDeviceData sets a raw device option by key and makes the device reload
+// its options.
+func (z *ZKTeco) SetDeviceData(key, value string) (err error) {
+	defer z.recoverInternal("setDeviceData", &err)
+	data := []byte(fmt.Sprintf("%s=%s", key, value))
+	if err := z.ackCommand("setDeviceData", CMD_OPTIONS_WRQ, data); err != nil {
+		return err
+	}
+	return z.ackCommand("setDeviceData", CMD_REFRESHOPTION, nil)
+}
+
 // SetCustomData sets a custom key-value pair on the device.
-func (z *ZKTeco) SetCustomData(key, value string) error {
+func (z *ZKTeco) SetCustomData(key, value string) (err error) {
+	defer z.recoverInternal("setCustomData", &err)
 	data := []byte(fmt.Sprintf("*%s=%s", key, value))
 	resp, err := z.command(CMD_OPTIONS_WRQ, data, "general")
 	if err != nil {
@@ -165,18 +393,20 @@ func (z *ZKTeco) SetCustomData(key, value string) error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("setCustomData: error response %d", pkt.Command)
+		return fmt.Errorf("setCustomData: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // GetCustomData gets a custom key-value pair from the device.
-func (z *ZKTeco) GetCustomData(key string) (string, error) {
+func (z *ZKTeco) GetCustomData(key string) (_ string, err error) {
+	defer z.recoverInternal("getCustomData", &err)
 	return z.getDeviceOption("*" + key)
 }
 
 // SetPushCommKey sets the push communication key.
-func (z *ZKTeco) SetPushCommKey(value string) error {
+func (z *ZKTeco) SetPushCommKey(value string) (err error) {
+	defer z.recoverInternal("setPushCommKey", &err)
 	data := []byte(fmt.Sprintf("pushcommkey=%s", value))
 	resp, err := z.command(CMD_OPTIONS_WRQ, data, "general")
 	if err != nil {
@@ -187,12 +417,13 @@ func (z *ZKTeco) SetPushCommKey(value string) error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("setPushCommKey: error response %d", pkt.Command)
+		return fmt.Errorf("setPushCommKey: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // GetPushCommKey gets the push communication key.
-func (z *ZKTeco) GetPushCommKey() (string, error) {
+func (z *ZKTeco) GetPushCommKey() (_ string, err error) {
+	defer z.recoverInternal("getPushCommKey", &err)
 	return z.getDeviceOption("pushcommkey")
 }
@@ -0,0 +1,104 @@
+package zkteco
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Discovery limits of DiscoverTCP.
+const (
+	discoverPort        = 4370
+	discoverConcurrency = 64
+	discoverTimeout     = 2 * time.Second // per address
+	discoverMaxHostBits = 16              // at most a /16 of IPv4
+)
+
+// DiscoveredDevice is a device found by DiscoverTCP.
+type DiscoveredDevice struct {
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+	Serial string `json:"serial"`
+}
+
+// DiscoverTCP finds the devices of the network cidr, e.g. "192.168.1.0/24",
+// for networks that block the UDP broadcast the vendor tools rely on. It
+// probes port 4370 of every address concurrently, connects over TCP where
+// the port is open and reads the serial number (empty if the device does
+// not give it); addresses that do not answer the handshake within two
+// seconds are skipped. opts apply to the probing clients, e.g. WithPassword
+// for devices with a comm key. The range is limited to 65536 addresses.
+// Devices are returned in address order; once ctx is done, the ones found
+// so far are returned with ctx.Err().
+func DiscoverTCP(ctx context.Context, cidr string, opts ...Option) ([]DiscoveredDevice, error) {
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("discover: %w", err)
+	}
+	prefix = prefix.Masked()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > discoverMaxHostBits {
+		return nil, fmt.Errorf("discover: %s has more than %d addresses", cidr, 1<<discoverMaxHostBits)
+	}
+
+	var addrs []netip.Addr
+	for a := prefix.Addr(); a.IsValid() && prefix.Contains(a); a = a.Next() {
+		addrs = append(addrs, a)
+	}
+	// Skip the network and broadcast addresses of IPv4 subnets
+	if prefix.Addr().Is4() && hostBits >= 2 {
+		addrs = addrs[1 : len(addrs)-1]
+	}
+
+	found := make([]*DiscoveredDevice, len(addrs))
+	sem := make(chan struct{}, discoverConcurrency)
+	var wg sync.WaitGroup
+	for i, a := range addrs {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, host string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			found[i] = probeTCP(ctx, host, opts)
+		}(i, a.String())
+	}
+	wg.Wait()
+
+	var devices []DiscoveredDevice
+	for _, d := range found {
+		if d != nil {
+			devices = append(devices, *d)
+		}
+	}
+	return devices, ctx.Err()
+}
+
+// probeTCP connects to host and reads its serial number, or returns nil if
+// no device answers there. A device whose serial number cannot be read is
+// returned without it.
+func probeTCP(ctx context.Context, host string, opts []Option) *DiscoveredDevice {
+	ctx, cancel := context.WithTimeout(ctx, discoverTimeout)
+	defer cancel()
+
+	z := NewZKTeco(host, discoverPort, append([]Option{WithProtocol("tcp")}, opts...)...)
+	if err := z.ConnectContext(ctx); err != nil {
+		return nil
+	}
+	defer z.DisconnectContext(ctx)
+
+	d := &DiscoveredDevice{Host: host, Port: discoverPort}
+	z.Do(ctx, func(zk *ZKTeco) error {
+		var err error
+		d.Serial, err = zk.SerialNumber()
+		return err
+	})
+	return d
+}
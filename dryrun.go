@@ -0,0 +1,73 @@
+package zkteco
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"log/slog"
+)
+
+// WithDryRun makes the client log the commands that change the device (user,
+// template and option writes, deletions, clears, time and LCD changes,
+// uploads, door unlocks, power and enable/disable commands) instead of
+// sending them, and answer them with CMD_ACK_OK. Reads are sent as usual.
+// Each skipped command is logged with the exact packet that would have been
+// sent to the WithLogger logger, or to slog.Default.
+func WithDryRun() Option {
+	return func(z *ZKTeco) {
+		z.dryRun = true
+	}
+}
+
+// writeCommands are the commands WithDryRun does not send.
+var writeCommands = map[uint16]bool{
+	CMD_ENABLE_DEVICE:    true,
+	CMD_DISABLE_DEVICE:   true,
+	CMD_RESTART:          true,
+	CMD_POWEROFF:         true,
+	CMD_SLEEP:            true,
+	CMD_RESUME:           true,
+	CMD_REFRESHDATA:      true,
+	CMD_REFRESHOPTION:    true,
+	CMD_TESTVOICE:        true,
+	CMD_WRITE_LCD:        true,
+	CMD_CLEAR_LCD:        true,
+	CMD_SMS_WRQ:          true,
+	CMD_DELETE_SMS:       true,
+	CMD_UDATA_WRQ:        true,
+	CMD_DELETE_UDATA:     true,
+	CMD_TMP_WRITE:        true,
+	CMD_PREPARE_DATA:     true,
+	CMD_DATA:             true,
+	CMD_UPDATEFILE:       true,
+	CMD_DELETEFILE:       true,
+	CMD_USER_TEMP_WRQ:    true,
+	CMD_OPTIONS_WRQ:      true,
+	CMD_CLEAR_DATA:       true,
+	CMD_CLEAR_ATT_LOG:    true,
+	CMD_DELETE_USER:      true,
+	CMD_DELETE_USER_TEMP: true,
+	CMD_CLEAR_ADMIN:      true,
+	CMD_SET_TIME:         true,
+	CMD_SET_USER:         true,
+	CMD_UNLOCK:           true,
+}
+
+// dryRunCommand logs the packet for cmd and returns the CMD_ACK_OK the device
+// would have answered. The session state is left untouched, since the device
+// never sees the command.
+func (z *ZKTeco) dryRunCommand(cmd uint16, data []byte) []byte {
+	pkt, nextReplyID := z.newPacket(cmd, data)
+
+	logger := z.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Info("zkteco: dry run, command not sent",
+		"device", z.host, "command", commandString(cmd), "packet", hex.EncodeToString(pkt))
+
+	resp := make([]byte, 8)
+	binary.LittleEndian.PutUint16(resp[0:2], CMD_ACK_OK)
+	binary.LittleEndian.PutUint16(resp[4:6], z.sessionID)
+	binary.LittleEndian.PutUint16(resp[6:8], nextReplyID)
+	return resp
+}
@@ -0,0 +1,83 @@
+package zkteco
+
+import "strings"
+
+// EventMask is a set of EF_* event flags, built readably with its With
+// methods:
+//
+//	mask := zkteco.Events().WithAttlog().WithVerify().WithAlarm()
+//	err := zk.ListenEvents(handler, mask.Int(), 0)
+type EventMask int
+
+// Events returns an empty EventMask.
+func Events() EventMask {
+	return 0
+}
+
+// AllEvents holds every event flag.
+const AllEvents EventMask = EF_ATTLOG | EF_FINGER | EF_ENROLLUSER | EF_ENROLLFINGER |
+	EF_BUTTON | EF_UNLOCK | EF_HIDNUM | EF_VERIFY | EF_FPFTR | EF_ALARM |
+	EF_DOOR_CLOSE | EF_EXIT_BUTTON | EF_REMOTE_OPEN
+
+// WithAttlog adds attendance punches (EF_ATTLOG).
+func (m EventMask) WithAttlog() EventMask { return m | EF_ATTLOG }
+
+// WithFinger adds fingers placed on the sensor (EF_FINGER).
+func (m EventMask) WithFinger() EventMask { return m | EF_FINGER }
+
+// WithEnrollUser adds user enrollments (EF_ENROLLUSER).
+func (m EventMask) WithEnrollUser() EventMask { return m | EF_ENROLLUSER }
+
+// WithEnrollFinger adds fingerprint enrollments (EF_ENROLLFINGER).
+func (m EventMask) WithEnrollFinger() EventMask { return m | EF_ENROLLFINGER }
+
+// WithButton adds button presses (EF_BUTTON).
+func (m EventMask) WithButton() EventMask { return m | EF_BUTTON }
+
+// WithUnlock adds door unlocks (EF_UNLOCK).
+func (m EventMask) WithUnlock() EventMask { return m | EF_UNLOCK }
+
+// WithCard adds card swipes (EF_HIDNUM).
+func (m EventMask) WithCard() EventMask { return m | EF_HIDNUM }
+
+// WithVerify adds verifications (EF_VERIFY).
+func (m EventMask) WithVerify() EventMask { return m | EF_VERIFY }
+
+// WithFingerFeature adds fingerprint features (EF_FPFTR).
+func (m EventMask) WithFingerFeature() EventMask { return m | EF_FPFTR }
+
+// WithAlarm adds alarms (EF_ALARM).
+func (m EventMask) WithAlarm() EventMask { return m | EF_ALARM }
+
+// WithDoorClose adds doors closing (EF_DOOR_CLOSE).
+func (m EventMask) WithDoorClose() EventMask { return m | EF_DOOR_CLOSE }
+
+// WithExitButton adds doors opened with the exit button (EF_EXIT_BUTTON).
+func (m EventMask) WithExitButton() EventMask { return m | EF_EXIT_BUTTON }
+
+// WithRemoteOpen adds doors opened remotely (EF_REMOTE_OPEN).
+func (m EventMask) WithRemoteOpen() EventMask { return m | EF_REMOTE_OPEN }
+
+// Has reports whether m holds every flag of flags.
+func (m EventMask) Has(flags int) bool {
+	return int(m)&flags == flags
+}
+
+// Int returns the mask as taken by ListenEvents and the Listener methods.
+func (m EventMask) Int() int {
+	return int(m)
+}
+
+// String lists the event names of the mask, e.g. "attendance|verify".
+func (m EventMask) String() string {
+	var names []string
+	for flag := EventMask(1); flag <= m && flag != 0; flag <<= 1 {
+		if m&flag != 0 {
+			names = append(names, EventName(int(flag)))
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,97 @@
+package zkteco
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ExportAttendances writes records into sink, then flushes it if it has a
+// Flush method, as StreamAttendances does for a download, so history
+// already archived goes through the same serializers. Export formats are
+// RecordSinks: CSVSink and NDJSONSink are built in, and the optional parquet
+// module writes Parquet files for data lakes.
+func ExportAttendances(records []Attendance, sink RecordSink) error {
+	for _, att := range records {
+		if err := sink.OnAttendance(att); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+	}
+	if f, ok := sink.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return fmt.Errorf("export: flush: %w", err)
+		}
+	}
+	return nil
+}
+
+// csvHeader are the columns written by CSVSink, named after the fields of
+// AttendanceDocument.
+var csvHeader = []string{
+	"schema_version", "uid", "user_id", "time", "verify_mode", "punch",
+	"work_code", "reader", "direction", "time_implausible", "device_serial",
+}
+
+// CSVSink is a RecordSink writing attendance records as CSV, with a header
+// row and the columns of AttendanceDocument.
+type CSVSink struct {
+	w          *csv.Writer
+	headerDone bool
+}
+
+// NewCSVSink creates a CSVSink writing to w. Rows are buffered; Flush writes
+// them out.
+func NewCSVSink(w io.Writer) *CSVSink {
+	return &CSVSink{w: csv.NewWriter(w)}
+}
+
+// OnAttendance writes the row of att, after the header row for the first
+// record.
+func (s *CSVSink) OnAttendance(att Attendance) error {
+	if !s.headerDone {
+		s.headerDone = true
+		if err := s.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	doc := att.Document()
+	return s.w.Write([]string{
+		strconv.Itoa(doc.SchemaVersion),
+		strconv.Itoa(doc.UID),
+		doc.UserID,
+		doc.Time,
+		doc.VerifyMode,
+		doc.Punch,
+		strconv.Itoa(doc.WorkCode),
+		strconv.Itoa(doc.Reader),
+		doc.Direction,
+		strconv.FormatBool(doc.TimeImplausible),
+		doc.DeviceSerial,
+	})
+}
+
+// Flush writes the buffered rows to the underlying writer.
+func (s *CSVSink) Flush() error {
+	s.w.Flush()
+	return s.w.Error()
+}
+
+// NDJSONSink is a RecordSink writing attendance records as
+// newline-delimited JSON, one AttendanceDocument per line, like
+// WriteAttendancesNDJSON.
+type NDJSONSink struct {
+	enc *json.Encoder
+}
+
+// NewNDJSONSink creates an NDJSONSink writing to w. Each record is written
+// at once.
+func NewNDJSONSink(w io.Writer) *NDJSONSink {
+	return &NDJSONSink{enc: json.NewEncoder(w)}
+}
+
+// OnAttendance writes the document of att.
+func (s *NDJSONSink) OnAttendance(att Attendance) error {
+	return s.enc.Encode(att.Document())
+}
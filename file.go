@@ -0,0 +1,166 @@
+package zkteco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// File slots accepted by the audio and image upload helpers.
+const (
+	MaxVoiceSlot = 55
+	MaxBellSlot  = 15
+	MaxAdSlot    = 20
+)
+
+// GetDeviceLogFile downloads an internal log or diagnostic file by name,
+// for firmwares that serve files over the data channel.
+func (z *ZKTeco) GetDeviceLogFile(name string) ([]byte, error) {
+	return z.GetDeviceLogFileContext(context.Background(), name)
+}
+
+// GetDeviceLogFileContext is like GetDeviceLogFile but aborts the transfer
+// when ctx is canceled, leaving the connection usable.
+func (z *ZKTeco) GetDeviceLogFileContext(ctx context.Context, name string) (_ []byte, err error) {
+	defer z.recoverInternal("getDeviceLogFileContext", &err)
+	if name == "" {
+		return nil, fmt.Errorf("getDeviceLogFile: empty file name")
+	}
+	allData, err := z.commandData(ctx, CMD_READFILE_DATA, append([]byte(name), 0x00))
+	if err != nil {
+		return nil, fmt.Errorf("getDeviceLogFile %q: %w", name, err)
+	}
+
+	// Strip the 8-byte packet header
+	if len(allData) <= 8 {
+		return nil, nil
+	}
+	content := make([]byte, len(allData)-8)
+	copy(content, allData[8:])
+	return content, nil
+}
+
+// UploadFile uploads a file to the device under name, for firmwares that
+// accept file uploads over the data channel.
+func (z *ZKTeco) UploadFile(name string, content []byte) (err error) {
+	defer z.recoverInternal("uploadFile", &err)
+	if name == "" {
+		return fmt.Errorf("uploadFile: empty file name")
+	}
+	if len(content) == 0 {
+		return fmt.Errorf("uploadFile %q: empty content", name)
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if err := z.profileLocked().supports(CMD_UPDATEFILE); err != nil {
+		return fmt.Errorf("uploadFile %q: %w", name, err)
+	}
+	if err := z.sendLargeData(content); err != nil {
+		return fmt.Errorf("uploadFile %q: %w", name, err)
+	}
+	return z.ackCommandLocked(fmt.Sprintf("uploadFile %q", name), CMD_UPDATEFILE, append([]byte(name), 0x00))
+}
+
+// UploadVoice replaces the voice prompt in slot (0-MaxVoiceSlot) with a WAV
+// file. The slot numbers match the TestVoice indexes.
+func (z *ZKTeco) UploadVoice(slot int, wav []byte) (err error) {
+	defer z.recoverInternal("uploadVoice", &err)
+	if slot < 0 || slot > MaxVoiceSlot {
+		return fmt.Errorf("uploadVoice: slot %d out of range 0-%d", slot, MaxVoiceSlot)
+	}
+	return z.UploadFile(VoiceFileName(slot), wav)
+}
+
+// UploadBell replaces the bell sound in slot (1-MaxBellSlot) with a WAV file.
+func (z *ZKTeco) UploadBell(slot int, wav []byte) (err error) {
+	defer z.recoverInternal("uploadBell", &err)
+	if slot < 1 || slot > MaxBellSlot {
+		return fmt.Errorf("uploadBell: slot %d out of range 1-%d", slot, MaxBellSlot)
+	}
+	return z.UploadFile(BellFileName(slot), wav)
+}
+
+// VoiceFileName returns the device file name of a voice prompt slot.
+func VoiceFileName(slot int) string {
+	return fmt.Sprintf("%d.wav", slot)
+}
+
+// BellFileName returns the device file name of a bell sound slot.
+func BellFileName(slot int) string {
+	return fmt.Sprintf("bell%02d.wav", slot)
+}
+
+// DeleteFile deletes a file from the device.
+func (z *ZKTeco) DeleteFile(name string) (err error) {
+	defer z.recoverInternal("deleteFile", &err)
+	if name == "" {
+		return fmt.Errorf("deleteFile: empty file name")
+	}
+	return z.ackCommand(fmt.Sprintf("deleteFile %q", name), CMD_DELETEFILE, append([]byte(name), 0x00))
+}
+
+// UploadAdImage stores a JPEG advertisement image in slot (1-MaxAdSlot).
+// Screen-equipped terminals rotate these images as the screensaver.
+func (z *ZKTeco) UploadAdImage(slot int, jpg []byte) (err error) {
+	defer z.recoverInternal("uploadAdImage", &err)
+	if slot < 1 || slot > MaxAdSlot {
+		return fmt.Errorf("uploadAdImage: slot %d out of range 1-%d", slot, MaxAdSlot)
+	}
+	return z.UploadFile(AdImageFileName(slot), jpg)
+}
+
+// DeleteAdImage deletes the advertisement image in slot.
+func (z *ZKTeco) DeleteAdImage(slot int) (err error) {
+	defer z.recoverInternal("deleteAdImage", &err)
+	if slot < 1 || slot > MaxAdSlot {
+		return fmt.Errorf("deleteAdImage: slot %d out of range 1-%d", slot, MaxAdSlot)
+	}
+	return z.DeleteFile(AdImageFileName(slot))
+}
+
+// ListAdImages returns the slots that hold an advertisement image.
+// It probes every slot, downloading each stored image once.
+func (z *ZKTeco) ListAdImages() (_ []int, err error) {
+	defer z.recoverInternal("listAdImages", &err)
+	var slots []int
+	for slot := 1; slot <= MaxAdSlot; slot++ {
+		content, err := z.GetDeviceLogFile(AdImageFileName(slot))
+		if err != nil {
+			// Missing files are reported as an error response;
+			// only transport failures abort the scan
+			var netErr net.Error
+			if errors.As(err, &netErr) {
+				return nil, fmt.Errorf("listAdImages: %w", err)
+			}
+			continue
+		}
+		if len(content) > 0 {
+			slots = append(slots, slot)
+		}
+	}
+	return slots, nil
+}
+
+// AdImageFileName returns the device file name of an advertisement image slot.
+func AdImageFileName(slot int) string {
+	return fmt.Sprintf("ad_%d.jpg", slot)
+}
+
+// SetScreensaverTimeout sets the idle time before the screensaver starts.
+// The device stores the value in whole seconds; 0 disables the screensaver.
+func (z *ZKTeco) SetScreensaverTimeout(d time.Duration) error {
+	return z.SetDeviceData("ScreenSaverTime", strconv.Itoa(int(d/time.Second)))
+}
+
+// GetScreensaverTimeout returns the idle time before the screensaver starts.
+func (z *ZKTeco) GetScreensaverTimeout() (_ time.Duration, err error) {
+	defer z.recoverInternal("getScreensaverTimeout", &err)
+	seconds, err := z.getIntOption("ScreenSaverTime")
+	if err != nil {
+		return 0, fmt.Errorf("getScreensaverTimeout: %w", err)
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,237 @@
+package zkteco
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+)
+
+// Template is a fingerprint template of one finger.
+type Template struct {
+	UID         int    `json:"uid"`
+	FingerIndex int    `json:"finger_index"` // 0-9
+	Data        []byte `json:"data"`
+}
+
+// TemplateError reports a template that could not be uploaded.
+type TemplateError struct {
+	UID         int
+	FingerIndex int
+	Err         error
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("uid %d finger %d: %s", e.UID, e.FingerIndex, e.Err)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// TemplateUploadReport summarizes a bulk template upload.
+type TemplateUploadReport struct {
+	Total    int
+	Uploaded int
+	Failed   []*TemplateError
+}
+
+// Template size bounds checked by ValidateTemplate. ZKFinger 9.0 and 10.0
+// templates fall well within them.
+const (
+	MinTemplateSize = 32
+	MaxTemplateSize = 2048
+)
+
+// ErrInvalidTemplate is returned for template data that is not a usable
+// fingerprint template.
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// templateV10Magic follows a 2-byte prefix at the start of ZKFinger 10.0
+// templates.
+var templateV10Magic = []byte("SS21")
+
+// TemplateVersion returns the ZKFinger algorithm version of a template: 10
+// for templates with the ZKFinger 10.0 header, otherwise 9 (ZKFinger 9.0
+// templates have no header to recognise them by).
+func TemplateVersion(data []byte) int {
+	if len(data) >= 6 && bytes.Equal(data[2:6], templateV10Magic) {
+		return 10
+	}
+	return 9
+}
+
+// ValidateTemplate checks template data before it is uploaded, so blobs
+// corrupted or mangled by an external system are rejected on the host
+// rather than stored in the device's matcher. It fails, wrapping
+// ErrInvalidTemplate, for data outside MinTemplateSize-MaxTemplateSize, for
+// erased flash (all 0x00 or all 0xFF), for a ZKFinger 10.0 header that is
+// truncated or not followed by template data, and for templates still
+// base64-encoded, as exported by most HR systems. SetFingerprint,
+// SetAllFingerprints and ProvisionUser validate every template.
+func ValidateTemplate(data []byte) error {
+	switch {
+	case len(data) == 0:
+		return fmt.Errorf("%w: empty", ErrInvalidTemplate)
+	case isBase64Template(data):
+		return fmt.Errorf("%w: data is base64 text, decode it first", ErrInvalidTemplate)
+	case len(data) < MinTemplateSize:
+		return fmt.Errorf("%w: %d bytes, want at least %d", ErrInvalidTemplate, len(data), MinTemplateSize)
+	case len(data) > MaxTemplateSize:
+		return fmt.Errorf("%w: %d bytes, want at most %d", ErrInvalidTemplate, len(data), MaxTemplateSize)
+	case allBytes(data, 0x00) || allBytes(data, 0xFF):
+		return fmt.Errorf("%w: all bytes are 0x%02X (erased)", ErrInvalidTemplate, data[0])
+	}
+	if TemplateVersion(data) == 10 {
+		if data[0] != 'M' {
+			return fmt.Errorf("%w: ZKFinger 10.0 header starts with 0x%02X, want 'M'", ErrInvalidTemplate, data[0])
+		}
+		if allBytes(data[6:], 0x00) {
+			return fmt.Errorf("%w: ZKFinger 10.0 header without template data", ErrInvalidTemplate)
+		}
+	}
+	return nil
+}
+
+// isBase64Template reports whether data is the base64 text of a template
+// rather than the template itself.
+func isBase64Template(data []byte) bool {
+	decoded, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	return err == nil && len(decoded) >= MinTemplateSize
+}
+
+func allBytes(data []byte, b byte) bool {
+	for _, c := range data {
+		if c != b {
+			return false
+		}
+	}
+	return true
+}
+
+// SetFingerprint uploads one fingerprint template for a user.
+func (z *ZKTeco) SetFingerprint(t Template) (err error) {
+	defer z.recoverInternal("setFingerprint", &err)
+	if err := z.writeTemplate(t); err != nil {
+		return fmt.Errorf("setFingerprint: %w", err)
+	}
+	return z.ackCommand("setFingerprint", CMD_REFRESHDATA, nil)
+}
+
+// SetAllFingerprints uploads the templates of many users (keyed by UID), one
+// chunked write per template, then refreshes the device data once. Templates
+// rejected by the device are collected in the report and the upload goes on;
+// a connection failure stops it and is returned with the partial report.
+// progress, if not nil, is called after every template.
+func (z *ZKTeco) SetAllFingerprints(templates map[int][]Template, progress func(done, total int)) (report *TemplateUploadReport, err error) {
+	defer z.recoverInternal("setAllFingerprints", &err)
+	err = z.whileDisabled(func() error {
+		report, err = z.setAllFingerprints(templates, progress)
+		return err
+	})
+	return report, err
+}
+
+func (z *ZKTeco) setAllFingerprints(templates map[int][]Template, progress func(done, total int)) (*TemplateUploadReport, error) {
+	uids := make([]int, 0, len(templates))
+	for uid := range templates {
+		uids = append(uids, uid)
+	}
+	sort.Ints(uids)
+
+	report := &TemplateUploadReport{}
+	for _, uid := range uids {
+		report.Total += len(templates[uid])
+	}
+
+	done := 0
+	for _, uid := range uids {
+		for _, t := range templates[uid] {
+			t.UID = uid
+			if err := z.writeTemplate(t); err != nil {
+				var netErr net.Error
+				if errors.As(err, &netErr) {
+					return report, fmt.Errorf("setAllFingerprints: %w", err)
+				}
+				report.Failed = append(report.Failed, &TemplateError{UID: uid, FingerIndex: t.FingerIndex, Err: err})
+			} else {
+				report.Uploaded++
+			}
+			done++
+			if progress != nil {
+				progress(done, report.Total)
+			}
+		}
+	}
+
+	if err := z.ackCommand("setAllFingerprints", CMD_REFRESHDATA, nil); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// writeTemplate uploads a template to the device buffer and stores it with
+// CMD_TMP_WRITE: uid(2) + finger(1) + valid flag(1) + size(2).
+func (z *ZKTeco) writeTemplate(t Template) error {
+	if t.FingerIndex < 0 || t.FingerIndex > 9 {
+		return fmt.Errorf("finger index %d out of range 0-9", t.FingerIndex)
+	}
+	if err := ValidateTemplate(t.Data); err != nil {
+		return err
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if err := z.sendLargeData(t.Data); err != nil {
+		return err
+	}
+
+	data := make([]byte, 6)
+	binary.LittleEndian.PutUint16(data[0:2], uint16(t.UID))
+	data[2] = byte(t.FingerIndex)
+	data[3] = 1
+	binary.LittleEndian.PutUint16(data[4:6], uint16(len(t.Data)))
+	return z.ackCommandLocked("write template", CMD_TMP_WRITE, data)
+}
+
+// TemplateMatch is a pair of byte-identical templates enrolled under
+// different users.
+type TemplateMatch struct {
+	A Template `json:"a"`
+	B Template `json:"b"`
+}
+
+// FindDuplicateTemplates returns the pairs of byte-identical templates
+// enrolled under different users (keyed by UID), flagging a template copied
+// to several employee IDs. It is not biometric matching: the same finger
+// enrolled again gives a different template, which is not found.
+func FindDuplicateTemplates(templates map[int][]Template) []TemplateMatch {
+	uids := make([]int, 0, len(templates))
+	for uid := range templates {
+		uids = append(uids, uid)
+	}
+	sort.Ints(uids)
+
+	seen := make(map[[sha256.Size]byte][]Template)
+	var matches []TemplateMatch
+	for _, uid := range uids {
+		for _, t := range templates[uid] {
+			if len(t.Data) == 0 {
+				continue
+			}
+			t.UID = uid
+			sum := sha256.Sum256(t.Data)
+			for _, prev := range seen[sum] {
+				if prev.UID != uid {
+					matches = append(matches, TemplateMatch{A: prev, B: t})
+				}
+			}
+			seen[sum] = append(seen[sum], t)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,214 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0mithun/go-zkteco"
+)
+
+// Kinds of ProgressEvent.
+const (
+	DeviceStarted   = "started"
+	DeviceSucceeded = "succeeded"
+	DeviceFailed    = "failed"
+)
+
+// ProgressEvent reports the progress of a bulk operation on one device.
+type ProgressEvent struct {
+	Kind    string    `json:"kind"` // DeviceStarted, DeviceSucceeded or DeviceFailed
+	Device  Device    `json:"device"`
+	Err     error     `json:"-"` // set for DeviceFailed
+	Done    int       `json:"done"`
+	Total   int       `json:"total"`
+	Percent float64   `json:"percent"` // devices finished, 0-100
+	Time    time.Time `json:"time"`
+}
+
+// BulkOptions configures a bulk operation.
+type BulkOptions struct {
+	// Concurrency is the number of devices worked on at once. Default is 10.
+	Concurrency int
+	// Interval is the minimum time between two device connections, capping
+	// the rate at which devices are contacted. Default is 0 (no cap).
+	Interval time.Duration
+	// Progress, if set, receives a ProgressEvent when each device starts
+	// and finishes. Sends block, so the channel must be drained; it is not
+	// closed.
+	Progress chan<- ProgressEvent
+	// ClientOptions are passed to Device.Client.
+	ClientOptions []zkteco.Option
+	// Registry, if set, gets the LastSeen time of each device reached.
+	Registry *Registry
+	// RateLimiter, if set, limits the traffic of the clients; share one
+	// between the bulk operations, clock syncs and queues of a fleet.
+	RateLimiter *RateLimiter
+	// Clock paces the operation and timestamps its events, and is given to
+	// the clients (see zkteco.WithClock). Default is zkteco.SystemClock.
+	Clock zkteco.Clock
+}
+
+// clock returns the clock of the operation.
+func (o BulkOptions) clock() zkteco.Clock {
+	if o.Clock == nil {
+		return zkteco.SystemClock
+	}
+	return o.Clock
+}
+
+// Result is the outcome of a bulk operation on one device.
+type Result struct {
+	Device   Device        `json:"device"`
+	Err      error         `json:"-"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Bulk connects to each device, runs op and disconnects, with the
+// concurrency and rate limits of opts. It returns one Result per device, in
+// the order of devices. Devices not started when ctx is canceled fail with
+// ctx.Err().
+func Bulk(ctx context.Context, devices []Device, opts BulkOptions, op func(ctx context.Context, zk *zkteco.ZKTeco) error) []Result {
+	return bulk(ctx, devices, opts, func(ctx context.Context, _ Device, zk *zkteco.ZKTeco) error {
+		return op(ctx, zk)
+	})
+}
+
+// bulk is Bulk with the device passed to op.
+func bulk(ctx context.Context, devices []Device, opts BulkOptions, op func(ctx context.Context, d Device, zk *zkteco.ZKTeco) error) []Result {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 10
+	}
+	clock := opts.clock()
+
+	results := make([]Result, len(devices))
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	report := func(kind string, d Device, err error) {
+		if opts.Progress == nil {
+			return
+		}
+		mu.Lock()
+		if kind != DeviceStarted {
+			done++
+		}
+		ev := ProgressEvent{
+			Kind:    kind,
+			Device:  d,
+			Err:     err,
+			Done:    done,
+			Total:   len(devices),
+			Percent: 100 * float64(done) / float64(len(devices)),
+			Time:    clock.Now(),
+		}
+		mu.Unlock()
+		select {
+		case opts.Progress <- ev:
+		case <-ctx.Done():
+		}
+	}
+
+	sem := make(chan struct{}, opts.Concurrency)
+	var lastStart time.Time
+	for i, d := range devices {
+		results[i].Device = d
+
+		if err := waitTurn(ctx, clock, sem, opts.Interval, &lastStart); err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, d Device) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			report(DeviceStarted, d, nil)
+			start := clock.Now()
+			err := runOnDevice(ctx, d, opts, op)
+			results[i].Duration = clock.Now().Sub(start)
+			results[i].Err = err
+			if err != nil {
+				report(DeviceFailed, d, err)
+			} else {
+				report(DeviceSucceeded, d, nil)
+			}
+		}(i, d)
+	}
+	wg.Wait()
+	return results
+}
+
+// waitTurn takes a concurrency slot and waits until interval has passed
+// since the previous start.
+func waitTurn(ctx context.Context, clock zkteco.Clock, sem chan struct{}, interval time.Duration, lastStart *time.Time) error {
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if wait := lastStart.Add(interval).Sub(clock.Now()); wait > 0 {
+		timer := clock.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			<-sem
+			return ctx.Err()
+		}
+	}
+	*lastStart = clock.Now()
+	return nil
+}
+
+func runOnDevice(ctx context.Context, d Device, opts BulkOptions, op func(ctx context.Context, d Device, zk *zkteco.ZKTeco) error) error {
+	clientOpts := opts.ClientOptions
+	if opts.RateLimiter != nil {
+		clientOpts = append(clientOpts[:len(clientOpts):len(clientOpts)], opts.RateLimiter.Option(d))
+	}
+	if opts.Clock != nil {
+		clientOpts = append(clientOpts[:len(clientOpts):len(clientOpts)], zkteco.WithClock(opts.Clock))
+	}
+	zk, err := d.Client(clientOpts...)
+	if err != nil {
+		return err
+	}
+	if err := zk.Connect(); err != nil {
+		return err
+	}
+	defer zk.Disconnect()
+
+	if opts.Registry != nil {
+		opts.Registry.Touch(d.Tenant, d.Serial, opts.clock().Now())
+	}
+	return op(ctx, d, zk)
+}
+
+// SyncTime sets the clock of each device to the host time.
+func SyncTime(ctx context.Context, devices []Device, opts BulkOptions) []Result {
+	return Bulk(ctx, devices, opts, func(ctx context.Context, zk *zkteco.ZKTeco) error {
+		return zk.SetTime(zk.Clock().Now())
+	})
+}
+
+// UploadUsers creates or updates users on each device, with the device
+// disabled during the upload.
+func UploadUsers(ctx context.Context, devices []Device, users []zkteco.User, opts BulkOptions) []Result {
+	return Bulk(ctx, devices, opts, func(ctx context.Context, zk *zkteco.ZKTeco) error {
+		return zk.WithDeviceDisabled(ctx, func() error {
+			for _, u := range users {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := zk.SetUser(u.UID, u.UserID, u.Name, u.Password, u.Role, u.CardNo); err != nil {
+					return fmt.Errorf("user %s: %w", u.UserID, err)
+				}
+			}
+			return nil
+		})
+	})
+}
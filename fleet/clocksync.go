@@ -0,0 +1,137 @@
+package fleet
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/0mithun/go-zkteco"
+)
+
+// ClockSyncConfig configures a ClockSync.
+type ClockSyncConfig struct {
+	// Source is the reference clock, such as zkteco.NTPSource. Default is
+	// the clock of Bulk, zkteco.HostClock unless set.
+	Source zkteco.TimeSource
+	// Tolerance is the drift left uncorrected. Default is 2 seconds.
+	Tolerance time.Duration
+	// Interval between two rounds over the fleet. Default is 1 hour.
+	Interval time.Duration
+	// HistorySize is the number of checks kept per device. Default is 100.
+	HistorySize int
+	// Bulk sets the concurrency and rate limits of a round, and the clock
+	// of the sync.
+	Bulk BulkOptions
+	// OnRound, if set, is called with the results of each round.
+	OnRound func([]Result)
+	// OnError, if set, is called when the reference clock cannot be read;
+	// the round is skipped.
+	OnError func(error)
+}
+
+// ClockSync keeps the clocks of fleet devices within a tolerance of a
+// reference clock, checking them every interval and keeping a history of
+// the checks and corrections of each device.
+type ClockSync struct {
+	devices func() []Device
+	cfg     ClockSyncConfig
+
+	mu      sync.Mutex
+	history map[deviceKey][]zkteco.ClockCheck
+}
+
+// NewClockSync creates a ClockSync for the devices returned by devices,
+// called at the start of each round, e.g. func() []Device { return
+// reg.Devices("") }.
+func NewClockSync(devices func() []Device, cfg ClockSyncConfig) *ClockSync {
+	if cfg.Source == nil {
+		cfg.Source = zkteco.ClockSource(cfg.Bulk.clock())
+	}
+	if cfg.Tolerance <= 0 {
+		cfg.Tolerance = 2 * time.Second
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.HistorySize <= 0 {
+		cfg.HistorySize = 100
+	}
+	return &ClockSync{
+		devices: devices,
+		cfg:     cfg,
+		history: make(map[deviceKey][]zkteco.ClockCheck),
+	}
+}
+
+// Run syncs the fleet every interval until ctx is canceled, starting with an
+// immediate round. Use zkteco.RunComponent to run it in a Service.
+func (c *ClockSync) Run(ctx context.Context) error {
+	ticker := c.cfg.Bulk.clock().NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		results, err := c.SyncOnce(ctx)
+		if err != nil {
+			if c.cfg.OnError != nil {
+				c.cfg.OnError(err)
+			}
+		} else if c.cfg.OnRound != nil {
+			c.cfg.OnRound(results)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+// SyncOnce checks every device once, correcting the clocks that drifted
+// beyond the tolerance. The reference clock is read once for the round.
+func (c *ClockSync) SyncOnce(ctx context.Context) ([]Result, error) {
+	ref, err := c.cfg.Source.Now()
+	if err != nil {
+		return nil, err
+	}
+	clock := c.cfg.Bulk.clock()
+	src := offsetClock{clock, ref.Sub(clock.Now())}
+
+	return bulk(ctx, c.devices(), c.cfg.Bulk, func(ctx context.Context, d Device, zk *zkteco.ZKTeco) error {
+		check, err := zk.CheckClockWith(src, c.cfg.Tolerance)
+		if !check.CheckedAt.IsZero() {
+			c.record(d, check)
+		}
+		return err
+	}), nil
+}
+
+// record appends check to the history of d, dropping the oldest checks
+// beyond the history size.
+func (c *ClockSync) record(d Device, check zkteco.ClockCheck) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := deviceKey{d.Tenant, d.Serial}
+	h := append(c.history[key], check)
+	if len(h) > c.cfg.HistorySize {
+		h = h[len(h)-c.cfg.HistorySize:]
+	}
+	c.history[key] = h
+}
+
+// offsetClock is a TimeSource running at a fixed offset from a clock.
+type offsetClock struct {
+	clock  zkteco.Clock
+	offset time.Duration
+}
+
+func (o offsetClock) Now() (time.Time, error) {
+	return o.clock.Now().Add(o.offset), nil
+}
+
+// History returns the checks of a device, oldest first.
+func (c *ClockSync) History(tenant, serial string) []zkteco.ClockCheck {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]zkteco.ClockCheck(nil), c.history[deviceKey{tenant, serial}]...)
+}
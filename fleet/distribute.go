@@ -0,0 +1,206 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/0mithun/go-zkteco"
+)
+
+// DistributeOptions configures DistributeTemplates.
+type DistributeOptions struct {
+	BulkOptions
+	// RemoveUsers removes from the targets the users the source does not
+	// have. By default they are left alone.
+	RemoveUsers bool
+}
+
+// Distribution is the outcome of DistributeTemplates on one target.
+type Distribution struct {
+	Result
+	UsersAdded        int                     `json:"users_added"`
+	UsersUpdated      int                     `json:"users_updated"`
+	UsersRemoved      int                     `json:"users_removed"`
+	TemplatesUploaded int                     `json:"templates_uploaded"`
+	TemplatesSkipped  int                     `json:"templates_skipped"` // already identical
+	TemplatesFailed   []*zkteco.TemplateError `json:"-"`                 // rejected by the device
+}
+
+// Source is the master data read by DistributeTemplates.
+type Source struct {
+	Users     []zkteco.User             `json:"users"`
+	Templates map[string]map[int][]byte `json:"templates"` // by user ID, then finger index
+}
+
+// ReadSource reads the users of the master terminal source, and their
+// fingerprint templates with the device disabled.
+func ReadSource(ctx context.Context, source Device, opts BulkOptions) (*Source, error) {
+	src := &Source{Templates: make(map[string]map[int][]byte)}
+	err := runOnDevice(ctx, source, opts, func(ctx context.Context, _ Device, zk *zkteco.ZKTeco) error {
+		users, err := zk.GetUsersContext(ctx)
+		if err != nil {
+			return err
+		}
+		src.Users = users
+		return zk.WithDeviceDisabled(ctx, func() error {
+			for _, u := range users {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				templates, err := zk.GetFingerprints(u.UID)
+				if err != nil {
+					return fmt.Errorf("user %s: %w", u.UserID, err)
+				}
+				if len(templates) > 0 {
+					src.Templates[u.UserID] = templates
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read source %s: %w", source.Serial, err)
+	}
+	return src, nil
+}
+
+// DistributeTemplates copies the users and fingerprint templates of the
+// master terminal source to each of targets (e.g. Registry.DevicesWithTag),
+// with the concurrency and rate limits of opts. Users are matched by user
+// ID: those already on a target keep their UID there, missing ones are
+// added with a free UID. Templates already identical on a target are
+// skipped, so a repeated run only uploads what changed. Templates a target
+// has for fingers the source has not enrolled are left alone. The source
+// itself is skipped if it is among the targets.
+//
+// It returns one Distribution per target, in the order of targets; a
+// template a target rejects does not fail it (see TemplatesFailed). The
+// error is set, and no target is touched, if the source cannot be read.
+func DistributeTemplates(ctx context.Context, source Device, targets []Device, opts DistributeOptions) ([]Distribution, error) {
+	src, err := ReadSource(ctx, source, opts.BulkOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []Device
+	for _, d := range targets {
+		if d.Address != source.Address && (d.Serial == "" || d.Serial != source.Serial || d.Tenant != source.Tenant) {
+			filtered = append(filtered, d)
+		}
+	}
+	return DistributeSource(ctx, src, filtered, opts), nil
+}
+
+// DistributeSource is DistributeTemplates with the source already read,
+// e.g. from a backup of the master terminal or once for several batches of
+// targets.
+func DistributeSource(ctx context.Context, src *Source, targets []Device, opts DistributeOptions) []Distribution {
+	dists := make([]Distribution, len(targets))
+	// Indexes of the targets by identity; a device listed twice gets a
+	// Distribution for each entry
+	type targetKey struct{ tenant, serial, address string }
+	index := make(map[targetKey][]int, len(targets))
+	for i, d := range targets {
+		k := targetKey{d.Tenant, d.Serial, d.Address}
+		index[k] = append(index[k], i)
+	}
+	var mu sync.Mutex
+
+	results := bulk(ctx, targets, opts.BulkOptions, func(ctx context.Context, d Device, zk *zkteco.ZKTeco) error {
+		var dist Distribution
+		err := distribute(ctx, zk, src, opts.RemoveUsers, &dist)
+		mu.Lock()
+		k := targetKey{d.Tenant, d.Serial, d.Address}
+		dists[index[k][0]] = dist
+		index[k] = index[k][1:]
+		mu.Unlock()
+		return err
+	})
+	for i, r := range results {
+		dists[i].Result = r
+	}
+	return dists
+}
+
+// distribute brings the users and templates of zk in line with src.
+func distribute(ctx context.Context, zk *zkteco.ZKTeco, src *Source, remove bool, dist *Distribution) error {
+	current, err := zk.GetUsersContext(ctx)
+	if err != nil {
+		return err
+	}
+	// Source UIDs mean nothing on the target: new users get free ones
+	desired := make([]zkteco.User, len(src.Users))
+	for i, u := range src.Users {
+		u.UID = 0
+		desired[i] = u
+	}
+	changes := zkteco.DiffUserLists(current, desired, remove)
+
+	uids := make(map[string]int, len(current))
+	added := make(map[string]bool)
+	for _, u := range current {
+		uids[u.UserID] = u.UID
+	}
+	for _, c := range changes {
+		if c.Kind == zkteco.UserAdd {
+			uids[c.User.UserID] = c.User.UID
+			added[c.User.UserID] = true
+		}
+	}
+
+	return zk.WithDeviceDisabled(ctx, func() error {
+		if len(changes) > 0 {
+			applied, err := zk.ApplyUserChanges(changes)
+			for _, c := range changes[:applied] {
+				switch c.Kind {
+				case zkteco.UserAdd:
+					dist.UsersAdded++
+				case zkteco.UserUpdate:
+					dist.UsersUpdated++
+				case zkteco.UserRemove:
+					dist.UsersRemoved++
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		upload := make(map[int][]zkteco.Template)
+		for _, u := range src.Users {
+			templates := src.Templates[u.UserID]
+			if len(templates) == 0 {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			uid := uids[u.UserID]
+			var existing map[int][]byte
+			if !added[u.UserID] {
+				if existing, err = zk.GetFingerprints(uid); err != nil {
+					return fmt.Errorf("user %s: %w", u.UserID, err)
+				}
+			}
+			for finger, data := range templates {
+				if bytes.Equal(existing[finger], data) {
+					dist.TemplatesSkipped++
+					continue
+				}
+				upload[uid] = append(upload[uid], zkteco.Template{UID: uid, FingerIndex: finger, Data: data})
+			}
+		}
+		if len(upload) == 0 {
+			return nil
+		}
+
+		report, err := zk.SetAllFingerprints(upload, nil)
+		if report != nil {
+			dist.TemplatesUploaded = report.Uploaded
+			dist.TemplatesFailed = report.Failed
+		}
+		return err
+	})
+}
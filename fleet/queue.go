@@ -0,0 +1,252 @@
+package fleet
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/0mithun/go-zkteco"
+)
+
+// Kinds of Op.
+const (
+	OpSetUser    = "set_user"
+	OpRemoveUser = "remove_user"
+	OpSetTime    = "set_time"
+)
+
+// Op is a write operation queued for a device.
+type Op struct {
+	Kind string       `json:"kind"` // OpSetUser, OpRemoveUser or OpSetTime
+	User *zkteco.User `json:"user,omitempty"`
+	UID  int          `json:"uid,omitempty"`
+}
+
+// SetUserOp creates or updates u.
+func SetUserOp(u zkteco.User) Op {
+	return Op{Kind: OpSetUser, User: &u}
+}
+
+// RemoveUserOp removes the user uid.
+func RemoveUserOp(uid int) Op {
+	return Op{Kind: OpRemoveUser, UID: uid}
+}
+
+// SetTimeOp sets the device clock to the host time at execution.
+func SetTimeOp() Op {
+	return Op{Kind: OpSetTime}
+}
+
+// run executes the operation on zk.
+func (op Op) run(zk *zkteco.ZKTeco) error {
+	switch op.Kind {
+	case OpSetUser:
+		if op.User == nil {
+			return fmt.Errorf("%s: no user", op.Kind)
+		}
+		u := op.User
+		return zk.SetUser(u.UID, u.UserID, u.Name, u.Password, u.Role, u.CardNo)
+	case OpRemoveUser:
+		return zk.RemoveUser(op.UID)
+	case OpSetTime:
+		return zk.SetTime(zk.Clock().Now())
+	default:
+		return fmt.Errorf("unknown operation %q", op.Kind)
+	}
+}
+
+// QueuedOp is an Op waiting in a Queue.
+type QueuedOp struct {
+	ID        int64     `json:"id"`
+	Tenant    string    `json:"tenant"`
+	Serial    string    `json:"serial"`
+	Op        Op        `json:"op"`
+	QueuedAt  time.Time `json:"queued_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"` // zero means never
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// QueueConfig configures a Queue.
+type QueueConfig struct {
+	// TTL is how long an operation is kept before it expires. Default is 0
+	// (never).
+	TTL time.Duration
+	// Interval between two attempts to reach the devices with pending
+	// operations. Default is 1 minute.
+	Interval time.Duration
+	// Bulk sets the concurrency and rate limits of an attempt, and the clock
+	// of the queue.
+	Bulk BulkOptions
+	// OnDone, if set, is called for each operation executed on its device.
+	OnDone func(QueuedOp)
+	// OnExpired, if set, is called for each operation dropped on expiry.
+	OnExpired func(QueuedOp)
+}
+
+// Queue holds write operations for devices that cannot be reached and runs
+// them, in order, once the devices are back. An operation that fails stays
+// at the head of its device's queue, with its error, until it succeeds or
+// expires.
+type Queue struct {
+	cfg QueueConfig
+
+	mu     sync.Mutex
+	ops    []QueuedOp
+	nextID int64
+}
+
+// NewQueue creates an empty Queue.
+func NewQueue(cfg QueueConfig) *Queue {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Minute
+	}
+	return &Queue{cfg: cfg}
+}
+
+// Enqueue queues op for d and returns its ID.
+func (q *Queue) Enqueue(d Device, op Op) int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.nextID++
+	qo := QueuedOp{
+		ID:       q.nextID,
+		Tenant:   d.Tenant,
+		Serial:   d.Serial,
+		Op:       op,
+		QueuedAt: q.cfg.Bulk.clock().Now(),
+	}
+	if q.cfg.TTL > 0 {
+		qo.ExpiresAt = qo.QueuedAt.Add(q.cfg.TTL)
+	}
+	q.ops = append(q.ops, qo)
+	return qo.ID
+}
+
+// Pending returns the operations queued for the device, or for all devices
+// of tenant if serial is empty, or for all devices if both are empty, in
+// queue order.
+func (q *Queue) Pending(tenant, serial string) []QueuedOp {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var list []QueuedOp
+	for _, qo := range q.ops {
+		if (tenant == "" || qo.Tenant == tenant) && (serial == "" || qo.Serial == serial) {
+			list = append(list, qo)
+		}
+	}
+	return list
+}
+
+// Cancel removes the operation id from the queue. It reports whether it was
+// queued.
+func (q *Queue) Cancel(id int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, qo := range q.ops {
+		if qo.ID == id {
+			q.ops = append(q.ops[:i], q.ops[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Expire drops the operations expired at now and returns them.
+func (q *Queue) Expire(now time.Time) []QueuedOp {
+	q.mu.Lock()
+	var expired []QueuedOp
+	kept := q.ops[:0]
+	for _, qo := range q.ops {
+		if !qo.ExpiresAt.IsZero() && !now.Before(qo.ExpiresAt) {
+			expired = append(expired, qo)
+		} else {
+			kept = append(kept, qo)
+		}
+	}
+	q.ops = kept
+	q.mu.Unlock()
+
+	if q.cfg.OnExpired != nil {
+		for _, qo := range expired {
+			q.cfg.OnExpired(qo)
+		}
+	}
+	return expired
+}
+
+// Flush runs the operations queued for d on zk, a connected client of d, in
+// order. It stops at the first failure, which is recorded on the operation
+// and returned.
+func (q *Queue) Flush(ctx context.Context, d Device, zk *zkteco.ZKTeco) error {
+	for _, qo := range q.Pending(d.Tenant, d.Serial) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := qo.Op.run(zk)
+		q.finish(qo.ID, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish records the outcome of the operation id, unless it was canceled
+// meanwhile: on success it is removed from the queue.
+func (q *Queue) finish(id int64, err error) {
+	q.mu.Lock()
+	var done *QueuedOp
+	for i := range q.ops {
+		if q.ops[i].ID != id {
+			continue
+		}
+		q.ops[i].Attempts++
+		if err != nil {
+			q.ops[i].LastError = err.Error()
+			break
+		}
+		qo := q.ops[i]
+		done = &qo
+		q.ops = append(q.ops[:i], q.ops[i+1:]...)
+		break
+	}
+	q.mu.Unlock()
+
+	if done != nil && q.cfg.OnDone != nil {
+		q.cfg.OnDone(*done)
+	}
+}
+
+// Run tries every interval, until ctx is canceled, to reach the devices with
+// pending operations and flush their queues, after dropping expired
+// operations. devices returns the known devices, e.g. func() []Device {
+// return reg.Devices("") }. Use zkteco.RunComponent to run it in a Service.
+func (q *Queue) Run(ctx context.Context, devices func() []Device) error {
+	clock := q.cfg.Bulk.clock()
+	ticker := clock.NewTicker(q.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		q.Expire(clock.Now())
+
+		var targets []Device
+		for _, d := range devices() {
+			if len(q.Pending(d.Tenant, d.Serial)) > 0 {
+				targets = append(targets, d)
+			}
+		}
+		if len(targets) > 0 {
+			bulk(ctx, targets, q.cfg.Bulk, func(ctx context.Context, d Device, zk *zkteco.ZKTeco) error {
+				return q.Flush(ctx, d, zk)
+			})
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package fleet
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/0mithun/go-zkteco"
+)
+
+// RateLimit caps the traffic of a set of devices. Zero fields mean no limit.
+type RateLimit struct {
+	// BytesPerSecond caps the bytes sent and received, e.g. to keep bulk
+	// downloads from saturating an uplink shared by many devices.
+	BytesPerSecond int `json:"bytes_per_second,omitempty"`
+	// CommandsPerSecond caps the packets sent to the devices.
+	CommandsPerSecond float64 `json:"commands_per_second,omitempty"`
+}
+
+// RateLimiter is a token-bucket limiter shared by the clients of many
+// devices, so that bulk operations across a fleet stay within the capacity
+// of a shared link (such as an FRP server all devices are reached through).
+// It has a global limit and optional limits for the devices of a tag, e.g.
+// one per site; a device is held to the global limit and to the limit of
+// each of its tags. Buckets hold one second of traffic. It is safe for
+// concurrent use.
+type RateLimiter struct {
+	global *rateBuckets
+
+	mu   sync.Mutex
+	tags map[[2]string]*rateBuckets
+}
+
+// NewRateLimiter creates a RateLimiter with the global limit global.
+func NewRateLimiter(global RateLimit) *RateLimiter {
+	return &RateLimiter{
+		global: newRateBuckets(global),
+		tags:   make(map[[2]string]*rateBuckets),
+	}
+}
+
+// SetTagLimit limits the combined traffic of the devices whose tag key has
+// value, in addition to the global limit. It applies to the clients created
+// afterwards.
+func (l *RateLimiter) SetTagLimit(key, value string, limit RateLimit) *RateLimiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tags[[2]string{key, value}] = newRateBuckets(limit)
+	return l
+}
+
+// Option returns a client option that makes the connections to d respect
+// the limiter, for Device.Client. It wraps the network connections of the
+// built-in transports (see zkteco.WithDialerWrapper), keeping the dialer or
+// connection of the WithDialer or WithConn options before it, so it must
+// come after them. Transports installed with WithTransport are not limited.
+func (l *RateLimiter) Option(d Device) zkteco.Option {
+	return zkteco.WithDialerWrapper(func(next zkteco.DialFunc) zkteco.DialFunc {
+		return l.Dialer(d, next)
+	})
+}
+
+// Dialer wraps dial (net.Dialer if nil) so that the connections it opens to
+// d respect the limiter: reads and writes wait for byte tokens, and each
+// write, which carries one packet, for a command token. A wait ends early
+// with os.ErrDeadlineExceeded when the deadline of the connection passes.
+func (l *RateLimiter) Dialer(d Device, dial zkteco.DialFunc) zkteco.DialFunc {
+	buckets := []*rateBuckets{l.global}
+	l.mu.Lock()
+	for k, v := range d.Tags {
+		if b, ok := l.tags[[2]string{k, v}]; ok {
+			buckets = append(buckets, b)
+		}
+	}
+	l.mu.Unlock()
+
+	if dial == nil {
+		var nd net.Dialer
+		dial = nd.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &limitedConn{Conn: conn, buckets: buckets}, nil
+	}
+}
+
+// limitedConn is a connection held to the limits of buckets.
+type limitedConn struct {
+	net.Conn
+	buckets []*rateBuckets
+
+	readDeadline, writeDeadline connDeadline
+	readDebt                    time.Duration // wait owed by the last read
+}
+
+func (c *limitedConn) Write(p []byte) (int, error) {
+	var wait time.Duration
+	for _, b := range c.buckets {
+		wait = max(wait, b.commands.reserve(1), b.bytes.reserve(float64(len(p))))
+	}
+	if err := c.writeDeadline.wait(wait); err != nil {
+		return 0, err
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *limitedConn) Read(p []byte) (int, error) {
+	// Received bytes are only known after the read: the wait they cost
+	// delays the next read, which slows the sender down.
+	wait := c.readDebt
+	c.readDebt = 0
+	if err := c.readDeadline.wait(wait); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(p)
+	for _, b := range c.buckets {
+		c.readDebt = max(c.readDebt, b.bytes.reserve(float64(n)))
+	}
+	return n, err
+}
+
+func (c *limitedConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *limitedConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *limitedConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// connDeadline is a deadline of a limitedConn, which may change during a
+// wait, e.g. when the client interrupts a pending read.
+type connDeadline struct {
+	mu      sync.Mutex
+	t       time.Time
+	changed chan struct{} // closed when t changes
+}
+
+func (d *connDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.t = t
+	if d.changed != nil {
+		close(d.changed)
+		d.changed = nil
+	}
+}
+
+func (d *connDeadline) get() (time.Time, <-chan struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.changed == nil {
+		d.changed = make(chan struct{})
+	}
+	return d.t, d.changed
+}
+
+// wait sleeps for wait, or fails with os.ErrDeadlineExceeded when the
+// deadline passes first.
+func (d *connDeadline) wait(wait time.Duration) error {
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		deadline, changed := d.get()
+		var expiry *time.Timer
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			expiry = time.NewTimer(time.Until(deadline))
+			expired = expiry.C
+		}
+		var err error
+		again := false
+		select {
+		case <-timer.C:
+		case <-expired:
+			err = os.ErrDeadlineExceeded
+		case <-changed:
+			again = true
+		}
+		if expiry != nil {
+			expiry.Stop()
+		}
+		if !again {
+			return err
+		}
+	}
+}
+
+// rateBuckets are the buckets of a RateLimit.
+type rateBuckets struct {
+	bytes    *tokenBucket
+	commands *tokenBucket
+}
+
+func newRateBuckets(limit RateLimit) *rateBuckets {
+	return &rateBuckets{
+		bytes:    newTokenBucket(float64(limit.BytesPerSecond)),
+		commands: newTokenBucket(limit.CommandsPerSecond),
+	}
+}
+
+// tokenBucket refills at rate tokens per second, up to one second's worth.
+// A rate of 0 or less means no limit.
+type tokenBucket struct {
+	rate float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64) *tokenBucket {
+	return &tokenBucket{rate: rate, tokens: rate, last: time.Now()}
+}
+
+// reserve takes n tokens and returns how long to wait before using them.
+// The bucket may go into debt, so requests larger than the bucket pass
+// after a proportional wait.
+func (b *tokenBucket) reserve(n float64) time.Duration {
+	if b.rate <= 0 || n <= 0 {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens = min(b.rate, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,188 @@
+// Package fleet manages many ZKTeco devices: a persistent registry of the
+// devices of several tenants, shared by the pollers and gateways built on it.
+package fleet
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/0mithun/go-zkteco"
+)
+
+// Device is a registry entry. Devices are identified by tenant and serial
+// number.
+type Device struct {
+	Tenant   string            `json:"tenant"`
+	Serial   string            `json:"serial"`
+	Address  string            `json:"address"`            // host:port
+	Protocol string            `json:"protocol,omitempty"` // "tcp" or "udp"; default "udp"
+	Password int               `json:"password,omitempty"` // comm key
+	Tags     map[string]string `json:"tags,omitempty"`
+	LastSeen time.Time         `json:"last_seen,omitempty"`
+}
+
+// Client returns a client for the device with its address, protocol and
+// password, followed by opts.
+func (d Device) Client(opts ...zkteco.Option) (*zkteco.ZKTeco, error) {
+	host, portStr, err := net.SplitHostPort(d.Address)
+	if err != nil {
+		return nil, fmt.Errorf("device %s: address: %w", d.Serial, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("device %s: port %q: %w", d.Serial, portStr, err)
+	}
+
+	base := []zkteco.Option{zkteco.WithPassword(d.Password)}
+	if d.Protocol != "" {
+		base = append(base, zkteco.WithProtocol(d.Protocol))
+	}
+	return zkteco.NewZKTeco(host, port, append(base, opts...)...), nil
+}
+
+// clone returns a copy of d that shares no memory with it.
+func (d Device) clone() Device {
+	d.Tags = maps.Clone(d.Tags)
+	return d
+}
+
+// ErrNotFound is returned for devices that are not registered.
+var ErrNotFound = errors.New("device not registered")
+
+// Store persists registry entries. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// List returns all entries.
+	List() ([]Device, error)
+	// Put creates or replaces the entry of d.Tenant and d.Serial.
+	Put(d Device) error
+	// Delete removes an entry; deleting a missing entry is not an error.
+	Delete(tenant, serial string) error
+}
+
+// Registry is the set of managed devices, cached in memory and written
+// through to a Store. It is safe for concurrent use.
+type Registry struct {
+	store Store
+
+	mu      sync.RWMutex
+	devices map[deviceKey]Device
+}
+
+type deviceKey struct {
+	tenant, serial string
+}
+
+// NewRegistry loads the devices from store.
+func NewRegistry(store Store) (*Registry, error) {
+	list, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("fleet: load registry: %w", err)
+	}
+	r := &Registry{store: store, devices: make(map[deviceKey]Device, len(list))}
+	for _, d := range list {
+		r.devices[deviceKey{d.Tenant, d.Serial}] = d
+	}
+	return r, nil
+}
+
+// Register adds a device or replaces its entry. The serial number and
+// address are required.
+func (r *Registry) Register(d Device) error {
+	if d.Serial == "" || d.Address == "" {
+		return fmt.Errorf("fleet: register: serial and address are required")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d = d.clone()
+	if err := r.store.Put(d); err != nil {
+		return fmt.Errorf("fleet: register %s: %w", d.Serial, err)
+	}
+	r.devices[deviceKey{d.Tenant, d.Serial}] = d
+	return nil
+}
+
+// Deregister removes a device.
+func (r *Registry) Deregister(tenant, serial string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := deviceKey{tenant, serial}
+	if _, ok := r.devices[key]; !ok {
+		return fmt.Errorf("fleet: deregister %s: %w", serial, ErrNotFound)
+	}
+	if err := r.store.Delete(tenant, serial); err != nil {
+		return fmt.Errorf("fleet: deregister %s: %w", serial, err)
+	}
+	delete(r.devices, key)
+	return nil
+}
+
+// Get returns the entry of a device.
+func (r *Registry) Get(tenant, serial string) (Device, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	d, ok := r.devices[deviceKey{tenant, serial}]
+	if !ok {
+		return Device{}, fmt.Errorf("fleet: %s: %w", serial, ErrNotFound)
+	}
+	return d.clone(), nil
+}
+
+// Devices returns the devices of tenant, or of all tenants if tenant is
+// empty, sorted by tenant and serial number.
+func (r *Registry) Devices(tenant string) []Device {
+	return r.filter(func(d Device) bool {
+		return tenant == "" || d.Tenant == tenant
+	})
+}
+
+// DevicesWithTag returns the devices, of all tenants, whose tag key is set to
+// value.
+func (r *Registry) DevicesWithTag(key, value string) []Device {
+	return r.filter(func(d Device) bool {
+		v, ok := d.Tags[key]
+		return ok && v == value
+	})
+}
+
+func (r *Registry) filter(keep func(Device) bool) []Device {
+	r.mu.RLock()
+	var list []Device
+	for _, d := range r.devices {
+		if keep(d) {
+			list = append(list, d.clone())
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Tenant != list[j].Tenant {
+			return list[i].Tenant < list[j].Tenant
+		}
+		return list[i].Serial < list[j].Serial
+	})
+	return list
+}
+
+// Touch records that the device was reached at t.
+func (r *Registry) Touch(tenant, serial string, t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := deviceKey{tenant, serial}
+	d, ok := r.devices[key]
+	if !ok {
+		return fmt.Errorf("fleet: touch %s: %w", serial, ErrNotFound)
+	}
+	d.LastSeen = t
+	if err := r.store.Put(d); err != nil {
+		return fmt.Errorf("fleet: touch %s: %w", serial, err)
+	}
+	r.devices[key] = d
+	return nil
+}
@@ -0,0 +1,141 @@
+package fleet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps entries in memory only, for tests and
+// registries rebuilt on every start.
+type MemoryStore struct {
+	mu      sync.Mutex
+	devices map[deviceKey]Device
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{devices: make(map[deviceKey]Device)}
+}
+
+// List implements Store.
+func (s *MemoryStore) List() ([]Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		list = append(list, d.clone())
+	}
+	return list, nil
+}
+
+// Put implements Store.
+func (s *MemoryStore) Put(d Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices[deviceKey{d.Tenant, d.Serial}] = d.clone()
+	return nil
+}
+
+// Delete implements Store.
+func (s *MemoryStore) Delete(tenant, serial string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.devices, deviceKey{tenant, serial})
+	return nil
+}
+
+// FileStore is a Store that keeps all entries in one JSON file, rewritten
+// atomically on every change. It suits registries of up to a few thousand
+// devices; larger fleets should implement Store on a database.
+type FileStore struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// NewFileStore returns a FileStore on path. The file is created on the first
+// change; a missing file is an empty registry.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// List implements Store.
+func (s *FileStore) List() ([]Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// Put implements Store.
+func (s *FileStore) Put(d Device) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.read()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if list[i].Tenant == d.Tenant && list[i].Serial == d.Serial {
+			list[i] = d
+			return s.write(list)
+		}
+	}
+	return s.write(append(list, d))
+}
+
+// Delete implements Store.
+func (s *FileStore) Delete(tenant, serial string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list, err := s.read()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		if list[i].Tenant == tenant && list[i].Serial == serial {
+			return s.write(append(list[:i], list[i+1:]...))
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) read() ([]Device, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Device
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return list, nil
+}
+
+// write replaces the file through a temporary file, so a crash never leaves
+// it half written.
+func (s *FileStore) write(list []Device) error {
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,74 @@
+package zkteco
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func FuzzParseUserRecord(f *testing.F) {
+	f.Add(make([]byte, 72))
+	f.Add([]byte{0, 1, 0, 14, '1', '2', '3'})
+
+	f.Fuzz(func(t *testing.T, rec []byte) {
+		ParseUserRecord(rec)
+		parseLegacyUserRecord(rec)
+	})
+}
+
+func FuzzParseAttendanceRecord(f *testing.F) {
+	rec := make([]byte, 40)
+	rec[2] = 1
+	f.Add(rec)
+	f.Add(make([]byte, 16))
+
+	f.Fuzz(func(t *testing.T, rec []byte) {
+		ParseAttendanceRecord(rec)
+		parseAttendanceRecord16(rec)
+		parseAttendanceRecord8(rec)
+	})
+}
+
+func FuzzParseAttendanceRecord44(f *testing.F) {
+	f.Add(testAttendanceRecord44(1, "100", testPunch, 7))
+	f.Add(make([]byte, 44))
+	f.Add([]byte{1, 0, '1'})
+
+	f.Fuzz(func(t *testing.T, rec []byte) {
+		att, err := parseAttendanceRecord44(rec)
+		if len(rec) >= 44 && binary.LittleEndian.Uint16(rec[0:2]) != 0 {
+			if err != nil {
+				t.Fatalf("parseAttendanceRecord44: %v", err)
+			}
+			if att.WorkCode != int(binary.LittleEndian.Uint32(rec[40:44])) {
+				t.Fatalf("WorkCode = %d, want bytes 40-43", att.WorkCode)
+			}
+		}
+	})
+}
+
+func FuzzAttendanceDecoder(f *testing.F) {
+	f.Add(append(make([]byte, 8), sizePrefixed(testAttendanceRecord44(1, "100", testPunch, 7))...), uint16(20))
+	f.Add(append(make([]byte, 8), sizePrefixed(testAttendanceRecord(1, "100", testPunch))...), uint16(11))
+
+	f.Fuzz(func(t *testing.T, data []byte, split uint16) {
+		at := min(int(split), len(data))
+		for _, p := range []Profile{ProfileDefault, ProfileLegacy} {
+			whole := decodeAttendances(p, data)
+			if parts := decodeAttendances(p, data[:at], data[at:]); !reflect.DeepEqual(parts, whole) {
+				t.Fatalf("%s profile: split at %d decoded %v, whole %v", p.Name, at, parts, whole)
+			}
+		}
+	})
+}
+
+func FuzzDecodeRealTimeEvent(f *testing.F) {
+	f.Add(make([]byte, 40), EF_ATTLOG)
+	f.Add(make([]byte, 10), EF_FINGER)
+	f.Add([]byte{0xf4, 0x01}, EF_UNLOCK)
+
+	z := NewZKTeco("127.0.0.1", 4370)
+	f.Fuzz(func(t *testing.T, payload []byte, eventType int) {
+		z.decodeRealTimeEvent(payload, eventType)
+	})
+}
@@ -1,3 +1,7 @@
 module github.com/0mithun/go-zkteco
 
 go 1.22
+
+require golang.org/x/term v0.27.0
+
+require golang.org/x/sys v0.28.0 // indirect
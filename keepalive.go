@@ -0,0 +1,81 @@
+package zkteco
+
+import (
+	"context"
+	"time"
+)
+
+// WithKeepalive sends a heartbeat (CMD_GET_TIME) whenever the connection
+// has been idle for interval, so the device does not expire the session of
+// a long-lived client between polls. The heartbeat runs in the background
+// from Connect to Disconnect, as a command of its own (see the concurrency
+// notes of ZKTeco), and is skipped while other commands keep the session
+// busy. A failed heartbeat is logged (WithLogger); the next command reports
+// a dead connection. Default is 0 (no heartbeat).
+func WithKeepalive(interval time.Duration) Option {
+	return func(z *ZKTeco) {
+		z.keepalive = interval
+	}
+}
+
+// startKeepalive starts the heartbeat of a new connection, stopping the one
+// of the previous connection if it is still running.
+func (z *ZKTeco) startKeepalive() {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.stopKeepalive()
+	stop := make(chan struct{})
+	z.keepaliveStop = stop
+	go z.runKeepalive(stop)
+}
+
+// stopKeepalive stops the heartbeat, if running. The caller holds z.mu,
+// or is the only user of the client.
+func (z *ZKTeco) stopKeepalive() {
+	if z.keepaliveStop != nil {
+		close(z.keepaliveStop)
+		z.keepaliveStop = nil
+	}
+}
+
+func (z *ZKTeco) runKeepalive(stop <-chan struct{}) {
+	defer func() {
+		if r := recover(); r != nil {
+			z.warn("keepalive stopped by panic", "panic", r)
+		}
+	}()
+	timer := z.clock.NewTimer(z.keepalive)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C():
+		}
+		wait := z.heartbeat(stop)
+		if wait == 0 {
+			return
+		}
+		timer.Reset(wait)
+	}
+}
+
+// heartbeat sends the heartbeat if the connection has been idle for the
+// interval, and returns how long to wait before the next check, or 0 if
+// the heartbeat was stopped.
+func (z *ZKTeco) heartbeat(stop <-chan struct{}) time.Duration {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	select {
+	case <-stop:
+		return 0
+	default:
+	}
+	if idle := z.clock.Now().Sub(z.lastSent); idle < z.keepalive {
+		return z.keepalive - idle
+	}
+	if _, err := z.commandLocked(context.Background(), CMD_GET_TIME, nil, "general"); err != nil {
+		z.warn("keepalive failed", "error", err)
+	}
+	return z.keepalive
+}
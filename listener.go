@@ -0,0 +1,368 @@
+package zkteco
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Listener dispatches real-time events to handlers registered per event type.
+// The event mask sent to the device is computed from the registered handlers.
+type Listener struct {
+	zk *ZKTeco
+
+	mu        sync.Mutex
+	handlers  []eventHandler
+	subs      []*Subscription
+	listening chan struct{} // closed when Listen returns; nil when idle
+	pauseAck  chan error    // set by Pause, consumed by the listen loop
+	resume    chan struct{} // closed by Resume
+	stopping  bool          // set by Stop
+	finished  bool          // a Listen returned, ending the subscriptions
+}
+
+type eventHandler struct {
+	mask int
+	fn   EventHandler
+}
+
+// NewListener creates a Listener for real-time events on z.
+func (z *ZKTeco) NewListener() *Listener {
+	return &Listener{zk: z}
+}
+
+// On registers fn for the events matching mask (a combination of EF_* flags).
+func (l *Listener) On(mask int, fn EventCallback) *Listener {
+	return l.Handle(mask, func(event RealTimeEvent) error {
+		fn(event)
+		return nil
+	})
+}
+
+// Handle registers fn for the events matching mask. An error returned by fn
+// stops Listen, which returns it (ErrStopListening yields nil).
+func (l *Listener) Handle(mask int, fn EventHandler) *Listener {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handlers = append(l.handlers, eventHandler{mask: mask, fn: fn})
+	return l
+}
+
+// OnAttendance registers fn for attendance punches (EF_ATTLOG).
+func (l *Listener) OnAttendance(fn EventCallback) *Listener {
+	return l.On(EF_ATTLOG, fn)
+}
+
+// OnFinger registers fn for fingers placed on the sensor (EF_FINGER).
+func (l *Listener) OnFinger(fn EventCallback) *Listener {
+	return l.On(EF_FINGER, fn)
+}
+
+// OnEnroll registers fn for user and fingerprint enrollment (EF_ENROLLUSER, EF_ENROLLFINGER).
+func (l *Listener) OnEnroll(fn EventCallback) *Listener {
+	return l.On(EF_ENROLLUSER|EF_ENROLLFINGER, fn)
+}
+
+// OnVerify registers fn for verification events (EF_VERIFY).
+func (l *Listener) OnVerify(fn EventCallback) *Listener {
+	return l.On(EF_VERIFY, fn)
+}
+
+// OnDoor registers fn for door events: unlocks (EF_UNLOCK) and, on
+// access-control firmwares, the door closing (EF_DOOR_CLOSE) or opened with
+// the exit button (EF_EXIT_BUTTON) or remotely (EF_REMOTE_OPEN). The event
+// type tells them apart.
+func (l *Listener) OnDoor(fn EventCallback) *Listener {
+	return l.On(EF_UNLOCK|EF_DOOR_CLOSE|EF_EXIT_BUTTON|EF_REMOTE_OPEN, fn)
+}
+
+// OnButton registers fn for button presses (EF_BUTTON).
+func (l *Listener) OnButton(fn EventCallback) *Listener {
+	return l.On(EF_BUTTON, fn)
+}
+
+// OnCard registers fn for card swipes (EF_HIDNUM), including cards that are
+// not enrolled.
+func (l *Listener) OnCard(fn EventCallback) *Listener {
+	return l.On(EF_HIDNUM, fn)
+}
+
+// OnAlarm registers fn for alarms (EF_ALARM).
+func (l *Listener) OnAlarm(fn EventCallback) *Listener {
+	return l.On(EF_ALARM, fn)
+}
+
+// Mask returns the union of the masks of all registered handlers.
+func (l *Listener) Mask() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	mask := 0
+	for _, h := range l.handlers {
+		mask |= h.mask
+	}
+	for _, sub := range l.subs {
+		mask |= sub.mask
+	}
+	return mask
+}
+
+// Listen registers the handlers' events with the device and dispatches them
+// until timeout elapses (0 = forever) or a handler returns an error.
+func (l *Listener) Listen(timeout time.Duration) (err error) {
+	defer l.zk.recoverInternal("listen", &err)
+	mask := l.Mask()
+	if mask == 0 {
+		return fmt.Errorf("listen: no handlers registered")
+	}
+
+	l.mu.Lock()
+	if l.listening != nil {
+		l.mu.Unlock()
+		return fmt.Errorf("listen: already listening")
+	}
+	done := make(chan struct{})
+	l.listening = done
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		l.listening = nil
+		l.pauseAck = nil
+		l.resume = nil
+		l.stopping = false
+		l.finished = true
+		subs := l.subs
+		l.subs = nil
+		l.mu.Unlock()
+		for _, sub := range subs {
+			sub.close()
+		}
+		close(done)
+	}()
+
+	return l.zk.listenEvents(l.dispatch, mask, timeout, func() error {
+		return l.checkPause(mask)
+	})
+}
+
+// Pause unregisters the events and stops reading from the connection, so
+// other commands can be sent on the same client until Resume is called.
+// It blocks until the listen loop has released the connection, which it
+// does after its pending read, within a second.
+func (l *Listener) Pause() error {
+	l.mu.Lock()
+	done := l.listening
+	if done == nil {
+		l.mu.Unlock()
+		return fmt.Errorf("pause: not listening")
+	}
+	if l.resume != nil {
+		l.mu.Unlock()
+		return nil
+	}
+	ack := make(chan error, 1)
+	l.pauseAck = ack
+	l.resume = make(chan struct{})
+	l.mu.Unlock()
+
+	// The loop notices the request after its pending read
+	select {
+	case err := <-ack:
+		return err
+	case <-done:
+		return fmt.Errorf("pause: listener stopped")
+	}
+}
+
+// Resume re-registers the events and continues dispatching after Pause.
+func (l *Listener) Resume() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.resume != nil {
+		close(l.resume)
+		l.resume = nil
+	}
+}
+
+// Stop unregisters the events and makes Listen return nil, also when paused.
+// It blocks until Listen has returned, within a second of a pending read.
+func (l *Listener) Stop() {
+	l.mu.Lock()
+	done := l.listening
+	if done == nil {
+		l.mu.Unlock()
+		return
+	}
+	l.stopping = true
+	if l.resume != nil {
+		close(l.resume)
+		l.resume = nil
+	}
+	l.mu.Unlock()
+
+	// The loop notices the request after its pending read
+	<-done
+}
+
+// checkPause runs in the listen loop between reads. On a pending Pause it
+// unregisters the events, waits for Resume and registers them again. On
+// Stop it unregisters the events and ends the loop.
+func (l *Listener) checkPause(mask int) error {
+	l.mu.Lock()
+	ack, resume, stopping := l.pauseAck, l.resume, l.stopping
+	l.pauseAck = nil
+	l.mu.Unlock()
+
+	if stopping {
+		l.zk.registerEvents(0)
+		return ErrStopListening
+	}
+	if ack == nil {
+		return nil
+	}
+
+	err := l.zk.registerEvents(0)
+	ack <- err
+	if err != nil {
+		return err
+	}
+
+	<-resume
+	l.mu.Lock()
+	stopping = l.stopping
+	l.mu.Unlock()
+	if stopping {
+		return ErrStopListening
+	}
+	return l.zk.registerEvents(mask)
+}
+
+// dispatch calls every handler whose mask matches the event type and stops
+// at the first error.
+func (l *Listener) dispatch(event RealTimeEvent) error {
+	l.mu.Lock()
+	handlers := make([]eventHandler, len(l.handlers))
+	copy(handlers, l.handlers)
+	subs := make([]*Subscription, len(l.subs))
+	copy(subs, l.subs)
+	l.mu.Unlock()
+
+	for _, sub := range subs {
+		if event.EventType&sub.mask != 0 {
+			sub.deliver(event)
+		}
+	}
+
+	for _, h := range handlers {
+		if event.EventType&h.mask != 0 {
+			if err := h.fn(event); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Replay dispatches the attendance records to the handlers and
+// subscriptions as EF_ATTLOG events built by AttendanceEvent, in order, to
+// backfill consumers after an outage. It stops at the first handler error.
+// Subscriptions drop replayed events like live ones when their buffer is
+// full, so size them for the backfill. Handlers run on the calling goroutine;
+// replay before Listen or while paused to avoid running them concurrently
+// with live events.
+func (l *Listener) Replay(records []Attendance) error {
+	for _, a := range records {
+		event := AttendanceEvent(a, l.zk.host)
+		if event.DeviceSerial == "" {
+			event.DeviceSerial = l.zk.serial
+		}
+		if err := l.dispatch(event); err != nil {
+			if errors.Is(err, ErrStopListening) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// Subscription delivers the events matching its mask on its own buffered
+// channel. Events are dropped, and counted, while the buffer is full, so a
+// slow subscriber never stalls the others.
+type Subscription struct {
+	l    *Listener
+	mask int
+	ch   chan RealTimeEvent
+
+	mu      sync.Mutex
+	closed  bool
+	dropped uint64
+}
+
+// Subscribe adds a subscriber for the events matching mask with room for
+// buffer pending events. The channel is closed by Unsubscribe or when Listen
+// returns. Subscribe before calling Listen so the mask includes the events.
+// Subscriptions end with the first Listen: a later Listen delivers to the
+// handlers only, and Subscribe then returns a subscription already closed.
+func (l *Listener) Subscribe(mask int, buffer int) *Subscription {
+	if buffer < 0 {
+		buffer = 0
+	}
+	sub := &Subscription{l: l, mask: mask, ch: make(chan RealTimeEvent, buffer)}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.finished {
+		sub.close()
+		return sub
+	}
+	l.subs = append(l.subs, sub)
+	return sub
+}
+
+// Events returns the channel the subscription's events are delivered on.
+func (s *Subscription) Events() <-chan RealTimeEvent {
+	return s.ch
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Unsubscribe removes the subscription and closes its channel.
+func (s *Subscription) Unsubscribe() {
+	s.l.mu.Lock()
+	for i, sub := range s.l.subs {
+		if sub == s {
+			s.l.subs = append(s.l.subs[:i], s.l.subs[i+1:]...)
+			break
+		}
+	}
+	s.l.mu.Unlock()
+	s.close()
+}
+
+func (s *Subscription) deliver(event RealTimeEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- event:
+	default:
+		s.dropped++
+	}
+}
+
+func (s *Subscription) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
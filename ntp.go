@@ -0,0 +1,102 @@
+package zkteco
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// TimeSource is the reference clock device clocks are synchronized with.
+type TimeSource interface {
+	Now() (time.Time, error)
+}
+
+// HostClock is the TimeSource of the host clock.
+var HostClock TimeSource = hostClock{}
+
+type hostClock struct{}
+
+func (hostClock) Now() (time.Time, error) {
+	return time.Now(), nil
+}
+
+// NTPSource is a TimeSource that queries an NTP server (SNTP, RFC 4330), for
+// hosts whose own clock cannot be trusted.
+type NTPSource struct {
+	// Server is "host" or "host:port"; the port defaults to 123.
+	Server string
+	// Timeout of one query. Default is 5 seconds.
+	Timeout time.Duration
+}
+
+// ntpEpochOffset is the number of seconds between 1900 and 1970.
+const ntpEpochOffset = 2208988800
+
+// Now returns the host time corrected by the offset measured from the server.
+func (s NTPSource) Now() (time.Time, error) {
+	offset, err := s.Offset()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(offset), nil
+}
+
+// Offset queries the server and returns the server time minus the host time.
+func (s NTPSource) Offset() (time.Duration, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	addr := s.Server
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "123")
+	}
+
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return 0, fmt.Errorf("ntp %s: %w", s.Server, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := make([]byte, 48)
+	req[0] = 0x23 // no leap warning, version 4, client mode
+	sent := time.Now()
+	putNTPTime(req[40:48], sent)
+	if _, err := conn.Write(req); err != nil {
+		return 0, fmt.Errorf("ntp %s: %w", s.Server, err)
+	}
+
+	resp := make([]byte, 48)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return 0, fmt.Errorf("ntp %s: %w", s.Server, err)
+		}
+		received := time.Now()
+		// Ignore stray packets that do not answer this request
+		if n < 48 || resp[0]&0x07 != 4 || string(resp[24:32]) != string(req[40:48]) {
+			continue
+		}
+		if resp[1] == 0 {
+			return 0, fmt.Errorf("ntp %s: kiss-of-death %q", s.Server, resp[12:16])
+		}
+		serverReceived := ntpTime(resp[32:40])
+		serverSent := ntpTime(resp[40:48])
+		return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+	}
+}
+
+// putNTPTime writes t as a 64-bit NTP timestamp.
+func putNTPTime(b []byte, t time.Time) {
+	binary.BigEndian.PutUint32(b[0:4], uint32(t.Unix()+ntpEpochOffset))
+	binary.BigEndian.PutUint32(b[4:8], uint32((uint64(t.Nanosecond())<<32)/1e9))
+}
+
+// ntpTime reads a 64-bit NTP timestamp.
+func ntpTime(b []byte) time.Time {
+	sec := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	nsec := (uint64(binary.BigEndian.Uint32(b[4:8])) * 1e9) >> 32
+	return time.Unix(sec, int64(nsec))
+}
@@ -0,0 +1,120 @@
+package zkteco
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// OptionSet maps device option keys to values, e.g. the configuration of a
+// "golden" device to clone onto new terminals.
+type OptionSet map[string]string
+
+// CloneableOptionKeys are the behaviour and display options DumpOptions reads
+// by default. Identity and network options (serial number, device ID, IP
+// address) are left out so a dump can be applied to other devices.
+var CloneableOptionKeys = []string{
+	"LockOn",
+	"AlarmAttLog",
+	"AlarmOpLog",
+	"AlarmReRec",
+	"VOLUME",
+	"VoiceOn",
+	"IdleMinute",
+	"IdlePower",
+	"DtFmt",
+	"ShowState",
+	"KeyLayout",
+	"WorkCode",
+	"FaceFunOn",
+}
+
+// DumpOptions reads the options keys (CloneableOptionKeys if keys is nil)
+// into an OptionSet. Options the firmware does not have are left out.
+func (z *ZKTeco) DumpOptions(keys []string) (_ OptionSet, err error) {
+	defer z.recoverInternal("dumpOptions", &err)
+	if keys == nil {
+		keys = CloneableOptionKeys
+	}
+	values, errs := z.getDeviceOptionsEach(keys)
+
+	set := make(OptionSet, len(keys))
+	for i, key := range keys {
+		if errs[i] != nil {
+			if errors.Is(errs[i], ErrUnknownOption) {
+				continue
+			}
+			return nil, fmt.Errorf("dumpOptions: %w", errs[i])
+		}
+		set[key] = values[i]
+	}
+	return set, nil
+}
+
+// DiffOptions compares the device with set and returns the options whose
+// value differs, without changing anything: a preview of ApplyOptions.
+func (z *ZKTeco) DiffOptions(set OptionSet) (_ []Deviation, err error) {
+	defer z.recoverInternal("diffOptions", &err)
+	report := &AuditReport{}
+	if err := z.auditOptions(set, false, report); err != nil {
+		return nil, fmt.Errorf("diffOptions: %w", err)
+	}
+	return report.Deviations, nil
+}
+
+// ApplyOptions writes the options of set whose value differs on the device,
+// then makes the device reload its options. It returns the differing
+// options, with Remediated or RemediationError set for each.
+func (z *ZKTeco) ApplyOptions(set OptionSet) (_ []Deviation, err error) {
+	defer z.recoverInternal("applyOptions", &err)
+	report := &AuditReport{}
+	if err := z.auditOptions(set, true, report); err != nil {
+		return nil, fmt.Errorf("applyOptions: %w", err)
+	}
+	return report.Deviations, nil
+}
+
+// WriteTo writes the set as sorted "key=value" lines, the format of the
+// device's own options file.
+func (s OptionSet) WriteTo(w io.Writer) (int64, error) {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var written int64
+	for _, key := range keys {
+		n, err := fmt.Fprintf(w, "%s=%s\n", key, s[key])
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// ReadOptionSet reads "key=value" lines as written by OptionSet.WriteTo.
+// Blank lines and lines starting with '#' are ignored.
+func ReadOptionSet(r io.Reader) (OptionSet, error) {
+	set := make(OptionSet)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("readOptionSet: line %d: want key=value, got %q", line, text)
+		}
+		set[strings.TrimSpace(key)] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("readOptionSet: %w", err)
+	}
+	return set, nil
+}
@@ -0,0 +1,57 @@
+package zkteco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// AttendancePage is a page of the attendance log, for paginated APIs.
+// Offset, Limit and Total count the records of the device log, including
+// unused slots and records dropped by WithRecordTimeWindow, so a page can
+// hold fewer than Limit records; the next page starts at Offset+Limit.
+type AttendancePage struct {
+	Records []Attendance `json:"records"`
+	Offset  int          `json:"offset"`
+	Limit   int          `json:"limit"`
+	Total   int          `json:"total"` // records on the device
+}
+
+// errPageFull stops the download of a page once its records are in.
+var errPageFull = errors.New("page full")
+
+// GetAttendancesPage returns the attendance records offset to offset+limit
+// of the device log (oldest first), and the number of records on the
+// device. The protocol cannot read part of the log, so the download
+// streams records up to the end of the page, decoding only the page, and is
+// aborted there; pages past the end cost one status command only.
+func (z *ZKTeco) GetAttendancesPage(offset, limit int) (*AttendancePage, error) {
+	return z.GetAttendancesPageContext(context.Background(), offset, limit)
+}
+
+// GetAttendancesPageContext is like GetAttendancesPage but aborts the
+// transfer when ctx is canceled, leaving the connection usable.
+func (z *ZKTeco) GetAttendancesPageContext(ctx context.Context, offset, limit int) (_ *AttendancePage, err error) {
+	defer z.recoverInternal("getAttendancesPageContext", &err)
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("getAttendancesPage: invalid offset %d or limit %d", offset, limit)
+	}
+
+	mem, err := z.GetMemoryInfo()
+	if err != nil {
+		return nil, fmt.Errorf("getAttendancesPage: %w", err)
+	}
+	page := &AttendancePage{Offset: offset, Limit: limit, Total: mem.LogCount}
+	if offset >= page.Total {
+		return page, nil
+	}
+
+	_, err = z.eachAttendanceSlots(ctx, offset, offset+limit, func(att Attendance) error {
+		page.Records = append(page.Records, att)
+		return nil
+	}, nil)
+	if err != nil && !errors.Is(err, errPageFull) {
+		return nil, fmt.Errorf("getAttendancesPage: %w", err)
+	}
+	return page, nil
+}
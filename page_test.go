@@ -0,0 +1,51 @@
+package zkteco
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestAttendancesPageOf44ByteRecords(t *testing.T) {
+	// Ten 44-byte records, 440 bytes: also eleven 40-byte ones
+	var records [][]byte
+	for i := range 10 {
+		records = append(records, testAttendanceRecord44(i+1, strconv.Itoa(100*(i+1)), testPunch, 7))
+	}
+	dev := stockDevice()
+	dev.attendances = sizePrefixed(records...)
+	dev.freeSizes = make([]uint32, freeSizeFaceCap+1)
+	dev.freeSizes[freeSizeLogs] = uint32(len(records))
+
+	zk := NewZKTeco("device", 4370, WithTransport(dev.transport()))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+
+	var got []Attendance
+	for offset := 0; ; offset += 4 {
+		page, err := zk.GetAttendancesPage(offset, 4)
+		if err != nil {
+			t.Fatalf("GetAttendancesPage(%d, 4): %v", offset, err)
+		}
+		if page.Total != len(records) {
+			t.Fatalf("page at %d: Total = %d, want %d", offset, page.Total, len(records))
+		}
+		if want := min(4, max(page.Total-offset, 0)); len(page.Records) != want {
+			t.Fatalf("page at %d: %d records, want %d", offset, len(page.Records), want)
+		}
+		got = append(got, page.Records...)
+		if offset+page.Limit >= page.Total {
+			break
+		}
+	}
+
+	if len(got) != len(records) {
+		t.Fatalf("pages hold %d records, want %d", len(got), len(records))
+	}
+	for i, att := range got {
+		if want := strconv.Itoa(100 * (i + 1)); att.UserID != want || att.WorkCode != 7 {
+			t.Errorf("record %d = %s with work code %d, want %s with 7", i, att.UserID, att.WorkCode, want)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package zkteco
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// ErrInternal is matched (errors.Is) by the errors of calls that hit a bug
+// of the client, such as a malformed reply slipping past the parsers: the
+// exported methods recover from panics and return an InternalError instead,
+// so a single device cannot crash the host service. The client may be left
+// with an unusable connection; reconnect before using it again.
+var ErrInternal = errors.New("internal error")
+
+// InternalError is a panic of an exported method turned into an error.
+type InternalError struct {
+	Op      string // method, e.g. "getUsers"
+	Command uint16 // last command exchanged with the device, 0 if none
+	Panic   any    // value the code panicked with
+	Stack   []byte // stack trace of the panic
+}
+
+func (e *InternalError) Error() string {
+	if e.Command == 0 {
+		return fmt.Sprintf("%s: %v: %v", e.Op, ErrInternal, e.Panic)
+	}
+	return fmt.Sprintf("%s: %v after %s: %v", e.Op, ErrInternal, commandString(e.Command), e.Panic)
+}
+
+// Is reports whether target is ErrInternal.
+func (e *InternalError) Is(target error) bool {
+	return target == ErrInternal
+}
+
+// Unwrap returns the panic value if it is an error, e.g. a runtime.Error.
+func (e *InternalError) Unwrap() error {
+	err, _ := e.Panic.(error)
+	return err
+}
+
+// recoverInternal turns a panic unwinding through the exported method op
+// into an InternalError in *err, re-enabling the device if the method left
+// it disabled. It must be deferred directly.
+func (z *ZKTeco) recoverInternal(op string, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	ie := &InternalError{Op: op, Panic: r, Stack: debug.Stack()}
+	z.mu.Lock()
+	ie.Command = z.lastCommand
+	z.mu.Unlock()
+	z.warn("recovered from panic", "op", op, "command", commandString(ie.Command), "panic", r)
+
+	if z.enableOnDisconnect && z.isDisabled() {
+		z.EnableDevice()
+	}
+	*err = ie
+}
@@ -0,0 +1,10 @@
+module github.com/0mithun/go-zkteco/parquet
+
+go 1.22
+
+require (
+	github.com/0mithun/go-zkteco v0.0.0-00010101000000-000000000000
+	github.com/parquet-go/parquet-go v0.24.0
+)
+
+replace github.com/0mithun/go-zkteco => ../
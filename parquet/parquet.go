@@ -0,0 +1,125 @@
+// Package parquet exports attendance records as Parquet files, so history
+// pulled from devices can land directly in a data lake and be queried with
+// Spark, DuckDB, Athena and the like.
+//
+// It is a separate module so the zkteco package itself does not depend on
+// a Parquet library.
+package parquet
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	zkteco "github.com/0mithun/go-zkteco"
+	pq "github.com/parquet-go/parquet-go"
+)
+
+// Record is the Parquet row of an attendance record. Its columns follow
+// zkteco.AttendanceDocument, except for time, a timestamp column instead of
+// an RFC 3339 string. Strings repeating across rows are dictionary-encoded.
+type Record struct {
+	SchemaVersion   int32     `parquet:"schema_version"`
+	UID             int32     `parquet:"uid"`
+	UserID          string    `parquet:"user_id,dict"`
+	Time            time.Time `parquet:"time,timestamp(millisecond)"`
+	VerifyMode      string    `parquet:"verify_mode,dict"`
+	Punch           string    `parquet:"punch,dict"`
+	WorkCode        int32     `parquet:"work_code"`
+	Reader          int32     `parquet:"reader"`
+	Direction       string    `parquet:"direction,optional,dict"` // null when unknown
+	TimeImplausible bool      `parquet:"time_implausible"`
+	DeviceSerial    string    `parquet:"device_serial,optional,dict"`
+}
+
+// NewRecord returns the Parquet row of att.
+func NewRecord(att zkteco.Attendance) Record {
+	doc := att.Document()
+	return Record{
+		SchemaVersion:   int32(doc.SchemaVersion),
+		UID:             int32(doc.UID),
+		UserID:          doc.UserID,
+		Time:            att.RecordTime,
+		VerifyMode:      doc.VerifyMode,
+		Punch:           doc.Punch,
+		WorkCode:        int32(doc.WorkCode),
+		Reader:          int32(doc.Reader),
+		Direction:       doc.Direction,
+		TimeImplausible: doc.TimeImplausible,
+		DeviceSerial:    doc.DeviceSerial,
+	}
+}
+
+// batchSize is the number of rows buffered before they are handed to the
+// Parquet writer.
+const batchSize = 1024
+
+// errFlushed is returned for records received after the file was completed.
+var errFlushed = errors.New("parquet: sink already flushed")
+
+// Sink is a zkteco.RecordSink writing attendance records to a Snappy
+// compressed Parquet file:
+//
+//	f, _ := os.Create("attendance.parquet")
+//	sink := parquet.NewSink(f)
+//	err := zk.StreamAttendances(ctx, sink)
+//
+// A Parquet file ends with a footer describing its row groups, so it is
+// only readable once Flush has run; StreamAttendances and
+// zkteco.ExportAttendances call it after the last record. A Sink writes one
+// file: it accepts no records after Flush.
+type Sink struct {
+	w       *pq.GenericWriter[Record]
+	batch   []Record
+	flushed bool
+}
+
+// NewSink creates a Sink writing to w. Options are passed on to the Parquet
+// writer, e.g. pq.Compression to change the codec or pq.MaxRowsPerRowGroup.
+func NewSink(w io.Writer, options ...pq.WriterOption) *Sink {
+	opts := append([]pq.WriterOption{pq.Compression(&pq.Snappy)}, options...)
+	return &Sink{
+		w:     pq.NewGenericWriter[Record](w, opts...),
+		batch: make([]Record, 0, batchSize),
+	}
+}
+
+// OnAttendance adds the row of att to the file.
+func (s *Sink) OnAttendance(att zkteco.Attendance) error {
+	if s.flushed {
+		return errFlushed
+	}
+	s.batch = append(s.batch, NewRecord(att))
+	if len(s.batch) == batchSize {
+		return s.writeBatch()
+	}
+	return nil
+}
+
+// Flush writes the remaining rows and the footer, completing the file. It
+// does not close the underlying writer.
+func (s *Sink) Flush() error {
+	if s.flushed {
+		return nil
+	}
+	if err := s.writeBatch(); err != nil {
+		return err
+	}
+	s.flushed = true
+	if err := s.w.Close(); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	return nil
+}
+
+func (s *Sink) writeBatch() error {
+	if len(s.batch) == 0 {
+		return nil
+	}
+	if _, err := s.w.Write(s.batch); err != nil {
+		return fmt.Errorf("parquet: %w", err)
+	}
+	s.batch = s.batch[:0]
+	return nil
+}
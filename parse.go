@@ -0,0 +1,53 @@
+package zkteco
+
+import (
+	"errors"
+	"fmt"
+)
+
+// maxReportSamples is the number of failed records whose raw bytes are kept
+// in a ParseReport.
+const maxReportSamples = 5
+
+// errEmptyRecord marks an unused slot in a record table. Such slots are
+// dropped without a trace in the ParseReport.
+var errEmptyRecord = errors.New("empty record")
+
+// ParseError describes a record that could not be parsed.
+type ParseError struct {
+	Offset int    `json:"offset"` // byte offset of the record in the table
+	Reason string `json:"reason"`
+	Raw    []byte `json:"raw,omitempty"` // only kept for the first failures
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("record at offset %d: %s", e.Offset, e.Reason)
+}
+
+// ParseReport collects the records skipped while parsing a record table.
+type ParseReport struct {
+	Parsed  int           `json:"parsed"`
+	Skipped int           `json:"skipped"`
+	Errors  []*ParseError `json:"errors,omitempty"`
+
+	// Implausible counts the attendance records outside the
+	// WithRecordTimeWindow window, flagged (and counted in Parsed) or
+	// dropped (and counted in Skipped).
+	Implausible int `json:"implausible,omitempty"`
+}
+
+// add records a skipped record. The raw bytes of the first
+// maxReportSamples failures are copied into the report.
+func (r *ParseReport) add(offset int, rec []byte, err error) {
+	r.Skipped++
+	pe := &ParseError{Offset: offset, Reason: err.Error()}
+	if len(r.Errors) < maxReportSamples {
+		pe.Raw = append([]byte(nil), rec...)
+	}
+	r.Errors = append(r.Errors, pe)
+}
+
+// errShortRecord reports a record shorter than its layout.
+func errShortRecord(got, want int) error {
+	return fmt.Errorf("record too short: %d bytes, want %d", got, want)
+}
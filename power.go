@@ -0,0 +1,108 @@
+package zkteco
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// powerTimeDisabled is the option value of a disabled power action.
+const powerTimeDisabled = 65535
+
+// PowerSchedule holds the automatic power-off/power-on and idle-sleep settings.
+// Times are offsets from midnight; a negative value disables the action.
+type PowerSchedule struct {
+	PowerOffAt   time.Duration `json:"power_off_at"`
+	PowerOnAt    time.Duration `json:"power_on_at"`
+	SuspendAt    time.Duration `json:"suspend_at"`
+	IdleMinutes  int           `json:"idle_minutes"`   // 0 = never
+	IdlePowerOff bool          `json:"idle_power_off"` // power off instead of sleeping when idle
+}
+
+// GetPowerSchedule returns the device power schedule.
+func (z *ZKTeco) GetPowerSchedule() (_ *PowerSchedule, err error) {
+	defer z.recoverInternal("getPowerSchedule", &err)
+	ps := &PowerSchedule{}
+
+	if ps.PowerOffAt, err = z.getPowerTime("AutoPowerOff"); err != nil {
+		return nil, fmt.Errorf("getPowerSchedule: %w", err)
+	}
+	if ps.PowerOnAt, err = z.getPowerTime("AutoPowerOn"); err != nil {
+		return nil, fmt.Errorf("getPowerSchedule: %w", err)
+	}
+	if ps.SuspendAt, err = z.getPowerTime("AutoPowerSuspend"); err != nil {
+		return nil, fmt.Errorf("getPowerSchedule: %w", err)
+	}
+	if ps.IdleMinutes, err = z.getIntOption("IdleMinute"); err != nil {
+		return nil, fmt.Errorf("getPowerSchedule: %w", err)
+	}
+	idlePower, err := z.getIntOption("IdlePower")
+	if err != nil {
+		return nil, fmt.Errorf("getPowerSchedule: %w", err)
+	}
+	ps.IdlePowerOff = idlePower == 0
+
+	return ps, nil
+}
+
+// SetPowerSchedule writes the device power schedule.
+func (z *ZKTeco) SetPowerSchedule(ps PowerSchedule) (err error) {
+	defer z.recoverInternal("setPowerSchedule", &err)
+	options := []struct {
+		key string
+		at  time.Duration
+	}{
+		{"AutoPowerOff", ps.PowerOffAt},
+		{"AutoPowerOn", ps.PowerOnAt},
+		{"AutoPowerSuspend", ps.SuspendAt},
+	}
+	for _, opt := range options {
+		value, err := encodePowerTime(opt.at)
+		if err != nil {
+			return fmt.Errorf("setPowerSchedule: %s: %w", opt.key, err)
+		}
+		if err := z.SetDeviceData(opt.key, strconv.Itoa(value)); err != nil {
+			return fmt.Errorf("setPowerSchedule: %w", err)
+		}
+	}
+
+	if ps.IdleMinutes < 0 {
+		return fmt.Errorf("setPowerSchedule: negative idle minutes %d", ps.IdleMinutes)
+	}
+	if err := z.SetDeviceData("IdleMinute", strconv.Itoa(ps.IdleMinutes)); err != nil {
+		return fmt.Errorf("setPowerSchedule: %w", err)
+	}
+	idlePower := "1"
+	if ps.IdlePowerOff {
+		idlePower = "0"
+	}
+	if err := z.SetDeviceData("IdlePower", idlePower); err != nil {
+		return fmt.Errorf("setPowerSchedule: %w", err)
+	}
+	return nil
+}
+
+// getPowerTime reads a power action time option (hour<<8 | minute).
+func (z *ZKTeco) getPowerTime(key string) (time.Duration, error) {
+	v, err := z.getIntOption(key)
+	if err != nil {
+		return 0, err
+	}
+	if v == powerTimeDisabled {
+		return -1, nil
+	}
+	return time.Duration(v>>8)*time.Hour + time.Duration(v&0xFF)*time.Minute, nil
+}
+
+// encodePowerTime packs a time of day as hour<<8 | minute.
+func encodePowerTime(at time.Duration) (int, error) {
+	if at < 0 {
+		return powerTimeDisabled, nil
+	}
+	if at >= 24*time.Hour {
+		return 0, fmt.Errorf("time of day %s out of range", at)
+	}
+	hour := int(at / time.Hour)
+	minute := int((at % time.Hour) / time.Minute)
+	return hour<<8 | minute, nil
+}
@@ -0,0 +1,159 @@
+package zkteco
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestLegacyDeviceReads(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opt  Option
+	}{
+		{"fixed", WithProfile(ProfileLegacy)},
+		{"detected", WithProfileDetection()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dev := legacyDevice()
+			zk := NewZKTeco("device", 4370, WithTransport(dev.transport()), tc.opt)
+			if err := zk.Connect(); err != nil {
+				t.Fatalf("Connect: %v", err)
+			}
+			defer zk.Disconnect()
+			if p := zk.Profile(); p.Name != "legacy" {
+				t.Fatalf("Profile = %q, want legacy", p.Name)
+			}
+
+			users, err := zk.GetUsers()
+			if err != nil {
+				t.Fatalf("GetUsers: %v", err)
+			}
+			if len(users) != 2 || users[0].UserID != "100" || users[1].Name != "Bob" {
+				t.Errorf("GetUsers = %+v, want Alice (100) and Bob (200)", users)
+			}
+
+			records, err := zk.GetAttendances()
+			if err != nil {
+				t.Fatalf("GetAttendances: %v", err)
+			}
+			if len(records) != 2 || records[0].UserID != "100" || !records[0].RecordTime.Equal(testPunch) || !records[1].RecordTime.Equal(testPunch2) {
+				t.Errorf("GetAttendances = %+v, want 100 at %s and 200 at %s", records, testPunch, testPunch2)
+			}
+
+			if err := zk.SetUser(3, "300", "Carol", "", LEVEL_USER, 0); err != nil {
+				t.Fatalf("SetUser: %v", err)
+			}
+			if len(dev.set) != 1 || len(dev.set[0]) != 28 {
+				t.Errorf("SetUser wrote %d records, want one of 28 bytes", len(dev.set))
+			}
+		})
+	}
+}
+
+func TestProfileDetectionKeepsDefault(t *testing.T) {
+	zk := NewZKTeco("device", 4370, WithTransport(stockDevice().transport()), WithProfileDetection())
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+	if p := zk.Profile(); p.Name != "default" {
+		t.Fatalf("Profile = %q, want default", p.Name)
+	}
+
+	users, err := zk.GetUsers()
+	if err != nil {
+		t.Fatalf("GetUsers: %v", err)
+	}
+	if len(users) != 2 || users[1].UserID != "200" {
+		t.Errorf("GetUsers = %+v, want Alice (100) and Bob (200)", users)
+	}
+	records, err := zk.GetAttendances()
+	if err != nil {
+		t.Fatalf("GetAttendances: %v", err)
+	}
+	if len(records) != 2 || records[1].UserID != "200" || !records[1].RecordTime.Equal(testPunch2) {
+		t.Errorf("GetAttendances = %+v, want 100 at %s and 200 at %s", records, testPunch, testPunch2)
+	}
+}
+
+func TestConnectWithPassword(t *testing.T) {
+	dev := protectedDevice(1234)
+	mem := dev.transport()
+	zk := NewZKTeco("device", 4370, WithTransport(mem), WithPassword(1234))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+	if _, err := zk.Version(); err != nil {
+		t.Fatalf("Version after authentication: %v", err)
+	}
+	if got := mem.commands()[:2]; !slices.Equal(got, []uint16{CMD_CONNECT, CMD_ACK_AUTH}) {
+		t.Errorf("handshake = %v, want CMD_CONNECT then CMD_ACK_AUTH", got)
+	}
+
+	wrong := NewZKTeco("device", 4370, WithTransport(protectedDevice(1234).transport()), WithPassword(4321))
+	if err := wrong.Connect(); err == nil {
+		wrong.Disconnect()
+		t.Fatal("Connect with a wrong password succeeded")
+	}
+}
+
+func TestFaceDevicePhotoUpload(t *testing.T) {
+	dev := faceDevice()
+	zk := NewZKTeco("device", 4370, WithTransport(dev.transport()))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+
+	if on, err := zk.FaceFunctionOn(); err != nil || on != "1" {
+		t.Fatalf("FaceFunctionOn = %q, %v, want 1", on, err)
+	}
+	photo := bytes.Repeat([]byte{0xFF, 0xD8}, 3000)
+	if err := zk.UploadFile(UserPhotoFileName("100"), photo); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if got := dev.files[UserPhotoFileName("100")]; !bytes.Equal(got, photo) {
+		t.Errorf("device stored %d bytes under %q, want the %d-byte photo", len(got), UserPhotoFileName("100"), len(photo))
+	}
+}
+
+func TestLegacyAuthOnConnect(t *testing.T) {
+	dev := legacyDevice()
+	dev.password, dev.silentAuth = 1234, true
+	mem := dev.transport()
+	zk := NewZKTeco("device", 4370, WithTransport(mem), WithProfile(ProfileLegacy), WithPassword(1234))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+	if _, err := zk.Version(); err != nil {
+		t.Fatalf("Version after authentication: %v", err)
+	}
+	if got := mem.commands()[:2]; !slices.Equal(got, []uint16{CMD_CONNECT, CMD_ACK_AUTH}) {
+		t.Errorf("handshake = %v, want CMD_CONNECT then CMD_ACK_AUTH", got)
+	}
+}
+
+func TestLegacyUnsupportedCommands(t *testing.T) {
+	mem := legacyDevice().transport()
+	zk := NewZKTeco("device", 4370, WithTransport(mem), WithProfile(ProfileLegacy))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+
+	err := zk.UploadFile(UserPhotoFileName("100"), []byte{0xFF, 0xD8})
+	var unsupported *UnsupportedCommandError
+	if !errors.As(err, &unsupported) || unsupported.Command != CMD_UPDATEFILE || !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("UploadFile = %v, want an UnsupportedCommandError for CMD_UPDATEFILE", err)
+	}
+	if err := zk.DeleteFile(UserPhotoFileName("100")); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("DeleteFile = %v, want errors.ErrUnsupported", err)
+	}
+	if got := mem.commands(); !slices.Equal(got, []uint16{CMD_CONNECT}) {
+		t.Errorf("commands = %v, want CMD_CONNECT only", got)
+	}
+}
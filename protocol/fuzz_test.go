@@ -0,0 +1,41 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+)
+
+func FuzzParsePacket(f *testing.F) {
+	pkt, _ := CreateHeader(1000, 0, 65534, nil)
+	f.Add(pkt)
+	pkt, _ = CreateHeader(11, 1234, 1, []byte("~SerialNumber"))
+	f.Add(pkt)
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := ParsePacket(data)
+		if err != nil {
+			return
+		}
+		if len(p.Data) != len(data)-8 {
+			t.Fatalf("data length %d, want %d", len(p.Data), len(data)-8)
+		}
+	})
+}
+
+func FuzzExtractTCPPacket(f *testing.F) {
+	pkt, _ := CreateHeader(2000, 1, 2, []byte{1, 2, 3})
+	f.Add(WrapTCP(pkt))
+	f.Add(append(WrapTCP(pkt), 0x50, 0x50))
+	f.Add([]byte{0x50, 0x50, 0x82, 0x7D, 0xFF, 0xFF, 0xFF, 0xFF})
+
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		payload, rest, ok := ExtractTCPPacket(buf)
+		if !ok {
+			return
+		}
+		if !bytes.Equal(WrapTCP(payload), buf[:len(buf)-len(rest)]) {
+			t.Fatalf("payload does not round-trip through WrapTCP")
+		}
+	})
+}
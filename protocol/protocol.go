@@ -0,0 +1,260 @@
+// Package protocol implements the ZKTeco binary wire format: packet headers,
+// checksums, TCP framing, the comm-key used for password authentication and
+// the packed timestamp encoding.
+//
+// It is the layer the zkteco client is built on, exported for sniffers,
+// proxies and fuzzers.
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// ushrtMax is the wrap-around value of checksums and reply IDs.
+const ushrtMax = 65535
+
+// TCPMagic is the TCP framing header.
+var TCPMagic = []byte{0x50, 0x50, 0x82, 0x7D}
+
+// Packet represents a parsed ZKTeco protocol packet.
+type Packet struct {
+	Command   uint16
+	Checksum  uint16
+	SessionID uint16
+	ReplyID   uint16
+	Data      []byte
+}
+
+// ParsePacket parses raw bytes (without TCP framing) into a Packet.
+func ParsePacket(data []byte) (*Packet, error) {
+	if len(data) < 8 {
+		return nil, fmt.Errorf("packet too short: %d bytes", len(data))
+	}
+	p := &Packet{
+		Command:   binary.LittleEndian.Uint16(data[0:2]),
+		Checksum:  binary.LittleEndian.Uint16(data[2:4]),
+		SessionID: binary.LittleEndian.Uint16(data[4:6]),
+		ReplyID:   binary.LittleEndian.Uint16(data[6:8]),
+	}
+	if len(data) > 8 {
+		p.Data = make([]byte, len(data)-8)
+		copy(p.Data, data[8:])
+	}
+	return p, nil
+}
+
+// ChecksumStrategy computes the header checksum of a packet, given with a
+// zero checksum field. Firmware variants with a different checksum implement
+// it and are selected through the client's profile.
+type ChecksumStrategy interface {
+	Checksum(packet []byte) uint16
+}
+
+// CommKeyStrategy derives the CMD_AUTH payload from the device password and
+// the session ID, for firmware variants with a different authentication.
+type CommKeyStrategy interface {
+	CommKey(password int, sessionID uint16) []byte
+}
+
+// ChecksumFunc adapts a function to a ChecksumStrategy.
+type ChecksumFunc func(packet []byte) uint16
+
+// Checksum returns f(packet).
+func (f ChecksumFunc) Checksum(packet []byte) uint16 {
+	return f(packet)
+}
+
+// CommKeyFunc adapts a function to a CommKeyStrategy.
+type CommKeyFunc func(password int, sessionID uint16) []byte
+
+// CommKey returns f(password, sessionID).
+func (f CommKeyFunc) CommKey(password int, sessionID uint16) []byte {
+	return f(password, sessionID)
+}
+
+// The strategies of stock firmwares: Checksum and MakeCommKey.
+var (
+	DefaultChecksum ChecksumStrategy = ChecksumFunc(Checksum)
+	DefaultCommKey  CommKeyStrategy  = CommKeyFunc(MakeCommKey)
+)
+
+// CreateHeader builds a ZKTeco packet with proper checksum.
+// Returns the full packet bytes and the next replyID.
+// Note: checksum is calculated with the original replyID, but the packet
+// is sent with the incremented replyID (matching PHP behavior).
+func CreateHeader(command uint16, sessionID uint16, replyID uint16, data []byte) ([]byte, uint16) {
+	return CreateHeaderWith(DefaultChecksum, command, sessionID, replyID, data)
+}
+
+// CreateHeaderWith is like CreateHeader but computes the checksum with cs.
+func CreateHeaderWith(cs ChecksumStrategy, command uint16, sessionID uint16, replyID uint16, data []byte) ([]byte, uint16) {
+	packetLen := 8 + len(data)
+	buf := make([]byte, packetLen)
+
+	// Step 1: Pack with original replyID and checksum=0 for checksum calculation
+	binary.LittleEndian.PutUint16(buf[0:2], command)
+	binary.LittleEndian.PutUint16(buf[2:4], 0)
+	binary.LittleEndian.PutUint16(buf[4:6], sessionID)
+	binary.LittleEndian.PutUint16(buf[6:8], replyID)
+	if len(data) > 0 {
+		copy(buf[8:], data)
+	}
+
+	// Step 2: Calculate checksum over original packet
+	checksum := cs.Checksum(buf)
+
+	// Step 3: Increment replyID (wrapping at USHRT_MAX)
+	nextReplyID := replyID + 1
+	if nextReplyID >= ushrtMax {
+		nextReplyID -= ushrtMax
+	}
+
+	// Step 4: Repack with computed checksum and incremented replyID
+	binary.LittleEndian.PutUint16(buf[2:4], checksum)
+	binary.LittleEndian.PutUint16(buf[6:8], nextReplyID)
+
+	return buf, nextReplyID
+}
+
+// Checksum computes the ZKTeco 16-bit checksum.
+func Checksum(data []byte) uint16 {
+	var chksum int64 = 0
+	length := len(data)
+
+	for i := 0; i < length-1; i += 2 {
+		val := int64(binary.LittleEndian.Uint16(data[i : i+2]))
+		chksum += val
+		if chksum > ushrtMax {
+			chksum -= ushrtMax
+		}
+	}
+
+	if length%2 != 0 {
+		chksum += int64(data[length-1])
+	}
+
+	for chksum > ushrtMax {
+		chksum -= ushrtMax
+	}
+
+	if chksum > 0 {
+		chksum = -chksum
+	}
+	chksum--
+	for chksum < 0 {
+		chksum += ushrtMax
+	}
+
+	return uint16(chksum)
+}
+
+// WrapTCP wraps a packet with the TCP framing header.
+func WrapTCP(packet []byte) []byte {
+	result := make([]byte, 8+len(packet))
+	copy(result[0:4], TCPMagic)
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(packet)))
+	copy(result[8:], packet)
+	return result
+}
+
+// MakeCommKey generates the auth key for password authentication.
+// Matches PHP's makeCommKey exactly: reverses bits, XORs with "ZKSO", swaps halves.
+func MakeCommKey(key int, sessionID uint16) []byte {
+	// Step 1: Reverse all 32 bits of the key
+	var k uint32
+	for i := 0; i < 32; i++ {
+		if key&(1<<i) != 0 {
+			k = (k << 1) | 1
+		} else {
+			k = k << 1
+		}
+	}
+
+	// Step 2: Add session ID
+	k += uint32(sessionID)
+
+	// Step 3: Pack as little-endian uint32
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, k)
+
+	// Step 4: XOR with "ZKSO"
+	xorKeys := []byte{'Z', 'K', 'S', 'O'}
+	for i := 0; i < 4; i++ {
+		b[i] ^= xorKeys[i]
+	}
+
+	// Step 5: Swap uint16 halves (unpack as 2 shorts, swap, repack)
+	s1 := binary.LittleEndian.Uint16(b[0:2])
+	s2 := binary.LittleEndian.Uint16(b[2:4])
+	binary.LittleEndian.PutUint16(b[0:2], s2)
+	binary.LittleEndian.PutUint16(b[2:4], s1)
+
+	// Step 6: XOR with mask (0xFF & 50 = 50 = 0x32)
+	mask := byte(0xFF & 50)
+	b[0] ^= mask
+	b[1] ^= mask
+	b[2] = mask
+	b[3] ^= mask
+
+	return b
+}
+
+// EncodeTime encodes a time.Time to a ZKTeco packed timestamp.
+func EncodeTime(t time.Time) uint32 {
+	y := t.Year() % 100
+	m := int(t.Month())
+	d := t.Day()
+	h := t.Hour()
+	min := t.Minute()
+	sec := t.Second()
+	return uint32(((y*12*31+(m-1)*31+d-1)*24*60*60 + (h*60+min)*60 + sec))
+}
+
+// DecodeTime decodes a ZKTeco packed timestamp to time.Time.
+func DecodeTime(t uint32) time.Time {
+	second := int(t % 60)
+	t /= 60
+	minute := int(t % 60)
+	t /= 60
+	hour := int(t % 24)
+	t /= 24
+	day := int(t%31 + 1)
+	t /= 31
+	month := int(t%12 + 1)
+	t /= 12
+	year := int(t + 2000)
+	return time.Date(year, time.Month(month), day, hour, minute, second, 0, time.Local)
+}
+
+// ExtractTCPPacket tries to extract a complete TCP-framed packet from buf.
+// It returns the payload, the unconsumed remainder and whether a complete
+// packet was found.
+func ExtractTCPPacket(buf []byte) ([]byte, []byte, bool) {
+	if len(buf) < 8 {
+		return nil, buf, false
+	}
+
+	if buf[0] != 0x50 || buf[1] != 0x50 || buf[2] != 0x82 || buf[3] != 0x7D {
+		return nil, buf, false
+	}
+
+	// Compare in uint64 so a hostile length cannot overflow int
+	payloadLen := binary.LittleEndian.Uint32(buf[4:8])
+	if uint64(len(buf)-8) < uint64(payloadLen) {
+		return nil, buf, false
+	}
+	totalLen := 8 + int(payloadLen)
+
+	payload := make([]byte, payloadLen)
+	copy(payload, buf[8:totalLen])
+
+	var remainder []byte
+	if len(buf) > totalLen {
+		remainder = make([]byte, len(buf)-totalLen)
+		copy(remainder, buf[totalLen:])
+	}
+
+	return payload, remainder, true
+}
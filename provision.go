@@ -0,0 +1,164 @@
+package zkteco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProvisionUser enrolls u on the device with its fingerprint templates and,
+// if photo is not nil, its JPEG photo, following the recommended sequence as
+// one operation: with the device disabled, the user record and templates are
+// written, the data refreshed and read back to verify them, then the photo
+// is uploaded. If a step fails, the changes are rolled back: a new user is
+// removed, an existing one is restored with its previous templates. The
+// device is enabled again in every case. Templates are stored under u.UID,
+// and checked with ValidateTemplate before the device is touched.
+func (z *ZKTeco) ProvisionUser(u User, templates []Template, photo []byte) (err error) {
+	defer z.recoverInternal("provisionUser", &err)
+	for _, t := range templates {
+		if err := ValidateTemplate(t.Data); err != nil {
+			return fmt.Errorf("provisionUser: finger %d: %w", t.FingerIndex, err)
+		}
+	}
+	provision := func() error {
+		users, err := z.GetUsers()
+		if err != nil {
+			return fmt.Errorf("read users: %w", err)
+		}
+		var previous *User
+		for i := range users {
+			if users[i].UID == u.UID {
+				previous = &users[i]
+				break
+			}
+		}
+		var previousTemplates map[int][]byte
+		if previous != nil {
+			if previousTemplates, err = z.GetFingerprints(u.UID); err != nil {
+				return fmt.Errorf("read templates: %w", err)
+			}
+		}
+
+		if err := z.provisionUser(u, templates, photo); err != nil {
+			if rollbackErr := z.rollbackUser(u.UID, previous, previousTemplates); rollbackErr != nil {
+				return errors.Join(err, fmt.Errorf("rollback: %w", rollbackErr))
+			}
+			return err
+		}
+		return nil
+	}
+
+	if z.isDisabled() {
+		err = provision()
+	} else {
+		err = z.WithDeviceDisabled(context.Background(), provision)
+	}
+	if err != nil {
+		return fmt.Errorf("provisionUser %d: %w", u.UID, err)
+	}
+	return nil
+}
+
+func (z *ZKTeco) provisionUser(u User, templates []Template, photo []byte) error {
+	if err := z.SetUser(u.UID, u.UserID, u.Name, u.Password, u.Role, u.CardNo); err != nil {
+		return err
+	}
+	for _, t := range templates {
+		t.UID = u.UID
+		if err := z.writeTemplate(t); err != nil {
+			return fmt.Errorf("finger %d: %w", t.FingerIndex, err)
+		}
+	}
+	if err := z.RefreshData(); err != nil {
+		return err
+	}
+	if err := z.verifyUser(u, templates); err != nil {
+		return err
+	}
+	if photo != nil {
+		if err := z.UploadFile(UserPhotoFileName(u.UserID), photo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyUser reads back the user record and templates written by
+// provisionUser.
+func (z *ZKTeco) verifyUser(u User, templates []Template) error {
+	users, err := z.GetUsers()
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	var stored *User
+	for i := range users {
+		if users[i].UID == u.UID {
+			stored = &users[i]
+			break
+		}
+	}
+	if stored == nil {
+		return fmt.Errorf("verify: %w", ErrUserNotFound)
+	}
+	if !sameUser(*stored, u) {
+		return fmt.Errorf("verify: stored user %+v differs", *stored)
+	}
+
+	if len(templates) == 0 {
+		return nil
+	}
+	fingers, err := z.GetFingerprints(u.UID)
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	for _, t := range templates {
+		if _, ok := fingers[t.FingerIndex]; !ok {
+			return fmt.Errorf("verify: finger %d not stored", t.FingerIndex)
+		}
+	}
+	return nil
+}
+
+// sameUser reports whether stored is the record SetUser writes for u,
+// allowing for the truncated name and user ID of the record layouts and the
+// numeric user IDs of legacy firmwares.
+func sameUser(stored, u User) bool {
+	if stored.Role != u.Role || stored.CardNo != u.CardNo || !strings.HasPrefix(u.Name, stored.Name) {
+		return false
+	}
+	if stored.UserID != "" && strings.HasPrefix(u.UserID, stored.UserID) {
+		return true
+	}
+	a, errA := strconv.ParseUint(stored.UserID, 10, 32)
+	b, errB := strconv.ParseUint(u.UserID, 10, 32)
+	return errA == nil && errB == nil && a == b
+}
+
+// rollbackUser removes the user uid and, if it existed before, writes back
+// its previous record and templates.
+func (z *ZKTeco) rollbackUser(uid int, previous *User, previousTemplates map[int][]byte) error {
+	if err := z.RemoveUser(uid); err != nil {
+		return err
+	}
+	if previous != nil {
+		p := previous
+		if err := z.SetUser(p.UID, p.UserID, p.Name, p.Password, p.Role, p.CardNo); err != nil {
+			return err
+		}
+		for finger, data := range previousTemplates {
+			if err := z.writeTemplate(Template{UID: uid, FingerIndex: finger, Data: data}); err != nil {
+				return fmt.Errorf("finger %d: %w", finger, err)
+			}
+		}
+	}
+	return z.RefreshData()
+}
+
+// UserPhotoFileName returns the device file name of the photo of a user,
+// as shown on verification.
+func UserPhotoFileName(userID string) string {
+	return userID + ".jpg"
+}
@@ -0,0 +1,380 @@
+package zkteco
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/0mithun/go-zkteco/protocol"
+)
+
+// Proxy holds one device connection and shares it between any number of
+// clients speaking the ZKTeco TCP protocol. Each client gets its own session;
+// commands are forwarded one at a time, so clients never kick each other off
+// the device.
+//
+// Large transfers are received in full by the proxy and replayed to the
+// client as a single CMD_DATA chunk. Uploads (CMD_PREPARE_DATA followed by
+// CMD_DATA) keep the device reserved for the uploading client until its
+// commit command. Real-time events are not forwarded: CMD_REG_EVENT is
+// answered with CMD_ACK_ERROR.
+//
+// Client packets are limited to the client's WithMaxBufferSize, or to 16 MiB
+// without one; a client that announces a longer packet is disconnected, as
+// is an uploading client that sends nothing for the client's timeout.
+type Proxy struct {
+	zk          *ZKTeco
+	password    int
+	idleTimeout time.Duration
+	backoff     Backoff
+
+	// Reconnect state, guarded by device
+	failures    int
+	nextAttempt time.Time
+
+	device   sync.Mutex // held while a command (or an upload) is in flight
+	mu       sync.Mutex
+	sessions map[uint16]*proxySession
+	nextID   uint16
+	closed   bool
+	ln       net.Listener
+}
+
+// ProxyOption configures a Proxy.
+type ProxyOption func(*Proxy)
+
+// WithProxyPassword makes clients authenticate with password, as they would
+// with a device that has a comm key set.
+func WithProxyPassword(password int) ProxyOption {
+	return func(p *Proxy) {
+		p.password = password
+	}
+}
+
+// WithProxyIdleTimeout disconnects clients that send nothing for d.
+// Default is 5 minutes; 0 disables the timeout.
+func WithProxyIdleTimeout(d time.Duration) ProxyOption {
+	return func(p *Proxy) {
+		p.idleTimeout = d
+	}
+}
+
+// WithProxyBackoff sets how long the proxy waits after a failed device
+// reconnect before trying again; commands arriving meanwhile fail with
+// CMD_ACK_ERROR instead of each dialing the device. Default is
+// DefaultBackoff.
+func WithProxyBackoff(b Backoff) ProxyOption {
+	return func(p *Proxy) {
+		if b != nil {
+			p.backoff = b
+		}
+	}
+}
+
+// NewProxy creates a Proxy for zk. The client should already be connected; it
+// is reconnected if the device connection fails. It must not be used directly
+// while the Proxy is serving.
+func NewProxy(zk *ZKTeco, opts ...ProxyOption) *Proxy {
+	p := &Proxy{
+		zk:          zk,
+		idleTimeout: 5 * time.Minute,
+		backoff:     DefaultBackoff,
+		sessions:    make(map[uint16]*proxySession),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// ListenAndServe listens on the TCP address addr and serves clients.
+func (p *Proxy) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("proxy: %w", err)
+	}
+	return p.Serve(ln)
+}
+
+// Serve accepts clients on ln until Close is called. It always returns a
+// non-nil error; after Close it returns net.ErrClosed.
+func (p *Proxy) Serve(ln net.Listener) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		ln.Close()
+		return net.ErrClosed
+	}
+	p.ln = ln
+	p.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			p.mu.Lock()
+			closed := p.closed
+			p.mu.Unlock()
+			if closed {
+				return net.ErrClosed
+			}
+			return fmt.Errorf("proxy: accept: %w", err)
+		}
+		go p.serveClient(conn)
+	}
+}
+
+// Sessions returns the number of connected client sessions.
+func (p *Proxy) Sessions() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sessions)
+}
+
+// Close stops accepting clients, disconnects the connected ones and
+// disconnects the device.
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	if p.ln != nil {
+		p.ln.Close()
+	}
+	for _, s := range p.sessions {
+		s.conn.Close()
+	}
+	p.mu.Unlock()
+
+	p.device.Lock()
+	defer p.device.Unlock()
+	return p.zk.Disconnect()
+}
+
+// proxyMaxPacket caps client packets when the client has no
+// WithMaxBufferSize.
+const proxyMaxPacket = 16 << 20
+
+// proxySession is the state of one client connection.
+type proxySession struct {
+	id      uint16
+	conn    net.Conn
+	buf     []byte
+	maxSize int // longest packet accepted, framing included
+	authed  bool
+	holding bool // the device is reserved for an upload in progress
+}
+
+func (p *Proxy) serveClient(conn net.Conn) {
+	s := &proxySession{conn: conn, maxSize: p.zk.maxBufferSize}
+	if s.maxSize <= 0 {
+		s.maxSize = proxyMaxPacket
+	}
+	defer func() {
+		// A bug triggered by one client ends its session only
+		if r := recover(); r != nil {
+			p.zk.warn("proxy session ended by panic", "client", conn.RemoteAddr().String(), "panic", r)
+		}
+		if s.holding {
+			p.device.Unlock()
+		}
+		p.mu.Lock()
+		delete(p.sessions, s.id)
+		p.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		// An upload in progress reserves the device, so its client only
+		// gets the device timeout to send the next packet
+		if s.holding && p.zk.timeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(p.zk.timeout))
+		} else if p.idleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(p.idleTimeout))
+		} else {
+			conn.SetReadDeadline(time.Time{})
+		}
+		raw, err := s.readPacket()
+		if err != nil {
+			return
+		}
+		pkt, err := protocol.ParsePacket(raw)
+		if err != nil {
+			return
+		}
+		if !p.handle(s, pkt) {
+			return
+		}
+	}
+}
+
+// handle answers one client packet. It returns false when the session ends.
+func (p *Proxy) handle(s *proxySession, pkt *protocol.Packet) bool {
+	switch pkt.Command {
+	case CMD_CONNECT:
+		if s.id == 0 {
+			s.id = p.newSession(s)
+		}
+		if p.password != 0 {
+			return s.reply(CMD_ACK_UNAUTH, pkt.ReplyID, nil) == nil
+		}
+		s.authed = true
+		return s.reply(CMD_ACK_OK, pkt.ReplyID, nil) == nil
+
+	case CMD_ACK_AUTH:
+		if s.id == 0 || !bytes.Equal(pkt.Data, makeCommKey(p.password, s.id)) {
+			s.reply(CMD_ACK_UNAUTH, pkt.ReplyID, nil)
+			return false
+		}
+		s.authed = true
+		return s.reply(CMD_ACK_OK, pkt.ReplyID, nil) == nil
+
+	case CMD_EXIT:
+		s.reply(CMD_ACK_OK, pkt.ReplyID, nil)
+		return false
+	}
+
+	if !s.authed {
+		s.reply(CMD_ACK_UNAUTH, pkt.ReplyID, nil)
+		return false
+	}
+
+	if pkt.Command == CMD_REG_EVENT {
+		return s.reply(CMD_ACK_ERROR, pkt.ReplyID, nil) == nil
+	}
+
+	replies, err := p.forward(s, pkt.Command, pkt.Data)
+	if err != nil {
+		return s.reply(CMD_ACK_ERROR, pkt.ReplyID, nil) == nil
+	}
+	for _, r := range replies {
+		if err := s.reply(r.cmd, pkt.ReplyID, r.data); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Proxy) newSession(s *proxySession) uint16 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		p.nextID++
+		if _, used := p.sessions[p.nextID]; p.nextID != 0 && !used {
+			p.sessions[p.nextID] = s
+			return p.nextID
+		}
+	}
+}
+
+// proxyReply is one packet sent back to the client.
+type proxyReply struct {
+	cmd  uint16
+	data []byte
+}
+
+// forward runs cmd on the device and returns the packets to send back.
+// Upload sequences keep the device reserved for the session until the
+// command following the last CMD_DATA.
+func (p *Proxy) forward(s *proxySession, cmd uint16, data []byte) ([]proxyReply, error) {
+	if !s.holding {
+		p.device.Lock()
+	}
+	s.holding = cmd == CMD_PREPARE_DATA || cmd == CMD_DATA
+	if !s.holding {
+		defer p.device.Unlock()
+	}
+
+	if !p.zk.connected() {
+		if err := p.reconnect(); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, allData, err := p.zk.RawCommand(cmd, data)
+	if err != nil {
+		p.dropDevice(err)
+		return nil, err
+	}
+	pkt, err := parsePacket(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	if pkt.Command != CMD_PREPARE_DATA {
+		return []proxyReply{{pkt.Command, pkt.Data}}, nil
+	}
+
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(allData)))
+	return []proxyReply{
+		{CMD_PREPARE_DATA, size},
+		{CMD_DATA, allData},
+		{CMD_ACK_OK, nil},
+	}, nil
+}
+
+// reconnect connects the device again unless the backoff after the last
+// failed attempt is still running. The device lock must be held.
+func (p *Proxy) reconnect() error {
+	if p.zk.clock.Now().Before(p.nextAttempt) {
+		return fmt.Errorf("proxy: device unavailable, next reconnect at %s", p.nextAttempt.Format(time.TimeOnly))
+	}
+	if err := p.zk.Connect(); err != nil {
+		p.failures++
+		p.nextAttempt = p.zk.clock.Now().Add(p.backoff.Delay(p.failures))
+		return err
+	}
+	p.failures = 0
+	p.nextAttempt = time.Time{}
+	return nil
+}
+
+// dropDevice disconnects the device after a network error or a session
+// reset so the next command reconnects it.
+func (p *Proxy) dropDevice(err error) {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, ErrSessionReset) {
+		p.zk.Disconnect()
+	}
+}
+
+// readPacket reads the next TCP-framed packet from the client.
+func (s *proxySession) readPacket() ([]byte, error) {
+	for {
+		if payload, rest, ok := protocol.ExtractTCPPacket(s.buf); ok {
+			s.buf = rest
+			return payload, nil
+		}
+		if len(s.buf) >= 8 && !bytes.Equal(s.buf[:4], protocol.TCPMagic) {
+			return nil, fmt.Errorf("invalid TCP framing")
+		}
+		if len(s.buf) >= 8 {
+			if size := 8 + int64(binary.LittleEndian.Uint32(s.buf[4:8])); size > int64(s.maxSize) {
+				return nil, fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrBufferOverflow, size, s.maxSize)
+			}
+		}
+
+		buf := make([]byte, 16384)
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		s.buf = append(s.buf, buf[:n]...)
+	}
+}
+
+// reply sends a packet to the client echoing its reply ID.
+func (s *proxySession) reply(cmd uint16, replyID uint16, data []byte) error {
+	buf := make([]byte, 8+len(data))
+	binary.LittleEndian.PutUint16(buf[0:2], cmd)
+	binary.LittleEndian.PutUint16(buf[4:6], s.id)
+	binary.LittleEndian.PutUint16(buf[6:8], replyID)
+	copy(buf[8:], data)
+	binary.LittleEndian.PutUint16(buf[2:4], protocol.Checksum(buf))
+
+	s.conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+	_, err := s.conn.Write(protocol.WrapTCP(buf))
+	return err
+}
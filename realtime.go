@@ -1,76 +1,114 @@
 package zkteco
 
 import (
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // RealTimeEvent represents a real-time event from the device.
 type RealTimeEvent struct {
-	EventType   int       `json:"event_type"`
-	EventName   string    `json:"event_name"`
-	UserID      string    `json:"user_id,omitempty"`
-	Time        time.Time `json:"time,omitempty"`
-	State       int       `json:"state,omitempty"`
-	DeviceIP    string    `json:"device_ip,omitempty"`
-	RawData     []byte    `json:"raw_data,omitempty"`
-	FingerIndex int       `json:"finger_index,omitempty"`
-	ButtonID    int       `json:"button_id,omitempty"`
-	DoorID      int       `json:"door_id,omitempty"`
-	UnlockType  int       `json:"unlock_type,omitempty"`
-	AlarmType   int       `json:"alarm_type,omitempty"`
+	EventType    int       `json:"event_type"`
+	EventName    string    `json:"event_name"`
+	UserID       string    `json:"user_id,omitempty"`
+	Time         time.Time `json:"time,omitempty"`
+	State        int       `json:"state,omitempty"` // verification mode, as in Attendance.State
+	Type         int       `json:"type,omitempty"`  // punch type, as in Attendance.Type
+	DeviceIP     string    `json:"device_ip,omitempty"`
+	RawData      []byte    `json:"raw_data,omitempty"`
+	FingerIndex  int       `json:"finger_index,omitempty"`
+	ButtonID     int       `json:"button_id,omitempty"`
+	DoorID       int       `json:"door_id,omitempty"`
+	UnlockType   int       `json:"unlock_type,omitempty"`
+	AlarmType    int       `json:"alarm_type,omitempty"`
+	CardNo       int       `json:"card_no,omitempty"`       // card events: card number, enrolled or not
+	Verified     bool      `json:"verified,omitempty"`      // verify events: the user was recognized
+	VerifyCode   int       `json:"verify_code,omitempty"`   // verify events: firmware result code, 0 = success
+	Score        int       `json:"score,omitempty"`         // verify events: match score, where reported
+	DeviceSerial string    `json:"device_serial,omitempty"` // see WithRecordSerial
+	DecodeError  string    `json:"decode_error,omitempty"`  // set when the payload could not be decoded; see RawData
+	Replayed     bool      `json:"replayed,omitempty"`      // built from a stored record by AttendanceEvent
 }
 
 // EventCallback is called when a real-time event is received.
 type EventCallback func(event RealTimeEvent)
 
+// EventHandler is called when a real-time event is received. Returning a
+// non-nil error stops listening; ErrStopListening stops without an error.
+type EventHandler func(event RealTimeEvent) error
+
+// ErrStopListening can be returned by an EventHandler to stop listening
+// without reporting an error.
+var ErrStopListening = errors.New("stop listening")
+
 // GetRealTimeLogs listens for real-time attendance log events.
 func (z *ZKTeco) GetRealTimeLogs(callback EventCallback, timeout time.Duration) error {
 	return z.GetRealTimeEvents(callback, EF_ATTLOG, timeout)
 }
 
 // GetRealTimeEvents listens for real-time events matching the event mask.
-func (z *ZKTeco) GetRealTimeEvents(callback EventCallback, eventMask int, timeout time.Duration) error {
-	data := make([]byte, 4)
-	binary.LittleEndian.PutUint32(data, uint32(eventMask))
+func (z *ZKTeco) GetRealTimeEvents(callback EventCallback, eventMask int, timeout time.Duration) (err error) {
+	defer z.recoverInternal("getRealTimeEvents", &err)
+	return z.ListenEvents(func(event RealTimeEvent) error {
+		callback(event)
+		return nil
+	}, eventMask, timeout)
+}
 
-	resp, err := z.command(CMD_REG_EVENT, data, "general")
-	if err != nil {
-		return fmt.Errorf("register events: %w", err)
-	}
+// ListenEvents listens for real-time events matching the event mask until
+// timeout elapses (0 = forever) or handler returns an error. The handler's
+// error is returned, except ErrStopListening which yields nil.
+func (z *ZKTeco) ListenEvents(handler EventHandler, eventMask int, timeout time.Duration) (err error) {
+	defer z.recoverInternal("listenEvents", &err)
+	return z.listenEvents(handler, eventMask, timeout, nil)
+}
 
-	pkt, err := parsePacket(resp)
-	if err != nil {
-		return fmt.Errorf("parse reg event response: %w", err)
-	}
-	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("register events: error response %d", pkt.Command)
+// listenEvents implements ListenEvents. If beforeRead is set, it is called
+// before every read so the caller can take over the connection between reads.
+func (z *ZKTeco) listenEvents(handler EventHandler, eventMask int, timeout time.Duration, beforeRead func() error) error {
+	// Events arriving during other commands are parked for this loop
+	z.mu.Lock()
+	z.listeners++
+	z.mu.Unlock()
+	defer func() {
+		z.mu.Lock()
+		z.listeners--
+		z.mu.Unlock()
+	}()
+
+	if err := z.registerEvents(eventMask); err != nil {
+		return err
 	}
 
-	startTime := time.Now()
+	ctx := z.callContext(context.Background())
+	startTime := z.clock.Now()
 
 	for {
-		if timeout > 0 && time.Since(startTime) >= timeout {
+		if timeout > 0 && z.clock.Now().Sub(startTime) >= timeout {
 			break
 		}
 
+		if beforeRead != nil {
+			if err := beforeRead(); err != nil {
+				if errors.Is(err, ErrStopListening) {
+					return nil
+				}
+				return err
+			}
+		}
+
 		readTimeout := 1 * time.Second
 		if timeout > 0 {
-			remaining := timeout - time.Since(startTime)
+			remaining := timeout - z.clock.Now().Sub(startTime)
 			if remaining < readTimeout {
 				readTimeout = remaining
 			}
 		}
-		z.conn.SetReadDeadline(time.Now().Add(readTimeout))
-
-		var payload []byte
-		if z.IsTCP() {
-			payload, err = z.recvTCP()
-		} else {
-			payload, err = z.recvUDP()
-		}
+		payload, err := z.nextEvent(ctx, readTimeout)
 
 		if err != nil {
 			if netErr, ok := err.(interface{ Timeout() bool }); ok && netErr.Timeout() {
@@ -99,18 +137,99 @@ func (z *ZKTeco) GetRealTimeEvents(callback EventCallback, eventMask int, timeou
 		}
 
 		event := z.decodeRealTimeEvent(payload, eventType)
-		callback(event)
+		if err := z.callHandler(handler, event); err != nil {
+			if errors.Is(err, ErrStopListening) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithUnsolicitedEvents sets a handler for the real-time events that arrive
+// while a command awaits its reply and no listener is running, e.g. from
+// firmwares that push events to sessions that never registered for them,
+// or after ListenEvents returned. Such events never corrupt the reply;
+// without a handler they are discarded and logged (WithLogger). The handler
+// runs inside the command, so it must not use the client itself.
+func WithUnsolicitedEvents(handler EventCallback) Option {
+	return func(z *ZKTeco) {
+		z.onUnsolicited = handler
+	}
+}
+
+// divertEvent takes an event packet received while a reply was awaited: it
+// is parked for the next read of a running listener, handed to the
+// WithUnsolicitedEvents handler, or discarded. The caller holds z.mu.
+func (z *ZKTeco) divertEvent(pkt []byte) {
+	if z.listeners > 0 {
+		z.parkEvent(pkt)
+		return
+	}
+	eventType := int(binary.LittleEndian.Uint16(pkt[4:6]))
+	if z.onUnsolicited == nil {
+		z.warn("discarded unsolicited event", "event", EventName(eventType))
+		return
+	}
+	z.onUnsolicited(z.decodeRealTimeEvent(pkt, eventType))
+}
+
+// nextEvent returns the next packet of a listener, a parked event or one
+// read within readTimeout, giving up when ctx is done. It holds the
+// connection for the read only, so other goroutines' commands run between
+// reads.
+func (z *ZKTeco) nextEvent(ctx context.Context, readTimeout time.Duration) ([]byte, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if payload := z.nextParkedEvent(); payload != nil {
+		return payload, nil
+	}
+	if z.transport == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	stop := z.interruptOn(ctx)
+	defer stop()
+	if err := z.armRead(ctx, time.Now().Add(readTimeout)); err != nil {
+		return nil, err
+	}
+	return z.transport.Recv()
+}
+
+// callHandler runs handler, re-enabling the device if it panics.
+func (z *ZKTeco) callHandler(handler EventHandler, event RealTimeEvent) error {
+	defer z.reenableOnPanic()
+	return handler(event)
+}
+
+// registerEvents subscribes to the events in mask; 0 unsubscribes from all.
+func (z *ZKTeco) registerEvents(mask int) error {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, uint32(mask))
+
+	resp, err := z.command(CMD_REG_EVENT, data, "general")
+	if err != nil {
+		return fmt.Errorf("register events: %w", err)
 	}
 
+	pkt, err := parsePacket(resp)
+	if err != nil {
+		return fmt.Errorf("parse reg event response: %w", err)
+	}
+	if pkt.Command != CMD_ACK_OK {
+		return fmt.Errorf("register events: error response %s", commandString(pkt.Command))
+	}
 	return nil
 }
 
 func (z *ZKTeco) decodeRealTimeEvent(payload []byte, eventType int) RealTimeEvent {
 	event := RealTimeEvent{
-		EventType: eventType,
-		EventName: EventName(eventType),
-		DeviceIP:  z.host,
-		Time:      time.Now(),
+		EventType:    eventType,
+		EventName:    EventName(eventType),
+		DeviceIP:     z.host,
+		DeviceSerial: z.serial,
+		Time:         z.clock.Now(),
 	}
 
 	if len(payload) <= 8 {
@@ -120,64 +239,143 @@ func (z *ZKTeco) decodeRealTimeEvent(payload []byte, eventType int) RealTimeEven
 
 	recvData := payload[8:]
 
+	var err error
 	switch eventType {
 	case EF_ATTLOG:
-		event = z.decodeAttLogEvent(recvData, event)
-	case EF_ENROLLUSER, EF_VERIFY:
-		if len(recvData) >= 9 {
+		event, err = z.decodeAttLogEvent(recvData, event)
+	case EF_ENROLLUSER:
+		if err = needEventBytes(recvData, 9); err == nil {
 			event.UserID = strings.TrimRight(string(recvData[0:9]), "\x00")
 		}
+	case EF_VERIFY:
+		if err = needEventBytes(recvData, 9); err == nil {
+			decodeVerifyEvent(recvData, &event)
+		}
 	case EF_FINGER, EF_ENROLLFINGER, EF_FPFTR:
-		if len(recvData) >= 10 {
+		if err = needEventBytes(recvData, 10); err == nil {
 			event.UserID = strings.TrimRight(string(recvData[0:9]), "\x00")
 			event.FingerIndex = int(recvData[9])
 		}
 	case EF_BUTTON:
-		if len(recvData) >= 2 {
+		if err = needEventBytes(recvData, 2); err == nil {
 			event.ButtonID = int(binary.LittleEndian.Uint16(recvData[0:2]))
 		}
 	case EF_UNLOCK:
-		if len(recvData) >= 2 {
+		if err = needEventBytes(recvData, 2); err == nil {
 			event.DoorID = int(recvData[0])
 			event.UnlockType = int(recvData[1])
 		}
+	case EF_HIDNUM:
+		if err = needEventBytes(recvData, 4); err == nil {
+			event.CardNo = int(binary.LittleEndian.Uint32(recvData[0:4]))
+		}
 	case EF_ALARM:
-		if len(recvData) >= 2 {
+		if err = needEventBytes(recvData, 2); err == nil {
 			event.AlarmType = int(binary.LittleEndian.Uint16(recvData[0:2]))
 		}
 	default:
 		event.RawData = recvData
 	}
 
+	if err != nil {
+		event.DecodeError = err.Error()
+		event.RawData = recvData
+	}
+
 	return event
 }
 
-func (z *ZKTeco) decodeAttLogEvent(recvData []byte, event RealTimeEvent) RealTimeEvent {
-	if len(recvData) < 32 {
-		event.RawData = recvData
-		return event
+// decodeVerifyEvent decodes an EF_VERIFY payload:
+// user ID(9) [+ result(1) [+ score(2)]]. Older firmwares only send the user
+// ID, and report a rejected verification with the user ID "-1".
+func decodeVerifyEvent(recvData []byte, event *RealTimeEvent) {
+	event.UserID = strings.TrimRight(string(recvData[0:9]), "\x00")
+	if len(recvData) >= 10 {
+		event.VerifyCode = int(recvData[9])
+	}
+	if len(recvData) >= 12 {
+		event.Score = int(binary.LittleEndian.Uint16(recvData[10:12]))
 	}
+	event.Verified = event.UserID != "" && event.UserID != "-1" && event.VerifyCode == 0
+}
 
-	event.UserID = strings.TrimRight(string(recvData[0:9]), "\x00")
+// needEventBytes checks that an event payload holds at least n bytes.
+func needEventBytes(data []byte, n int) error {
+	if len(data) < n {
+		return fmt.Errorf("event payload too short: %d bytes, want %d", len(data), n)
+	}
+	return nil
+}
 
-	if len(recvData) > 24 {
-		event.State = int(recvData[24])
+// decodeAttLogEvent decodes an EF_ATTLOG payload. Models differ in how they
+// encode the user ID, so the layout is picked from the payload length:
+//
+//	>= 32 bytes: user ID string(24) + verify(1) + state(1) + time(6)
+//	>= 25 bytes: user ID string(9) + verify(1) + state(1) + time(6)
+//	   14 bytes: user ID uint16 + verify(1) + state(1) + time(6) + work code(4)
+//	>= 12 bytes: user ID uint32 + verify(1) + state(1) + time(6)
+//	>= 10 bytes: user ID uint16 + verify(1) + state(1) + time(6)
+//
+// Longer payloads of the string variants carry a work code or reserved
+// bytes; the work code is not decoded.
+func (z *ZKTeco) decodeAttLogEvent(recvData []byte, event RealTimeEvent) (RealTimeEvent, error) {
+	var at int
+	switch n := len(recvData); {
+	case n >= 32:
+		event.UserID = strings.TrimRight(string(recvData[0:9]), "\x00")
+		at = 24
+	case n >= 25:
+		event.UserID = strings.TrimRight(string(recvData[0:9]), "\x00")
+		at = 9
+	case n == 14:
+		event.UserID = strconv.Itoa(int(binary.LittleEndian.Uint16(recvData[0:2])))
+		at = 2
+	case n >= 12:
+		event.UserID = strconv.FormatUint(uint64(binary.LittleEndian.Uint32(recvData[0:4])), 10)
+		at = 4
+	case n >= 10:
+		event.UserID = strconv.Itoa(int(binary.LittleEndian.Uint16(recvData[0:2])))
+		at = 2
+	default:
+		return event, needEventBytes(recvData, 10)
 	}
 
-	if len(recvData) >= 32 {
-		year := 2000 + int(recvData[26])
-		month := int(recvData[27])
-		day := int(recvData[28])
-		hour := int(recvData[29])
-		minute := int(recvData[30])
-		second := int(recvData[31])
+	event.State = int(recvData[at])
+	event.Type = int(recvData[at+1])
 
-		if month >= 1 && month <= 12 && day >= 1 && day <= 31 {
-			event.Time = time.Date(year, time.Month(month), day, hour, minute, second, 0, time.Local)
-		}
+	t := recvData[at+2 : at+8]
+	year := 2000 + int(t[0])
+	month := int(t[1])
+	day := int(t[2])
+	hour := int(t[3])
+	minute := int(t[4])
+	second := int(t[5])
+
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || second > 59 {
+		return event, fmt.Errorf("invalid event time %02d-%02d-%02d %02d:%02d:%02d",
+			year, month, day, hour, minute, second)
 	}
+	event.Time = time.Date(year, time.Month(month), day, hour, minute, second, 0, time.Local)
 
-	return event
+	return event, nil
+}
+
+// AttendanceEvent returns the EF_ATTLOG event the device would have sent for
+// the attendance record a, marked as Replayed, so records fetched after an
+// outage can be fed to consumers of real-time events in the same shape.
+// deviceIP is reported as the event's DeviceIP.
+func AttendanceEvent(a Attendance, deviceIP string) RealTimeEvent {
+	return RealTimeEvent{
+		EventType:    EF_ATTLOG,
+		EventName:    EventName(EF_ATTLOG),
+		UserID:       a.UserID,
+		Time:         a.RecordTime,
+		State:        a.State,
+		Type:         a.Type,
+		DeviceIP:     deviceIP,
+		DeviceSerial: a.DeviceSerial,
+		Replayed:     true,
+	}
 }
 
 // EventName returns a human-readable name for an event type.
@@ -195,12 +393,20 @@ func EventName(eventType int) string {
 		return "button"
 	case EF_UNLOCK:
 		return "unlock"
+	case EF_HIDNUM:
+		return "card"
 	case EF_VERIFY:
 		return "verify"
 	case EF_FPFTR:
 		return "finger_feature"
 	case EF_ALARM:
 		return "alarm"
+	case EF_DOOR_CLOSE:
+		return "door_close"
+	case EF_EXIT_BUTTON:
+		return "exit_button"
+	case EF_REMOTE_OPEN:
+		return "remote_open"
 	default:
 		return "unknown"
 	}
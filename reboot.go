@@ -0,0 +1,64 @@
+package zkteco
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrSessionReset is returned when the device answers a command of an
+// established session with CMD_ACK_UNAUTH: it no longer knows the session,
+// typically because it rebooted. Connect again to continue.
+var ErrSessionReset = errors.New("session reset by device")
+
+// RebootEvent reports that the device was found to have rebooted.
+type RebootEvent struct {
+	Host       string    `json:"host"`
+	Serial     string    `json:"serial,omitempty"` // set with WithRecordSerial
+	SessionID  uint16    `json:"session_id"`       // the session the device forgot
+	Command    uint16    `json:"command"`          // the command that noticed it
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// WithOnReboot calls fn when the device is found to have rebooted, so
+// long-lived clients can re-sync and register their events again. A reboot
+// is noticed when the device answers a command with CMD_ACK_UNAUTH for a
+// session it had accepted; the command fails with ErrSessionReset and fn is
+// called once for the session. fn runs in the command that noticed it and
+// must not use the client; reconnect once that command has returned. A
+// reboot that drops a TCP connection shows up as a connection error instead,
+// indistinguishable from a network failure.
+func WithOnReboot(fn func(RebootEvent)) Option {
+	return func(z *ZKTeco) {
+		z.onReboot = fn
+	}
+}
+
+// checkSessionReset returns ErrSessionReset if resp rejects the session of a
+// command other than the handshake, and reports the reboot.
+func (z *ZKTeco) checkSessionReset(cmd uint16, resp []byte) error {
+	if z.sessionID == 0 || cmd == CMD_CONNECT || cmd == CMD_ACK_AUTH || len(resp) < 8 {
+		return nil
+	}
+	if binary.LittleEndian.Uint16(resp[0:2]) != CMD_ACK_UNAUTH {
+		return nil
+	}
+
+	// A rebooted device is enabled, whatever it was before.
+	z.disabled = false
+	if !z.rebootReported {
+		z.rebootReported = true
+		z.warn("session reset, device rebooted", "command", commandString(cmd), "session", z.sessionID)
+		if z.onReboot != nil {
+			z.onReboot(RebootEvent{
+				Host:       z.host,
+				Serial:     z.serial,
+				SessionID:  z.sessionID,
+				Command:    cmd,
+				DetectedAt: z.clock.Now(),
+			})
+		}
+	}
+	return fmt.Errorf("command %s: %w", commandString(cmd), ErrSessionReset)
+}
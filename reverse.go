@@ -0,0 +1,167 @@
+package zkteco
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrReverseClosed is returned when a client accepted by a ReverseListener
+// connects again after its connection was closed: only the device can open
+// a new one, and it shows up as a new client of the listener.
+var ErrReverseClosed = errors.New("reverse connection closed, wait for the device to reconnect")
+
+// ReverseListener accepts TCP connections opened by devices, for sites
+// behind NAT where the device can only dial out: the device is configured
+// with the address of the server, and the server listens instead of
+// dialing. Once a device has connected, the listener runs the usual
+// handshake over that connection (the server still sends CMD_CONNECT and
+// authenticates) and hands back a connected client:
+//
+//	ln, err := zkteco.ListenReverse(":4370", zkteco.WithPassword(123))
+//	for {
+//	    zk, err := ln.Accept()
+//	    if err != nil {
+//	        return err
+//	    }
+//	    go handle(zk)
+//	}
+//
+// Handshakes run concurrently, so a slow or silent device does not hold up
+// the others; connections whose handshake fails are closed and logged
+// (WithLogger) without failing Accept. The clients are like dialed TCP
+// clients, except that Connect after Disconnect or a connection error fails
+// with ErrReverseClosed.
+type ReverseListener struct {
+	ln      net.Listener
+	opts    []Option
+	clients chan *ZKTeco
+
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error // why the listener stopped, set before done is closed
+}
+
+// ListenReverse listens for devices on the TCP address addr, e.g. ":4370".
+// The options apply to the accepted clients.
+func ListenReverse(addr string, opts ...Option) (*ReverseListener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewReverseListener(ln, opts...), nil
+}
+
+// NewReverseListener accepts devices on ln, e.g. a TLS listener or one
+// handed over by systemd. The options apply to the accepted clients.
+func NewReverseListener(ln net.Listener, opts ...Option) *ReverseListener {
+	l := &ReverseListener{
+		ln:      ln,
+		opts:    opts,
+		clients: make(chan *ZKTeco),
+		done:    make(chan struct{}),
+	}
+	go l.acceptLoop()
+	return l
+}
+
+// Accept waits for the next device to connect and complete the handshake.
+// It fails once the listener is closed or cannot accept connections.
+func (l *ReverseListener) Accept() (*ZKTeco, error) {
+	return l.AcceptContext(context.Background())
+}
+
+// AcceptContext is like Accept but gives up when ctx is done.
+func (l *ReverseListener) AcceptContext(ctx context.Context) (*ZKTeco, error) {
+	select {
+	case z := <-l.clients:
+		return z, nil
+	case <-l.done:
+		return nil, l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Addr returns the address the listener listens on.
+func (l *ReverseListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// Close stops the listener. Clients already accepted stay connected.
+func (l *ReverseListener) Close() error {
+	err := l.ln.Close()
+	l.stop(net.ErrClosed)
+	return err
+}
+
+func (l *ReverseListener) stop(err error) {
+	l.closeOnce.Do(func() {
+		l.err = err
+		close(l.done)
+	})
+}
+
+func (l *ReverseListener) acceptLoop() {
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			l.stop(err)
+			return
+		}
+		go l.handshake(conn)
+	}
+}
+
+// handshake connects a client over conn and hands it to Accept.
+func (l *ReverseListener) handshake(conn net.Conn) {
+	host, port := conn.RemoteAddr().String(), 0
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		host = h
+		port, _ = strconv.Atoi(p)
+	}
+	z := NewZKTeco(host, port, append([]Option{WithProtocol("tcp")}, l.opts...)...)
+	z.customTransport = &reverseTransport{
+		tcpTransport: newTCPTransport(conn.RemoteAddr().String(), z.readBufferSize, z.maxBufferSize),
+		accepted:     conn,
+	}
+	if err := z.Connect(); err != nil {
+		z.warn("reverse connection handshake failed", "error", err)
+		conn.Close()
+		return
+	}
+
+	select {
+	case l.clients <- z:
+	case <-l.done:
+		z.closeTransport()
+	}
+}
+
+// reverseTransport is a TCP transport over a connection the device opened.
+// It can be dialed once.
+type reverseTransport struct {
+	*tcpTransport
+	accepted net.Conn // until the first Dial
+}
+
+func (t *reverseTransport) Dial(timeout time.Duration) error {
+	if t.accepted == nil {
+		return ErrReverseClosed
+	}
+	t.conn, t.accepted = t.accepted, nil
+	t.timeout = timeout
+	t.buf = nil
+	return nil
+}
+
+func (t *reverseTransport) DialContext(ctx context.Context, timeout time.Duration) error {
+	return t.Dial(timeout)
+}
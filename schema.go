@@ -0,0 +1,159 @@
+package zkteco
+
+import (
+	"encoding/hex"
+	"time"
+)
+
+// SchemaVersion is the version of the JSON documents built by
+// RealTimeEvent.Document and Attendance.Document. Fields may be added within
+// a version; renaming or removing one, or changing its meaning, bumps it.
+const SchemaVersion = 1
+
+// EventDocument is the versioned JSON representation of a RealTimeEvent, for
+// webhooks, message queues and APIs. Unlike RealTimeEvent it does not change
+// with the library's structs: names are snake_case, times are RFC 3339 and
+// enums are names instead of device codes. Fields that do not apply to the
+// event type are omitted.
+type EventDocument struct {
+	SchemaVersion int    `json:"schema_version"`
+	Kind          string `json:"kind"`  // always "event"
+	Event         string `json:"event"` // event name, see EventName
+	Time          string `json:"time"`
+	DeviceIP      string `json:"device_ip,omitempty"`
+	DeviceSerial  string `json:"device_serial,omitempty"`
+	UserID        string `json:"user_id,omitempty"`
+
+	VerifyMode  string `json:"verify_mode,omitempty"` // attendance: see VerifyModeName
+	Punch       string `json:"punch,omitempty"`       // attendance: see PunchName
+	FingerIndex *int   `json:"finger_index,omitempty"`
+	Verified    *bool  `json:"verified,omitempty"`
+	VerifyCode  *int   `json:"verify_code,omitempty"`
+	Score       *int   `json:"score,omitempty"`
+	CardNo      *int   `json:"card_no,omitempty"`
+	ButtonID    *int   `json:"button_id,omitempty"`
+	DoorID      *int   `json:"door_id,omitempty"`
+	UnlockType  *int   `json:"unlock_type,omitempty"`
+	AlarmType   *int   `json:"alarm_type,omitempty"`
+
+	DecodeError string `json:"decode_error,omitempty"`
+	RawData     string `json:"raw_data,omitempty"` // hex
+	Replayed    bool   `json:"replayed,omitempty"` // see AttendanceEvent
+}
+
+// AttendanceDocument is the versioned JSON representation of an Attendance
+// record, following the same conventions as EventDocument.
+type AttendanceDocument struct {
+	SchemaVersion   int    `json:"schema_version"`
+	Kind            string `json:"kind"` // always "attendance"
+	UID             int    `json:"uid"`
+	UserID          string `json:"user_id"`
+	Time            string `json:"time"`
+	VerifyMode      string `json:"verify_mode"`
+	Punch           string `json:"punch"`
+	WorkCode        int    `json:"work_code,omitempty"`
+	Reader          int    `json:"reader,omitempty"`
+	Direction       string `json:"direction,omitempty"` // "in" or "out"
+	TimeImplausible bool   `json:"time_implausible,omitempty"`
+	DeviceSerial    string `json:"device_serial,omitempty"`
+	RawData         string `json:"raw_data,omitempty"` // hex; see WithRawRecords
+}
+
+// Document returns the versioned JSON representation of the event.
+func (e RealTimeEvent) Document() EventDocument {
+	doc := EventDocument{
+		SchemaVersion: SchemaVersion,
+		Kind:          "event",
+		Event:         e.EventName,
+		Time:          e.Time.Format(time.RFC3339),
+		DeviceIP:      e.DeviceIP,
+		DeviceSerial:  e.DeviceSerial,
+		UserID:        e.UserID,
+		DecodeError:   e.DecodeError,
+		Replayed:      e.Replayed,
+	}
+	if len(e.RawData) > 0 {
+		doc.RawData = hex.EncodeToString(e.RawData)
+	}
+	if e.DecodeError != "" {
+		return doc
+	}
+
+	switch e.EventType {
+	case EF_ATTLOG:
+		doc.VerifyMode = VerifyModeName(e.State)
+		doc.Punch = PunchName(e.Type)
+	case EF_FINGER, EF_ENROLLFINGER, EF_FPFTR:
+		doc.FingerIndex = &e.FingerIndex
+	case EF_VERIFY:
+		doc.Verified = &e.Verified
+		doc.VerifyCode = &e.VerifyCode
+		doc.Score = &e.Score
+	case EF_HIDNUM:
+		doc.CardNo = &e.CardNo
+	case EF_BUTTON:
+		doc.ButtonID = &e.ButtonID
+	case EF_UNLOCK:
+		doc.DoorID = &e.DoorID
+		doc.UnlockType = &e.UnlockType
+	case EF_ALARM:
+		doc.AlarmType = &e.AlarmType
+	}
+	return doc
+}
+
+// Document returns the versioned JSON representation of the record.
+func (a Attendance) Document() AttendanceDocument {
+	return AttendanceDocument{
+		SchemaVersion:   SchemaVersion,
+		Kind:            "attendance",
+		UID:             a.UID,
+		UserID:          a.UserID,
+		Time:            a.RecordTime.Format(time.RFC3339),
+		VerifyMode:      VerifyModeName(a.State),
+		Punch:           PunchName(a.Type),
+		WorkCode:        a.WorkCode,
+		Reader:          a.Reader,
+		Direction:       string(a.Direction),
+		TimeImplausible: a.TimeImplausible,
+		DeviceSerial:    a.DeviceSerial,
+		RawData:         hex.EncodeToString(a.Raw),
+	}
+}
+
+// VerifyModeName returns the schema name of a verification mode
+// (Attendance.State): "password", "fingerprint", "card" or "unknown".
+func VerifyModeName(state int) string {
+	switch state {
+	case STATE_PASSWORD:
+		return "password"
+	case STATE_FINGERPRINT:
+		return "fingerprint"
+	case STATE_CARD:
+		return "card"
+	default:
+		return "unknown"
+	}
+}
+
+// PunchName returns the schema name of a punch type (Attendance.Type):
+// "check_in", "check_out", "break_in", "break_out", "overtime_in",
+// "overtime_out" or "unknown".
+func PunchName(typ int) string {
+	switch typ {
+	case TYPE_CHECK_IN:
+		return "check_in"
+	case TYPE_CHECK_OUT:
+		return "check_out"
+	case TYPE_BREAK_IN:
+		return "break_in"
+	case TYPE_BREAK_OUT:
+		return "break_out"
+	case TYPE_OVERTIME_IN:
+		return "overtime_in"
+	case TYPE_OVERTIME_OUT:
+		return "overtime_out"
+	default:
+		return "unknown"
+	}
+}
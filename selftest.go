@@ -0,0 +1,99 @@
+package zkteco
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SelfTest is the result of RunSelfTest. A nil error means the check passed.
+type SelfTest struct {
+	Sensor     error     // fingerprint sensor test (CMD_TEST_TEMP)
+	Clock      error     // the clock reads a plausible time and is running
+	Voice      error     // voice prompt 0 ("Thank You") played
+	DeviceTime time.Time // the time read by the clock check
+}
+
+// SensorOK reports whether the fingerprint sensor test passed.
+func (t *SelfTest) SensorOK() bool { return t.Sensor == nil }
+
+// ClockOK reports whether the clock check passed.
+func (t *SelfTest) ClockOK() bool { return t.Clock == nil }
+
+// VoiceOK reports whether the voice check passed.
+func (t *SelfTest) VoiceOK() bool { return t.Voice == nil }
+
+// Passed reports whether all checks passed.
+func (t *SelfTest) Passed() bool {
+	return t.SensorOK() && t.ClockOK() && t.VoiceOK()
+}
+
+// String returns one line per check, e.g. "clock  ok" or
+// "sensor FAIL: testSensor: error response ACK_ERROR (2001)".
+func (t *SelfTest) String() string {
+	var b strings.Builder
+	for _, c := range []struct {
+		name string
+		err  error
+	}{{"sensor", t.Sensor}, {"clock", t.Clock}, {"voice", t.Voice}} {
+		if c.err != nil {
+			fmt.Fprintf(&b, "%-6s FAIL: %s\n", c.name, c.err)
+		} else {
+			fmt.Fprintf(&b, "%-6s ok\n", c.name)
+		}
+	}
+	return b.String()
+}
+
+// selfTestClockWait is how long RunSelfTest waits between the two clock reads.
+const selfTestClockWait = 2 * time.Second
+
+// RunSelfTest runs the device self-tests, for acceptance testing newly
+// installed terminals: the fingerprint sensor test, a clock check (the time
+// is read twice, two seconds apart, and must be valid and advance by about
+// as much) and a voice prompt. Every check runs even when an earlier one
+// fails, also by panicking (see InternalError); the results are in the
+// returned SelfTest. Voice prompts are not played with WithDryRun.
+func (z *ZKTeco) RunSelfTest() *SelfTest {
+	t := &SelfTest{}
+	t.Sensor = z.selfTestCheck("testSensor", func() error {
+		return z.ackCommand("testSensor", CMD_TEST_TEMP, nil)
+	})
+	t.Clock = z.selfTestCheck("checkClock", func() (err error) {
+		t.DeviceTime, err = z.checkClockRunning()
+		return err
+	})
+	t.Voice = z.selfTestCheck("testVoice", func() error {
+		return z.TestVoice(0)
+	})
+	return t
+}
+
+// selfTestCheck runs a check of RunSelfTest, turning a panic into its error.
+func (z *ZKTeco) selfTestCheck(op string, check func() error) (err error) {
+	defer z.recoverInternal(op, &err)
+	return check()
+}
+
+// checkClockRunning reads the device time twice, selfTestClockWait apart,
+// and checks that it advanced by about as much.
+func (z *ZKTeco) checkClockRunning() (time.Time, error) {
+	first, err := z.GetTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	ctx := z.callContext(context.Background())
+	if !sleep(ctx, z.clock, selfTestClockWait) {
+		return first, fmt.Errorf("clock: %w", ctx.Err())
+	}
+	second, err := z.GetTime()
+	if err != nil {
+		return first, err
+	}
+	// The device clock has a resolution of one second.
+	if elapsed := second.Sub(first); elapsed < selfTestClockWait-time.Second || elapsed > selfTestClockWait+time.Second {
+		return second, fmt.Errorf("clock: advanced %s in %s", elapsed, selfTestClockWait)
+	}
+	return second, nil
+}
@@ -0,0 +1,276 @@
+package zkteco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Component is a long-running part of a Service, such as a Proxy, a Listener
+// or a device connection.
+type Component interface {
+	// Start starts the component and returns once it is running; ongoing
+	// work continues in the background.
+	Start(ctx context.Context) error
+	// Stop finishes in-flight work and releases the component. It returns
+	// ctx.Err() if ctx expires first.
+	Stop(ctx context.Context) error
+}
+
+// Stage orders the shutdown of the components of a Service: all components
+// of a stage are stopped, concurrently, before the next stage. Components
+// are started in the reverse order.
+type Stage int
+
+const (
+	// StageGateway components take requests from outside, e.g. a Proxy.
+	// They are stopped first so no new work arrives.
+	StageGateway Stage = iota
+	// StageWorker components run jobs such as polls and transfers, which
+	// are allowed to finish.
+	StageWorker
+	// StageListener components receive real-time events; stopping them
+	// unregisters the events.
+	StageListener
+	// StageConnection components own device connections, closed last.
+	StageConnection
+
+	numStages
+)
+
+// Service owns the components of a daemon built on this package and
+// coordinates their start and their ordered, time-bounded shutdown.
+type Service struct {
+	stopTimeout time.Duration
+
+	mu         sync.Mutex
+	components [numStages][]namedComponent
+	started    [numStages]int // number of components of each stage started
+	running    bool
+}
+
+type namedComponent struct {
+	name string
+	c    Component
+}
+
+// NewService creates a Service whose Stop gives up on components still
+// stopping after stopTimeout (0 means no limit).
+func NewService(stopTimeout time.Duration) *Service {
+	return &Service{stopTimeout: stopTimeout}
+}
+
+// Add registers a component under name in stage. Components must be added
+// before Start.
+func (s *Service) Add(stage Stage, name string, c Component) *Service {
+	if stage < 0 || stage >= numStages {
+		panic(fmt.Sprintf("zkteco: invalid service stage %d", stage))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.components[stage] = append(s.components[stage], namedComponent{name, c})
+	return s
+}
+
+// Start starts the components, connections first and gateways last. If one
+// fails, the components already started are stopped and the error returned.
+func (s *Service) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running {
+		return fmt.Errorf("service: already started")
+	}
+	s.running = true
+
+	for stage := numStages - 1; stage >= 0; stage-- {
+		for _, nc := range s.components[stage] {
+			if err := nc.c.Start(ctx); err != nil {
+				startErr := fmt.Errorf("service: start %s: %w", nc.name, err)
+				return errors.Join(startErr, s.stopLocked())
+			}
+			s.started[stage]++
+		}
+	}
+	return nil
+}
+
+// Stop stops the started components stage by stage: gateways, workers,
+// listeners, then connections. It returns the errors of the components that
+// failed or did not stop within the stop timeout.
+func (s *Service) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopLocked()
+}
+
+func (s *Service) stopLocked() error {
+	ctx := context.Background()
+	if s.stopTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.stopTimeout)
+		defer cancel()
+	}
+
+	started := s.started
+	s.started = [numStages]int{}
+	s.running = false
+
+	var errs []error
+	for stage := Stage(0); stage < numStages; stage++ {
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		for _, nc := range s.components[stage][:started[stage]] {
+			wg.Add(1)
+			go func(nc namedComponent) {
+				defer wg.Done()
+				if err := nc.c.Stop(ctx); err != nil {
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("service: stop %s: %w", nc.name, err))
+					mu.Unlock()
+				}
+			}(nc)
+		}
+		wg.Wait()
+	}
+	return errors.Join(errs...)
+}
+
+// Run starts the service, waits until ctx is canceled and stops it.
+func (s *Service) Run(ctx context.Context) error {
+	if err := s.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return s.Stop()
+}
+
+// stopWithin runs stop, returning early with ctx.Err() if ctx expires
+// first. stop keeps running in the background in that case.
+func stopWithin(ctx context.Context, stop func() error) error {
+	done := make(chan error, 1)
+	go func() { done <- stop() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ClientComponent connects zk on Start and disconnects it on Stop, for
+// StageConnection.
+func ClientComponent(zk *ZKTeco) Component {
+	return clientComponent{zk}
+}
+
+type clientComponent struct{ zk *ZKTeco }
+
+func (c clientComponent) Start(context.Context) error {
+	return c.zk.Connect()
+}
+
+func (c clientComponent) Stop(ctx context.Context) error {
+	return stopWithin(ctx, c.zk.Disconnect)
+}
+
+// ActorComponent closes the actor on Stop, letting queued commands finish,
+// for StageWorker. The actor is started by NewActor.
+func ActorComponent(a *Actor) Component {
+	return actorComponent{a}
+}
+
+type actorComponent struct{ a *Actor }
+
+func (c actorComponent) Start(context.Context) error {
+	return nil
+}
+
+func (c actorComponent) Stop(ctx context.Context) error {
+	return stopWithin(ctx, c.a.Close)
+}
+
+// ListenerComponent runs l.Listen on Start and stops it, unregistering the
+// events, on Stop, for StageListener. Stop returns the error Listen ended
+// with, if it ended on its own.
+func ListenerComponent(l *Listener) Component {
+	return &listenerComponent{l: l}
+}
+
+type listenerComponent struct {
+	l    *Listener
+	done chan error
+}
+
+func (c *listenerComponent) Start(context.Context) error {
+	c.done = make(chan error, 1)
+	go func() { c.done <- c.l.Listen(0) }()
+	return nil
+}
+
+func (c *listenerComponent) Stop(ctx context.Context) error {
+	return stopWithin(ctx, func() error {
+		c.l.Stop()
+		return <-c.done
+	})
+}
+
+// ProxyComponent serves p on the TCP address addr, for StageGateway. Start
+// fails if addr cannot be listened on.
+func ProxyComponent(p *Proxy, addr string) Component {
+	return &proxyComponent{p: p, addr: addr}
+}
+
+type proxyComponent struct {
+	p    *Proxy
+	addr string
+}
+
+func (c *proxyComponent) Start(context.Context) error {
+	ln, err := net.Listen("tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	go c.p.Serve(ln)
+	return nil
+}
+
+func (c *proxyComponent) Stop(ctx context.Context) error {
+	return stopWithin(ctx, c.p.Close)
+}
+
+// RunComponent adapts run, a function that works until its context is
+// canceled (such as RunTimeSync or WatchOptions), to a Component. Stop
+// cancels the context and waits for run to return; a context.Canceled error
+// is not reported.
+func RunComponent(run func(ctx context.Context) error) Component {
+	return &runComponent{run: run}
+}
+
+type runComponent struct {
+	run    func(ctx context.Context) error
+	cancel context.CancelFunc
+	done   chan error
+}
+
+func (c *runComponent) Start(context.Context) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan error, 1)
+	go func() { c.done <- c.run(ctx) }()
+	return nil
+}
+
+func (c *runComponent) Stop(ctx context.Context) error {
+	c.cancel()
+	return stopWithin(ctx, func() error {
+		if err := <-c.done; !errors.Is(err, context.Canceled) {
+			return err
+		}
+		return nil
+	})
+}
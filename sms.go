@@ -0,0 +1,74 @@
+package zkteco
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// SMS is a short message displayed on the device after a punch.
+type SMS struct {
+	ID           int       `json:"id"`
+	Tag          int       `json:"tag"` // SMS_PUBLIC or SMS_PERSONAL
+	Content      string    `json:"content"`
+	Start        time.Time `json:"start"`
+	ValidMinutes int       `json:"valid_minutes"`
+}
+
+// smsRecordSize is the size of one message record:
+// tag(1) + id(2) + valid minutes(2) + reserved(2) + start(4) + content(61).
+const smsRecordSize = 72
+
+// SetSMS creates or updates a short message. Personal messages
+// (SMS_PERSONAL) are only shown to users assigned with AssignSMS.
+func (z *ZKTeco) SetSMS(sms SMS) (err error) {
+	defer z.recoverInternal("setSMS", &err)
+	if sms.Tag != SMS_PUBLIC && sms.Tag != SMS_PERSONAL {
+		return fmt.Errorf("setSMS: invalid tag %d", sms.Tag)
+	}
+	if sms.Start.IsZero() {
+		sms.Start = z.clock.Now()
+	}
+
+	data := make([]byte, smsRecordSize)
+	data[0] = byte(sms.Tag)
+	binary.LittleEndian.PutUint16(data[1:3], uint16(sms.ID))
+	binary.LittleEndian.PutUint16(data[3:5], uint16(sms.ValidMinutes))
+	binary.LittleEndian.PutUint32(data[7:11], encodeTime(sms.Start))
+
+	content := sms.Content
+	if len(content) > 60 {
+		content = content[:60]
+	}
+	copy(data[11:], content)
+
+	return z.ackCommand("setSMS", CMD_SMS_WRQ, data)
+}
+
+// DeleteSMS deletes a short message by ID.
+func (z *ZKTeco) DeleteSMS(smsID int) (err error) {
+	defer z.recoverInternal("deleteSMS", &err)
+	data := make([]byte, 2)
+	binary.LittleEndian.PutUint16(data, uint16(smsID))
+	return z.ackCommand("deleteSMS", CMD_DELETE_SMS, data)
+}
+
+// AssignSMS shows the personal message smsID to the user with the given UID.
+func (z *ZKTeco) AssignSMS(uid int, smsID int) (err error) {
+	defer z.recoverInternal("assignSMS", &err)
+	return z.ackCommand("assignSMS", CMD_UDATA_WRQ, userSMSData(uid, smsID))
+}
+
+// UnassignSMS removes the personal message smsID from the user with the given UID.
+func (z *ZKTeco) UnassignSMS(uid int, smsID int) (err error) {
+	defer z.recoverInternal("unassignSMS", &err)
+	return z.ackCommand("unassignSMS", CMD_DELETE_UDATA, userSMSData(uid, smsID))
+}
+
+// userSMSData packs a user/message assignment: uid(2) + sms id(2).
+func userSMSData(uid int, smsID int) []byte {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint16(data[0:2], uint16(uid))
+	binary.LittleEndian.PutUint16(data[2:4], uint16(smsID))
+	return data
+}
@@ -0,0 +1,144 @@
+package zkteco
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DeviceSnapshot is the device identity, memory usage and clock as read by
+// GetDeviceSnapshot. Fields whose query failed are left zero, and the error
+// is recorded in Errors under the field's JSON name.
+type DeviceSnapshot struct {
+	DeviceInfo
+	Memory     *MemoryInfo `json:"memory,omitempty"`
+	DeviceTime time.Time   `json:"device_time"`
+	TakenAt    time.Time   `json:"taken_at"`
+
+	Errors map[string]error `json:"-"`
+}
+
+// Err returns the errors of the failed fields joined into one, or nil if
+// every query succeeded.
+func (s *DeviceSnapshot) Err() error {
+	fields := make([]string, 0, len(s.Errors))
+	for field := range s.Errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	errs := make([]error, len(fields))
+	for i, field := range fields {
+		errs[i] = fmt.Errorf("%s: %w", field, s.Errors[field])
+	}
+	return errors.Join(errs...)
+}
+
+// snapshotQuery is one query of GetDeviceSnapshot and how its response is
+// stored.
+type snapshotQuery struct {
+	field string
+	cmd   pipelinedCommand
+	apply func(s *DeviceSnapshot, resp []byte) error
+}
+
+// optionQuery reads the device option key into *dst.
+func optionQuery(field, key string, dst func(s *DeviceSnapshot) *string) snapshotQuery {
+	return snapshotQuery{
+		field: field,
+		cmd:   pipelinedCommand{CMD_DEVICE, []byte(key)},
+		apply: func(s *DeviceSnapshot, resp []byte) error {
+			v, err := parseDeviceOption(key, resp)
+			*dst(s) = v
+			return err
+		},
+	}
+}
+
+var snapshotQueries = []snapshotQuery{
+	optionQuery("serial_number", "~SerialNumber", func(s *DeviceSnapshot) *string { return &s.SerialNumber }),
+	optionQuery("device_name", "~DeviceName", func(s *DeviceSnapshot) *string { return &s.DeviceName }),
+	optionQuery("device_id", "DeviceID", func(s *DeviceSnapshot) *string { return &s.DeviceID }),
+	optionQuery("vendor_name", "~OEMVendor", func(s *DeviceSnapshot) *string { return &s.VendorName }),
+	optionQuery("platform", "~Platform", func(s *DeviceSnapshot) *string { return &s.Platform }),
+	optionQuery("os_version", "~OS", func(s *DeviceSnapshot) *string { return &s.OSVersion }),
+	optionQuery("fm_version", "~ZKFPVersion", func(s *DeviceSnapshot) *string { return &s.FMVersion }),
+	optionQuery("build_time", "~BuildTime", func(s *DeviceSnapshot) *string { return &s.BuildTime }),
+	optionQuery("device_type", "~DeviceType", func(s *DeviceSnapshot) *string { return &s.DeviceType }),
+	optionQuery("oem_code", "~OEMCode", func(s *DeviceSnapshot) *string { return &s.OEMCode }),
+	{
+		field: "firmware_version",
+		cmd:   pipelinedCommand{cmd: CMD_VERSION},
+		apply: func(s *DeviceSnapshot, resp []byte) (err error) {
+			s.FirmwareVersion, err = parseVersion(resp)
+			return err
+		},
+	},
+	{
+		field: "memory",
+		cmd:   pipelinedCommand{cmd: CMD_GET_FREE_SIZES},
+		apply: func(s *DeviceSnapshot, resp []byte) error {
+			sizes, err := parseFreeSizes(resp)
+			if err != nil {
+				return err
+			}
+			s.Memory, err = memoryInfoFromSizes(sizes)
+			return err
+		},
+	},
+	{
+		field: "device_time",
+		cmd:   pipelinedCommand{cmd: CMD_GET_TIME},
+		apply: func(s *DeviceSnapshot, resp []byte) (err error) {
+			s.DeviceTime, err = parseTime(resp)
+			return err
+		},
+	},
+}
+
+// GetDeviceSnapshot reads the device identity (as GetDeviceInfo), memory
+// usage and clock in one batch. The queries are sent back to back and their
+// responses collected afterwards, so the whole snapshot costs about one round
+// trip instead of one per field; queries the firmware drops are retried one
+// at a time. A failed query does not fail the snapshot: see
+// DeviceSnapshot.Errors. The returned error is only set when the client is
+// not connected.
+func (z *ZKTeco) GetDeviceSnapshot() (_ *DeviceSnapshot, err error) {
+	defer z.recoverInternal("getDeviceSnapshot", &err)
+	if !z.connected() {
+		return nil, fmt.Errorf("getDeviceSnapshot: not connected")
+	}
+
+	s := &DeviceSnapshot{
+		TakenAt: z.clock.Now(),
+		Errors:  make(map[string]error),
+	}
+
+	cmds := make([]pipelinedCommand, len(snapshotQueries))
+	for i, q := range snapshotQueries {
+		cmds[i] = q.cmd
+	}
+	resps := z.commandPipeline(cmds)
+
+	// After a failed retry the connection is unlikely to recover, so the
+	// remaining unanswered queries get the same error
+	var connErr error
+	for i, q := range snapshotQueries {
+		resp := resps[i]
+		if resp == nil {
+			if connErr == nil {
+				resp, connErr = z.command(q.cmd.cmd, q.cmd.data, "general")
+			}
+			if connErr != nil {
+				s.Errors[q.field] = connErr
+				continue
+			}
+		}
+		if err := q.apply(s, resp); err != nil {
+			s.Errors[q.field] = err
+		}
+	}
+
+	return s, nil
+}
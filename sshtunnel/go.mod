@@ -0,0 +1,12 @@
+module github.com/0mithun/go-zkteco/sshtunnel
+
+go 1.22
+
+require (
+	github.com/0mithun/go-zkteco v0.0.0-00010101000000-000000000000
+	golang.org/x/crypto v0.31.0
+)
+
+require golang.org/x/sys v0.28.0 // indirect
+
+replace github.com/0mithun/go-zkteco => ../
@@ -0,0 +1,91 @@
+// Package sshtunnel reaches ZKTeco devices through an SSH server, the usual
+// way into devices on isolated VLANs.
+//
+// It is a separate module so the zkteco package itself does not depend on
+// golang.org/x/crypto.
+package sshtunnel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	zkteco "github.com/0mithun/go-zkteco"
+	"golang.org/x/crypto/ssh"
+)
+
+// WithSSHTunnel makes the client reach the device through the SSH server at
+// sshAddr (host:port): each connection logs in with config and forwards a TCP
+// connection to the device address. Only TCP can be forwarded, so use it with
+// zkteco.WithProtocol("tcp").
+func WithSSHTunnel(sshAddr string, config *ssh.ClientConfig) zkteco.Option {
+	return zkteco.WithDialer(Dialer(sshAddr, config))
+}
+
+// Dialer returns a zkteco.DialFunc that forwards TCP connections through the
+// SSH server at sshAddr.
+func Dialer(sshAddr string, config *ssh.ClientConfig) zkteco.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if network != "tcp" {
+			return nil, fmt.Errorf("sshtunnel: cannot forward %s, use tcp", network)
+		}
+
+		var d net.Dialer
+		raw, err := d.DialContext(ctx, "tcp", sshAddr)
+		if err != nil {
+			return nil, fmt.Errorf("sshtunnel: dial %s: %w", sshAddr, err)
+		}
+
+		// Bound the handshake and the forward request by the dial context
+		if deadline, ok := ctx.Deadline(); ok {
+			raw.SetDeadline(deadline)
+		}
+
+		c, chans, reqs, err := ssh.NewClientConn(raw, sshAddr, config)
+		if err != nil {
+			raw.Close()
+			return nil, fmt.Errorf("sshtunnel: handshake with %s: %w", sshAddr, err)
+		}
+		client := ssh.NewClient(c, chans, reqs)
+
+		remote, err := client.Dial("tcp", addr)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("sshtunnel: forward to %s: %w", addr, err)
+		}
+		raw.SetDeadline(time.Time{})
+
+		return newTunnelConn(remote, client), nil
+	}
+}
+
+// tunnelConn relays a forwarded SSH channel through an in-memory pipe, which
+// gives it the read and write deadlines the client relies on and SSH
+// channels do not support.
+type tunnelConn struct {
+	net.Conn
+	remote net.Conn
+	client *ssh.Client
+}
+
+func newTunnelConn(remote net.Conn, client *ssh.Client) *tunnelConn {
+	local, inner := net.Pipe()
+	go func() {
+		io.Copy(inner, remote)
+		inner.Close()
+	}()
+	go func() {
+		io.Copy(remote, inner)
+		remote.Close()
+	}()
+	return &tunnelConn{Conn: local, remote: remote, client: client}
+}
+
+// Close closes the forwarded connection and the SSH session.
+func (c *tunnelConn) Close() error {
+	c.Conn.Close()
+	c.remote.Close()
+	return c.client.Close()
+}
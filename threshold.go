@@ -0,0 +1,56 @@
+package zkteco
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// MatchThresholds holds the biometric matching thresholds. Higher values
+// lower false accepts at the cost of more false rejects.
+type MatchThresholds struct {
+	Fingerprint1To1 int `json:"fingerprint_1_1"` // verify (ID + finger)
+	Fingerprint1ToN int `json:"fingerprint_1_n"` // identify (finger only)
+	Face            int `json:"face"`
+}
+
+// GetMatchThresholds returns the matching thresholds. Face is left at 0 on
+// devices without face recognition.
+func (z *ZKTeco) GetMatchThresholds() (_ *MatchThresholds, err error) {
+	defer z.recoverInternal("getMatchThresholds", &err)
+	t := &MatchThresholds{}
+
+	if t.Fingerprint1To1, err = z.getIntOption("VThreshold"); err != nil {
+		return nil, fmt.Errorf("getMatchThresholds: %w", err)
+	}
+	if t.Fingerprint1ToN, err = z.getIntOption("MThreshold"); err != nil {
+		return nil, fmt.Errorf("getMatchThresholds: %w", err)
+	}
+	t.Face, _ = z.getIntOption("FaceMThr")
+
+	return t, nil
+}
+
+// SetFingerprint1To1Threshold sets the fingerprint verification (1:1) threshold.
+func (z *ZKTeco) SetFingerprint1To1Threshold(value int) (err error) {
+	defer z.recoverInternal("setFingerprint1To1Threshold", &err)
+	return z.setThreshold("VThreshold", value)
+}
+
+// SetFingerprint1ToNThreshold sets the fingerprint identification (1:N) threshold.
+func (z *ZKTeco) SetFingerprint1ToNThreshold(value int) (err error) {
+	defer z.recoverInternal("setFingerprint1ToNThreshold", &err)
+	return z.setThreshold("MThreshold", value)
+}
+
+// SetFaceThreshold sets the face matching threshold.
+func (z *ZKTeco) SetFaceThreshold(value int) (err error) {
+	defer z.recoverInternal("setFaceThreshold", &err)
+	return z.setThreshold("FaceMThr", value)
+}
+
+func (z *ZKTeco) setThreshold(key string, value int) error {
+	if value < 1 || value > 100 {
+		return fmt.Errorf("set %s: threshold %d out of range 1-100", key, value)
+	}
+	return z.SetDeviceData(key, strconv.Itoa(value))
+}
@@ -2,17 +2,33 @@ package zkteco
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"time"
 )
 
+// The device stores the year as an offset from 2000 in two digits.
+const (
+	minDeviceYear = 2000
+	maxDeviceYear = 2099
+)
+
+// ErrTimeOutOfRange is returned for times the device cannot store, and for
+// device times outside that range.
+var ErrTimeOutOfRange = errors.New("time out of device range")
+
 // GetTime returns the device time.
-func (z *ZKTeco) GetTime() (time.Time, error) {
+func (z *ZKTeco) GetTime() (_ time.Time, err error) {
+	defer z.recoverInternal("getTime", &err)
 	resp, err := z.command(CMD_GET_TIME, nil, "general")
 	if err != nil {
 		return time.Time{}, err
 	}
+	return parseTime(resp)
+}
 
+// parseTime decodes a CMD_GET_TIME response.
+func parseTime(resp []byte) (time.Time, error) {
 	pkt, err := parsePacket(resp)
 	if err != nil {
 		return time.Time{}, err
@@ -23,11 +39,21 @@ func (z *ZKTeco) GetTime() (time.Time, error) {
 	}
 
 	encoded := binary.LittleEndian.Uint32(pkt.Data[0:4])
-	return decodeTime(encoded), nil
+	t := decodeTime(encoded)
+	if err := checkDeviceTime(t); err != nil {
+		return time.Time{}, fmt.Errorf("getTime: %w", err)
+	}
+	return t, nil
 }
 
-// SetTime sets the device time.
-func (z *ZKTeco) SetTime(t time.Time) error {
+// SetTime sets the device time. Times outside the years 2000-2099 are
+// refused with ErrTimeOutOfRange.
+func (z *ZKTeco) SetTime(t time.Time) (err error) {
+	defer z.recoverInternal("setTime", &err)
+	if err := checkDeviceTime(t); err != nil {
+		return fmt.Errorf("setTime: %w", err)
+	}
+
 	data := make([]byte, 4)
 	binary.LittleEndian.PutUint32(data, encodeTime(t))
 
@@ -40,7 +66,40 @@ func (z *ZKTeco) SetTime(t time.Time) error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("setTime: error response %d", pkt.Command)
+		return fmt.Errorf("setTime: error response %s", commandString(pkt.Command))
+	}
+	return nil
+}
+
+// SetTimeVerified sets the device time, reads it back and returns the time
+// the device applied. It fails if the read-back time differs from t by more
+// than tolerance, plus the time the round trip took.
+func (z *ZKTeco) SetTimeVerified(t time.Time, tolerance time.Duration) (_ time.Time, err error) {
+	defer z.recoverInternal("setTimeVerified", &err)
+	start := z.clock.Now()
+	if err := z.SetTime(t); err != nil {
+		return time.Time{}, err
+	}
+
+	applied, err := z.GetTime()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("setTime: read back: %w", err)
+	}
+
+	// The device keeps t's wall clock in whole seconds, and keeps running
+	elapsed := z.clock.Now().Sub(start)
+	drift := applied.Sub(decodeTime(encodeTime(t)))
+	if drift < -tolerance || drift > tolerance+elapsed {
+		return applied, fmt.Errorf("setTime: device reports %s after setting %s",
+			applied.Format(time.DateTime), t.Format(time.DateTime))
+	}
+	return applied, nil
+}
+
+// checkDeviceTime checks that t falls in the range the device can store.
+func checkDeviceTime(t time.Time) error {
+	if y := t.Year(); y < minDeviceYear || y > maxDeviceYear {
+		return fmt.Errorf("%w: year %d", ErrTimeOutOfRange, y)
 	}
 	return nil
 }
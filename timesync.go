@@ -0,0 +1,127 @@
+package zkteco
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ClockCheck is the result of one device clock check.
+type ClockCheck struct {
+	CheckedAt     time.Time     `json:"checked_at"`
+	DeviceTime    time.Time     `json:"device_time"`
+	ReferenceTime time.Time     `json:"reference_time"`
+	Drift         time.Duration `json:"drift"` // device time minus reference time
+	Corrected     bool          `json:"corrected"`
+}
+
+// TimeSyncConfig configures RunTimeSync.
+type TimeSyncConfig struct {
+	// Interval between checks. Default is 6 hours.
+	Interval time.Duration
+	// MaxDrift is the drift tolerated without correction. Default is 2 seconds.
+	MaxDrift time.Duration
+	// AlertThreshold is the drift from which OnAlert is called.
+	// Default is 1 minute.
+	AlertThreshold time.Duration
+	// OnCheck, if set, is called after every check.
+	OnCheck func(ClockCheck)
+	// OnAlert, if set, is called when the drift reaches AlertThreshold.
+	OnAlert func(ClockCheck)
+	// OnError, if set, is called when a check fails. The loop keeps running.
+	OnError func(error)
+	// Source is the reference clock. Default is the clock of the client
+	// (HostClock unless set with WithClock).
+	Source TimeSource
+}
+
+// CheckClock compares the device clock with the host clock (the clock of
+// the client, see WithClock) and sets the device time when the drift
+// exceeds maxDrift.
+func (z *ZKTeco) CheckClock(maxDrift time.Duration) (ClockCheck, error) {
+	return z.CheckClockWith(ClockSource(z.clock), maxDrift)
+}
+
+// CheckClockWith is like CheckClock but compares the device clock with src,
+// and sets it to the time of src.
+func (z *ZKTeco) CheckClockWith(src TimeSource, maxDrift time.Duration) (_ ClockCheck, err error) {
+	defer z.recoverInternal("checkClockWith", &err)
+	ref, err := src.Now()
+	if err != nil {
+		return ClockCheck{}, fmt.Errorf("checkClock: reference clock: %w", err)
+	}
+	offset := ref.Sub(z.clock.Now())
+
+	deviceTime, err := z.GetTime()
+	if err != nil {
+		return ClockCheck{}, fmt.Errorf("checkClock: %w", err)
+	}
+
+	now := z.clock.Now()
+	check := ClockCheck{
+		CheckedAt:     now,
+		DeviceTime:    deviceTime,
+		ReferenceTime: now.Add(offset),
+		Drift:         deviceTime.Sub(now.Add(offset)),
+	}
+
+	if absDuration(check.Drift) > maxDrift {
+		if err := z.SetTime(z.clock.Now().Add(offset)); err != nil {
+			return check, fmt.Errorf("checkClock: %w", err)
+		}
+		check.Corrected = true
+	}
+	return check, nil
+}
+
+// RunTimeSync checks and corrects the device clock every cfg.Interval until
+// ctx is canceled, starting with an immediate check. Other goroutines may
+// use the client meanwhile (see ZKTeco); their commands can run between the
+// read and the correction of a check.
+func (z *ZKTeco) RunTimeSync(ctx context.Context, cfg TimeSyncConfig) (err error) {
+	defer z.recoverInternal("runTimeSync", &err)
+	if cfg.Interval <= 0 {
+		cfg.Interval = 6 * time.Hour
+	}
+	if cfg.MaxDrift <= 0 {
+		cfg.MaxDrift = 2 * time.Second
+	}
+	if cfg.AlertThreshold <= 0 {
+		cfg.AlertThreshold = time.Minute
+	}
+	if cfg.Source == nil {
+		cfg.Source = ClockSource(z.clock)
+	}
+
+	ticker := z.clock.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		check, err := z.CheckClockWith(cfg.Source, cfg.MaxDrift)
+		if err != nil {
+			if cfg.OnError != nil {
+				cfg.OnError(err)
+			}
+		} else {
+			if cfg.OnCheck != nil {
+				cfg.OnCheck(check)
+			}
+			if cfg.OnAlert != nil && absDuration(check.Drift) >= cfg.AlertThreshold {
+				cfg.OnAlert(check)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
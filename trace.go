@@ -0,0 +1,94 @@
+package zkteco
+
+import (
+	"sync"
+	"time"
+)
+
+// CommandTrace records one command exchanged with the device.
+type CommandTrace struct {
+	Command  uint16
+	Sent     []byte // the request packet, without transport framing
+	Reply    []byte // the reply packet, nil if none arrived
+	Err      error  // the error of the exchange, if it failed
+	Time     time.Time
+	Duration time.Duration
+}
+
+// WithTrace keeps the last n commands exchanged with the device, with their
+// packets, for Trace to return after a failure. Default is 0 (no trace).
+func WithTrace(n int) Option {
+	return func(z *ZKTeco) {
+		z.inspect.traceSize = n
+	}
+}
+
+// LastPacket returns a copy of the last reply packet received for a command,
+// or nil if none was. It is safe to call while another goroutine uses the
+// client, e.g. from an error reporter.
+func (z *ZKTeco) LastPacket() []byte {
+	z.inspect.mu.Lock()
+	defer z.inspect.mu.Unlock()
+	return append([]byte(nil), z.inspect.lastPacket...)
+}
+
+// LastError returns the error of the last command exchange that failed at the
+// protocol level (send, receive or session check), or nil. It is cleared by a
+// successful exchange. Like LastPacket, it is safe for concurrent use.
+func (z *ZKTeco) LastError() (err error) {
+	defer z.recoverInternal("lastError", &err)
+	z.inspect.mu.Lock()
+	defer z.inspect.mu.Unlock()
+	return z.inspect.lastErr
+}
+
+// Trace returns the commands kept by WithTrace, oldest first. It is safe for
+// concurrent use.
+func (z *ZKTeco) Trace() []CommandTrace {
+	z.inspect.mu.Lock()
+	defer z.inspect.mu.Unlock()
+	n := len(z.inspect.trace)
+	out := make([]CommandTrace, 0, n)
+	out = append(out, z.inspect.trace[z.inspect.next:]...)
+	return append(out, z.inspect.trace[:z.inspect.next]...)
+}
+
+// inspector holds what LastPacket, LastError and Trace return.
+type inspector struct {
+	traceSize int
+
+	mu         sync.Mutex
+	lastPacket []byte
+	lastErr    error
+	trace      []CommandTrace // ring of up to traceSize entries
+	next       int            // index of the oldest entry once trace is full
+}
+
+// record notes the exchange of the request pkt for cmd, sent at start and
+// completed at end.
+func (in *inspector) record(cmd uint16, pkt, reply []byte, err error, start, end time.Time) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if reply != nil {
+		in.lastPacket = reply
+	}
+	in.lastErr = err
+	if in.traceSize <= 0 {
+		return
+	}
+
+	t := CommandTrace{
+		Command:  cmd,
+		Sent:     pkt,
+		Reply:    reply,
+		Err:      err,
+		Time:     start,
+		Duration: end.Sub(start),
+	}
+	if len(in.trace) < in.traceSize {
+		in.trace = append(in.trace, t)
+		return
+	}
+	in.trace[in.next] = t
+	in.next = (in.next + 1) % in.traceSize
+}
@@ -0,0 +1,443 @@
+package zkteco
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/0mithun/go-zkteco/protocol"
+)
+
+// Transport carries ZKTeco packets (8-byte header and data, without any
+// transport framing) between the client and a device. Implementations other
+// than the built-in TCP, UDP and TCPMUX ones are installed with WithTransport.
+type Transport interface {
+	// Dial opens the connection. timeout bounds the dial and is the
+	// inactivity timeout of later sends and receives.
+	Dial(timeout time.Duration) error
+	// Send writes one packet.
+	Send(packet []byte) error
+	// Recv returns the next packet. It fails when no data arrives for the
+	// Dial timeout or when the read deadline passes.
+	Recv() ([]byte, error)
+	// SetReadDeadline sets the absolute deadline for Recv; the zero time
+	// means none. It may be called while a Recv is pending, to interrupt it
+	// with a past time.
+	SetReadDeadline(t time.Time) error
+	// Close closes the connection.
+	Close() error
+}
+
+// WithTransport makes the client use t instead of dialing TCP, UDP or TCPMUX
+// itself. Connect calls t.Dial.
+func WithTransport(t Transport) Option {
+	return func(z *ZKTeco) {
+		z.customTransport = t
+	}
+}
+
+// ContextDialer is a Transport whose Dial can be canceled, as the built-in
+// ones. ConnectContext uses DialContext when the transport has it.
+type ContextDialer interface {
+	Transport
+	// DialContext is like Dial but gives up when ctx is done.
+	DialContext(ctx context.Context, timeout time.Duration) error
+}
+
+// AddressedTransport is a Transport for a multi-drop link, such as an RS485
+// bus or a serial-to-Ethernet converter in front of one, whose framing
+// carries the machine number of the addressed device. Connect passes it the
+// WithMachineNumber address before Dial.
+type AddressedTransport interface {
+	Transport
+	// SetMachineNumber sets the address packets are framed for; devices on
+	// the link answer only to their own.
+	SetMachineNumber(n int)
+}
+
+// WithMachineNumber sets the machine number (the device ID configured on the
+// terminal, 1-255) of the device to talk to on a multi-drop link, so that
+// daisy-chained controllers sharing one bus can be targeted individually. It
+// is handed to an AddressedTransport installed with WithTransport. TCP and
+// UDP connections reach a single device and do not carry it. Default is 1.
+func WithMachineNumber(n int) Option {
+	return func(z *ZKTeco) {
+		z.machineNumber = n
+	}
+}
+
+// MachineNumber returns the machine number set with WithMachineNumber.
+func (z *ZKTeco) MachineNumber() int {
+	return z.machineNumber
+}
+
+// DialFunc opens a network connection. It is used by the built-in transports
+// instead of net.Dial when set with WithDialer.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// WithDialer makes the built-in transports open their connections with dial,
+// e.g. to reach the device through a tunnel or a VPN library. The context
+// passed to dial expires after the client timeout.
+func WithDialer(dial DialFunc) Option {
+	return func(z *ZKTeco) {
+		z.dial = dial
+	}
+}
+
+// WithDialerWrapper makes the built-in transports open their connections
+// with wrap(next), next being the dialer set by the WithDialer or WithConn
+// options before it, or net.Dialer. It adds to a dialer configured
+// elsewhere, e.g. to throttle or log its connections, where a later
+// WithDialer would replace it.
+func WithDialerWrapper(wrap func(next DialFunc) DialFunc) Option {
+	return func(z *ZKTeco) {
+		next := z.dial
+		if next == nil {
+			next = (&net.Dialer{}).DialContext
+		}
+		z.dial = wrap(next)
+	}
+}
+
+// ErrConnUsed is returned when a client given a connection with WithConn
+// connects again after that connection was closed.
+var ErrConnUsed = errors.New("connection already used, give the client a new one")
+
+// WithConn makes the client talk over conn, a connection to the device the
+// caller already opened, e.g. one end of net.Pipe in tests or a stream of a
+// multiplexing tunnel. Packets are framed as over TCP, the protocol being
+// set to "tcp"; a later WithProtocol("udp") sends one packet per Write
+// instead. conn replaces the dialer of WithDialer and is used by the first
+// Connect only: Connect after Disconnect fails with ErrConnUsed.
+func WithConn(conn net.Conn) Option {
+	return func(z *ZKTeco) {
+		z.protocol = "tcp"
+		var mu sync.Mutex
+		z.dial = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if conn == nil {
+				return nil, ErrConnUsed
+			}
+			c := conn
+			conn = nil
+			return c, nil
+		}
+	}
+}
+
+// newTransport returns the transport selected by the client options.
+func (z *ZKTeco) newTransport() Transport {
+	if z.customTransport != nil {
+		if t, ok := z.customTransport.(AddressedTransport); ok {
+			t.SetMachineNumber(z.machineNumber)
+		}
+		return z.customTransport
+	}
+	addr := net.JoinHostPort(z.host, fmt.Sprint(z.port))
+	switch {
+	case z.tcpmuxEnabled:
+		t := newTCPTransport(net.JoinHostPort(z.tcpmuxHost, fmt.Sprint(z.tcpmuxPort)), z.readBufferSize, z.maxBufferSize)
+		t.dialer = z.dial
+		return &tcpmuxTransport{
+			tcpTransport: t,
+			target:       fmt.Sprintf("%s.%s:%d", z.tcpmuxSubdomain, z.host, z.port),
+			headers:      z.tcpmuxHeaders,
+		}
+	case z.IsTCP():
+		t := newTCPTransport(addr, z.readBufferSize, z.maxBufferSize)
+		t.dialer = z.dial
+		return t
+	default:
+		return &udpTransport{deadlineConn: deadlineConn{dialer: z.dial}, addr: addr}
+	}
+}
+
+// deadlineConn holds a net.Conn with an inactivity timeout and an optional
+// absolute read deadline, shared by the built-in transports.
+type deadlineConn struct {
+	conn    net.Conn
+	timeout time.Duration
+	dialer  DialFunc // nil means net.Dialer
+
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (c *deadlineConn) dial(ctx context.Context, network, addr string, timeout time.Duration) error {
+	dial := c.dialer
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	conn, err := dial(ctx, network, addr)
+	if err != nil {
+		return fmt.Errorf("dial %s %s: %w", network, addr, err)
+	}
+	c.conn = conn
+	c.timeout = timeout
+	c.deadline = time.Time{}
+	return nil
+}
+
+func (c *deadlineConn) write(b []byte) error {
+	if c.conn == nil {
+		return fmt.Errorf("not connected")
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(c.timeout))
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// read reads once, waiting up to the inactivity timeout, capped by the read
+// deadline and by limit unless it is zero.
+func (c *deadlineConn) read(b []byte, limit time.Time) (int, error) {
+	if c.conn == nil {
+		return 0, fmt.Errorf("not connected")
+	}
+	deadline := time.Now().Add(c.timeout)
+	c.mu.Lock()
+	if !c.deadline.IsZero() && c.deadline.Before(deadline) {
+		deadline = c.deadline
+	}
+	if !limit.IsZero() && limit.Before(deadline) {
+		deadline = limit
+	}
+	c.conn.SetReadDeadline(deadline)
+	c.mu.Unlock()
+	return c.conn.Read(b)
+}
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	if c.conn == nil {
+		return nil
+	}
+	// Apply immediately so a pending read sees it
+	if t.IsZero() {
+		return c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+	}
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *deadlineConn) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// udpTransport sends one packet per datagram.
+type udpTransport struct {
+	deadlineConn
+	addr string
+	buf  []byte // datagram read buffer, reused by Recv
+}
+
+func (t *udpTransport) Dial(timeout time.Duration) error {
+	return t.DialContext(context.Background(), timeout)
+}
+
+func (t *udpTransport) DialContext(ctx context.Context, timeout time.Duration) error {
+	return t.dial(ctx, "udp", t.addr, timeout)
+}
+
+func (t *udpTransport) Send(packet []byte) error {
+	return t.write(packet)
+}
+
+func (t *udpTransport) Recv() ([]byte, error) {
+	if t.buf == nil {
+		t.buf = make([]byte, 65536)
+	}
+	n, err := t.read(t.buf, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	// Packets are kept, e.g. parked events, so they must not pin or share
+	// the buffer
+	return bytes.Clone(t.buf[:n]), nil
+}
+
+// ErrBufferOverflow is returned when the TCP reassembly buffer would grow
+// beyond the limit set with WithMaxBufferSize. The buffer is discarded.
+var ErrBufferOverflow = errors.New("tcp reassembly buffer overflow")
+
+// minTransferRate is the slowest link speed, in bytes per second, a packet
+// is allowed to arrive at before the TCP transport gives up on it.
+const minTransferRate = 1024
+
+// tcpTransport frames packets with the TCP header and reassembles them from
+// the stream.
+type tcpTransport struct {
+	deadlineConn
+	addr string
+
+	readBufferSize int
+	maxBufferSize  int
+	readBuf        []byte
+	buf            []byte // received bytes not yet framed into a packet
+}
+
+func newTCPTransport(addr string, readBufferSize, maxBufferSize int) *tcpTransport {
+	return &tcpTransport{addr: addr, readBufferSize: readBufferSize, maxBufferSize: maxBufferSize}
+}
+
+func (t *tcpTransport) Dial(timeout time.Duration) error {
+	return t.DialContext(context.Background(), timeout)
+}
+
+func (t *tcpTransport) DialContext(ctx context.Context, timeout time.Duration) error {
+	t.buf = nil
+	return t.dial(ctx, "tcp", t.addr, timeout)
+}
+
+func (t *tcpTransport) Send(packet []byte) error {
+	return t.write(wrapTCP(packet))
+}
+
+// Recv reads the next complete TCP-framed payload. Each read waits up to the
+// inactivity timeout for more bytes, and once the framing header announces
+// the payload length the whole packet must arrive within the timeout plus the
+// time minTransferRate needs for that length. Errors report how much of the
+// packet had arrived.
+func (t *tcpTransport) Recv() ([]byte, error) {
+	var packetDeadline time.Time
+
+	for {
+		if payload, remainder, ok := extractTCPPacket(t.buf); ok {
+			t.buf = remainder
+			return payload, nil
+		}
+
+		expected := -1
+		if len(t.buf) >= 8 {
+			if !bytes.Equal(t.buf[:4], protocol.TCPMagic) {
+				t.buf = nil
+				return nil, fmt.Errorf("read packet: invalid TCP framing")
+			}
+			expected = int(binary.LittleEndian.Uint32(t.buf[4:8]))
+			if packetDeadline.IsZero() {
+				packetDeadline = time.Now().Add(t.timeout +
+					time.Duration(expected)*time.Second/minTransferRate)
+			}
+		}
+
+		if err := t.fill(packetDeadline); err != nil {
+			if expected < 0 {
+				return nil, err
+			}
+			got := len(t.buf) - 8
+			if got < 0 {
+				got = 0
+			}
+			return nil, fmt.Errorf("read packet: %d of %d bytes received: %w", got, expected, err)
+		}
+	}
+}
+
+// fill reads once from the connection into the reassembly buffer, enforcing
+// the WithMaxBufferSize cap.
+func (t *tcpTransport) fill(limit time.Time) error {
+	if len(t.readBuf) != t.readBufferSize {
+		t.readBuf = make([]byte, t.readBufferSize)
+	}
+	n, err := t.read(t.readBuf, limit)
+	if err != nil {
+		return err
+	}
+	if t.maxBufferSize > 0 && len(t.buf)+n > t.maxBufferSize {
+		size := len(t.buf) + n
+		t.buf = nil
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", ErrBufferOverflow, size, t.maxBufferSize)
+	}
+	t.buf = append(t.buf, t.readBuf[:n]...)
+	return nil
+}
+
+// tcpmuxTransport reaches the device through a TCPMUX proxy: it dials the
+// proxy and performs an HTTP CONNECT handshake before the ZKTeco protocol.
+type tcpmuxTransport struct {
+	*tcpTransport
+	target  string
+	headers http.Header // extra CONNECT headers; see WithTCPMUXHeaders
+}
+
+func (t *tcpmuxTransport) Dial(timeout time.Duration) error {
+	return t.DialContext(context.Background(), timeout)
+}
+
+func (t *tcpmuxTransport) DialContext(ctx context.Context, timeout time.Duration) error {
+	if err := t.tcpTransport.DialContext(ctx, timeout); err != nil {
+		return fmt.Errorf("tcpmux proxy: %w", err)
+	}
+	if err := t.handshake(); err != nil {
+		t.Close()
+		return fmt.Errorf("tcpmux handshake: %w", err)
+	}
+	return nil
+}
+
+// handshake performs HTTP CONNECT through the TCPMUX proxy.
+func (t *tcpmuxTransport) handshake() error {
+	var request bytes.Buffer
+	fmt.Fprintf(&request, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", t.target, t.target)
+	headers := http.Header{"Proxy-Connection": {"Keep-Alive"}}
+	for key, values := range t.headers {
+		headers[http.CanonicalHeaderKey(key)] = values
+	}
+	headers.Del("Host")
+	if err := headers.Write(&request); err != nil {
+		return fmt.Errorf("build CONNECT request: %w", err)
+	}
+	request.WriteString("\r\n")
+
+	if err := t.write(request.Bytes()); err != nil {
+		return fmt.Errorf("send CONNECT request: %w", err)
+	}
+
+	t.conn.SetReadDeadline(time.Now().Add(t.timeout))
+	reader := bufio.NewReader(t.conn)
+	statusLine, err := reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("read proxy response: %w", err)
+	}
+
+	// Read remaining headers until blank line
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("read proxy headers: %w", err)
+		}
+		if strings.TrimSpace(line) == "" {
+			break
+		}
+	}
+
+	// Check for HTTP 200
+	statusLine = strings.TrimSpace(statusLine)
+	if !strings.Contains(statusLine, " 200 ") {
+		return fmt.Errorf("proxy returned: %s", statusLine)
+	}
+
+	// Keep any protocol bytes the reader buffered past the headers
+	if n := reader.Buffered(); n > 0 {
+		rest, _ := reader.Peek(n)
+		t.buf = append(t.buf, rest...)
+	}
+	return nil
+}
@@ -0,0 +1,408 @@
+package zkteco
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/0mithun/go-zkteco/protocol"
+)
+
+// memTransport is an in-memory Transport playing the device: each packet
+// the client sends is handed to answer, and the packets it returns are
+// queued for Recv.
+type memTransport struct {
+	answer func(pkt *protocol.Packet) [][]byte
+
+	mu       sync.Mutex
+	timeout  time.Duration
+	deadline time.Time
+	queue    [][]byte
+	sent     []uint16      // commands received, in order
+	wake     chan struct{} // signaled when queue or deadline changes
+}
+
+func newMemTransport(answer func(pkt *protocol.Packet) [][]byte) *memTransport {
+	return &memTransport{answer: answer, wake: make(chan struct{}, 1)}
+}
+
+func (t *memTransport) Dial(timeout time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timeout = timeout
+	t.queue = nil
+	return nil
+}
+
+func (t *memTransport) Send(packet []byte) error {
+	pkt, err := protocol.ParsePacket(packet)
+	if err != nil {
+		return err
+	}
+	replies := t.answer(pkt)
+	t.mu.Lock()
+	t.sent = append(t.sent, pkt.Command)
+	t.queue = append(t.queue, replies...)
+	t.mu.Unlock()
+	t.signal()
+	return nil
+}
+
+func (t *memTransport) Recv() ([]byte, error) {
+	for {
+		t.mu.Lock()
+		if len(t.queue) > 0 {
+			pkt := t.queue[0]
+			t.queue = t.queue[1:]
+			t.mu.Unlock()
+			return pkt, nil
+		}
+		deadline := t.deadline
+		if deadline.IsZero() {
+			deadline = time.Now().Add(t.timeout)
+		}
+		t.mu.Unlock()
+
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, os.ErrDeadlineExceeded
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-t.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (t *memTransport) SetReadDeadline(d time.Time) error {
+	t.mu.Lock()
+	t.deadline = d
+	t.mu.Unlock()
+	t.signal()
+	return nil
+}
+
+func (t *memTransport) Close() error { return nil }
+
+func (t *memTransport) signal() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// commands returns the commands the client sent so far.
+func (t *memTransport) commands() []uint16 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.sent)
+}
+
+// count returns how many times the client sent cmd.
+func (t *memTransport) count(cmd uint16) int {
+	n := 0
+	for _, c := range t.commands() {
+		if c == cmd {
+			n++
+		}
+	}
+	return n
+}
+
+const testSessionID = 0x4d2
+
+// devicePacket builds a device packet answering the request with reply ID
+// replyID.
+func devicePacket(cmd, replyID uint16, data []byte) []byte {
+	buf := make([]byte, 8+len(data))
+	binary.LittleEndian.PutUint16(buf[0:2], cmd)
+	binary.LittleEndian.PutUint16(buf[4:6], testSessionID)
+	binary.LittleEndian.PutUint16(buf[6:8], replyID)
+	copy(buf[8:], data)
+	binary.LittleEndian.PutUint16(buf[2:4], protocol.Checksum(buf))
+	return buf
+}
+
+// testUserRecord builds a 72-byte user record.
+func testUserRecord(uid int, userID, name string) []byte {
+	rec := make([]byte, 72)
+	binary.LittleEndian.PutUint16(rec[1:3], uint16(uid))
+	copy(rec[12:36], name)
+	copy(rec[49:72], userID)
+	return rec
+}
+
+// testLegacyUserRecord builds a 28-byte user record of a firmware before
+// 6.60, with a numeric user ID.
+func testLegacyUserRecord(uid int, userID uint32, name string) []byte {
+	rec := make([]byte, 28)
+	binary.LittleEndian.PutUint16(rec[0:2], uint16(uid))
+	copy(rec[8:16], name)
+	binary.LittleEndian.PutUint32(rec[24:28], userID)
+	return rec
+}
+
+// testAttendanceRecord builds a 40-byte attendance record.
+func testAttendanceRecord(uid int, userID string, t time.Time) []byte {
+	rec := make([]byte, 40)
+	binary.LittleEndian.PutUint16(rec[0:2], uint16(uid))
+	copy(rec[2:26], userID)
+	binary.LittleEndian.PutUint32(rec[27:31], encodeTime(t))
+	return rec
+}
+
+// testAttendanceRecord44 builds a 44-byte attendance record with a work code.
+func testAttendanceRecord44(uid int, userID string, t time.Time, workCode int) []byte {
+	rec := append(testAttendanceRecord(uid, userID, t), 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(rec[40:44], uint32(workCode))
+	return rec
+}
+
+// testAttendanceRecord16 builds a 16-byte attendance record of a firmware
+// before 6.60.
+func testAttendanceRecord16(userID uint32, t time.Time) []byte {
+	rec := make([]byte, 16)
+	binary.LittleEndian.PutUint32(rec[0:4], userID)
+	binary.LittleEndian.PutUint32(rec[4:8], encodeTime(t))
+	return rec
+}
+
+// sizePrefixed returns records after their 4-byte total size, as tables other
+// than the 72-byte user table are sent.
+func sizePrefixed(records ...[]byte) []byte {
+	table := binary.LittleEndian.AppendUint32(nil, 0)
+	for _, rec := range records {
+		table = append(table, rec...)
+	}
+	binary.LittleEndian.PutUint32(table[0:4], uint32(len(table)-4))
+	return table
+}
+
+// testDevice plays a device over a memTransport, with the firmware
+// behaviour selected by its fields: see stockDevice and the other profiles.
+// Commands it does not know are acknowledged.
+type testDevice struct {
+	version     string            // answer to CMD_VERSION
+	password    int               // required through CMD_ACK_AUTH, 0 for none
+	silentAuth  bool              // accept CMD_CONNECT without asking for the password
+	options     map[string]string // answers to CMD_DEVICE
+	users       []byte            // user table, as sent after the packet header
+	attendances []byte            // attendance table, likewise
+	freeSizes   []uint32          // answer to CMD_GET_FREE_SIZES
+
+	mu     sync.Mutex
+	authed bool
+	upload []byte            // data since the last CMD_PREPARE_DATA
+	files  map[string][]byte // stored with CMD_UPDATEFILE
+	set    [][]byte          // records written with CMD_SET_USER
+}
+
+var (
+	testPunch  = time.Date(2024, 3, 1, 8, 30, 0, 0, time.Local)
+	testPunch2 = time.Date(2024, 3, 1, 17, 5, 0, 0, time.Local)
+)
+
+// stockDevice is a device with firmware 6.60: 72-byte user records and
+// 40-byte attendance records.
+func stockDevice() *testDevice {
+	return &testDevice{
+		version:     "Ver 6.60 Apr 13 2022",
+		users:       append(testUserRecord(1, "100", "Alice"), testUserRecord(2, "200", "Bob")...),
+		attendances: sizePrefixed(testAttendanceRecord(1, "100", testPunch), testAttendanceRecord(2, "200", testPunch2)),
+	}
+}
+
+// legacyDevice is a device with a firmware before 6.60: 28-byte user records
+// with numeric user IDs and 16-byte attendance records.
+func legacyDevice() *testDevice {
+	return &testDevice{
+		version:     "Ver 6.21 Sep 2 2010",
+		users:       sizePrefixed(testLegacyUserRecord(1, 100, "Alice"), testLegacyUserRecord(2, 200, "Bob")),
+		attendances: sizePrefixed(testAttendanceRecord16(100, testPunch), testAttendanceRecord16(200, testPunch2)),
+	}
+}
+
+// faceDevice is a stock face terminal, which stores user photos.
+func faceDevice() *testDevice {
+	d := stockDevice()
+	d.options = map[string]string{"FaceFunOn": "1"}
+	return d
+}
+
+// protectedDevice is a stock device requiring a communication password.
+func protectedDevice(password int) *testDevice {
+	d := stockDevice()
+	d.password = password
+	return d
+}
+
+// transport returns a memTransport playing d.
+func (d *testDevice) transport() *memTransport {
+	return newMemTransport(d.answer)
+}
+
+func (d *testDevice) answer(pkt *protocol.Packet) [][]byte {
+	reply := func(cmd uint16, data []byte) [][]byte {
+		return [][]byte{devicePacket(cmd, pkt.ReplyID, data)}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch pkt.Command {
+	case CMD_CONNECT:
+		d.authed = d.password == 0
+		if !d.authed && !d.silentAuth {
+			return reply(CMD_ACK_UNAUTH, nil)
+		}
+		return reply(CMD_ACK_OK, nil)
+	case CMD_ACK_AUTH:
+		if !bytes.Equal(pkt.Data, protocol.MakeCommKey(d.password, testSessionID)) {
+			return reply(CMD_ACK_UNAUTH, nil)
+		}
+		d.authed = true
+		return reply(CMD_ACK_OK, nil)
+	}
+	if !d.authed {
+		return reply(CMD_ACK_UNAUTH, nil)
+	}
+
+	switch pkt.Command {
+	case CMD_VERSION:
+		return reply(CMD_ACK_OK, append([]byte(d.version), 0))
+	case CMD_DEVICE:
+		key := strings.TrimRight(string(pkt.Data), "\x00")
+		value, ok := d.options[key]
+		if !ok {
+			return reply(CMD_ACK_ERROR, nil)
+		}
+		return reply(CMD_ACK_OK, []byte(key+"="+value+"\x00"))
+	case CMD_GET_FREE_SIZES:
+		var data []byte
+		for _, v := range d.freeSizes {
+			data = binary.LittleEndian.AppendUint32(data, v)
+		}
+		return reply(CMD_ACK_OK, data)
+	case CMD_USER_TEMP_RRQ:
+		return tableReplies(pkt.ReplyID, d.users)
+	case CMD_ATT_LOG_RRQ:
+		return tableReplies(pkt.ReplyID, d.attendances)
+	case CMD_SET_USER:
+		d.set = append(d.set, bytes.Clone(pkt.Data))
+	case CMD_PREPARE_DATA:
+		d.upload = nil
+	case CMD_DATA:
+		d.upload = append(d.upload, pkt.Data...)
+	case CMD_UPDATEFILE:
+		if d.files == nil {
+			d.files = make(map[string][]byte)
+		}
+		d.files[strings.TrimRight(string(pkt.Data), "\x00")] = d.upload
+	}
+	return reply(CMD_ACK_OK, nil)
+}
+
+// tableReplies sends table as a large data transfer in 100-byte chunks, so
+// records straddle them.
+func tableReplies(replyID uint16, table []byte) [][]byte {
+	size := binary.LittleEndian.AppendUint32(nil, uint32(len(table)))
+	replies := [][]byte{devicePacket(CMD_PREPARE_DATA, replyID, size)}
+	for start := 0; start < len(table); start += 100 {
+		chunk := table[start:min(start+100, len(table))]
+		replies = append(replies, devicePacket(CMD_DATA, replyID, chunk))
+	}
+	return append(replies, devicePacket(CMD_ACK_OK, replyID, nil))
+}
+
+func TestMemTransportSession(t *testing.T) {
+	deviceTime := time.Date(2024, 3, 1, 8, 30, 0, 0, time.Local)
+	table := append(testUserRecord(1, "100", "Alice"), testUserRecord(2, "200", "Bob")...)
+
+	dev := newMemTransport(func(pkt *protocol.Packet) [][]byte {
+		switch pkt.Command {
+		case CMD_CONNECT, CMD_EXIT:
+			return [][]byte{devicePacket(CMD_ACK_OK, pkt.ReplyID, nil)}
+		case CMD_GET_TIME:
+			data := binary.LittleEndian.AppendUint32(nil, encodeTime(deviceTime))
+			return [][]byte{devicePacket(CMD_ACK_OK, pkt.ReplyID, data)}
+		case CMD_USER_TEMP_RRQ:
+			// The table arrives in two chunks after CMD_PREPARE_DATA
+			size := binary.LittleEndian.AppendUint32(nil, uint32(len(table)))
+			return [][]byte{
+				devicePacket(CMD_PREPARE_DATA, pkt.ReplyID, size),
+				devicePacket(CMD_DATA, pkt.ReplyID, table[:100]),
+				devicePacket(CMD_DATA, pkt.ReplyID, table[100:]),
+				devicePacket(CMD_ACK_OK, pkt.ReplyID, nil),
+			}
+		}
+		return [][]byte{devicePacket(CMD_ACK_ERROR, pkt.ReplyID, nil)}
+	})
+
+	zk := NewZKTeco("device", 4370, WithTransport(dev))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	got, err := zk.GetTime()
+	if err != nil {
+		t.Fatalf("GetTime: %v", err)
+	}
+	if !got.Equal(deviceTime) {
+		t.Errorf("GetTime = %s, want %s", got, deviceTime)
+	}
+
+	users, err := zk.GetUsers()
+	if err != nil {
+		t.Fatalf("GetUsers: %v", err)
+	}
+	if len(users) != 2 || users[0].UserID != "100" || users[1].Name != "Bob" {
+		t.Errorf("GetUsers = %+v, want Alice (100) and Bob (200)", users)
+	}
+
+	// The session stays usable after the transfer
+	if _, err := zk.GetTime(); err != nil {
+		t.Fatalf("GetTime after transfer: %v", err)
+	}
+
+	if err := zk.Disconnect(); err != nil {
+		t.Fatalf("Disconnect: %v", err)
+	}
+	want := []uint16{CMD_CONNECT, CMD_GET_TIME, CMD_USER_TEMP_RRQ, CMD_GET_TIME, CMD_EXIT}
+	if got := dev.commands(); !slices.Equal(got, want) {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+}
+
+func TestDoContextStaysWithCall(t *testing.T) {
+	dev := newMemTransport(answerTime(CMD_ACK_OK))
+	zk := NewZKTeco("device", 4370, WithTransport(dev))
+	if err := zk.Connect(); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer zk.Disconnect()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := zk.Do(ctx, func(bound *ZKTeco) error {
+		cancel()
+		// Calls on zk, as another goroutine would make, ignore ctx
+		if _, err := zk.GetTime(); err != nil {
+			t.Errorf("GetTime during a canceled Do: %v", err)
+		}
+		_, err := bound.GetTime()
+		return err
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Do = %v, want context.Canceled", err)
+	}
+	if _, err := zk.GetTime(); err != nil {
+		t.Errorf("GetTime after Do: %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package zkteco
+
+import (
+	"encoding/binary"
+)
+
+// maxParkedEvents bounds the event packets kept for the listener while
+// replies are awaited; older ones are dropped first.
+const maxParkedEvents = 256
+
+// isUDP reports whether the client talks to the device over the built-in UDP
+// transport.
+func (z *ZKTeco) isUDP() bool {
+	_, ok := z.transport.(*udpTransport)
+	return ok
+}
+
+// strayPacket reports whether resp, received while waiting for a packet of
+// the exchange replyID, belongs to something else and must be skipped.
+// Real-time events can arrive in the middle of a reply or a data transfer
+// over any transport, as some firmwares push them even to sessions that
+// never registered: they are diverted (see divertEvent). Datagrams are
+// moreover neither ordered nor tied to a request, so over UDP a late reply
+// to an earlier request can show up too: packets with another reply ID are
+// discarded. Replies are not matched during the handshake, and with
+// LenientSession profiles, whose firmwares do not echo the reply ID.
+func (z *ZKTeco) strayPacket(replyID uint16, resp []byte) bool {
+	udp := z.isUDP()
+	if len(resp) < 8 {
+		if udp {
+			z.warn("discarded short datagram", "bytes", len(resp))
+		}
+		return udp
+	}
+	if binary.LittleEndian.Uint16(resp[0:2]) == CMD_REG_EVENT {
+		z.divertEvent(resp)
+		return true
+	}
+	if !udp || z.sessionID == 0 || z.profileLocked().LenientSession {
+		return false
+	}
+	if got := binary.LittleEndian.Uint16(resp[6:8]); got != replyID {
+		z.warn("discarded stray reply", "command", commandString(binary.LittleEndian.Uint16(resp[0:2])),
+			"expected", replyID, "got", got)
+		return true
+	}
+	return false
+}
+
+// parkEvent keeps an event packet that arrived during a command for the
+// next listener read.
+func (z *ZKTeco) parkEvent(pkt []byte) {
+	if len(z.parked) >= maxParkedEvents {
+		z.warn("dropped parked event", "parked", len(z.parked))
+		z.parked = z.parked[1:]
+	}
+	z.parked = append(z.parked, pkt)
+}
+
+// nextParkedEvent returns the oldest parked event packet, or nil.
+func (z *ZKTeco) nextParkedEvent() []byte {
+	if len(z.parked) == 0 {
+		return nil
+	}
+	pkt := z.parked[0]
+	z.parked = z.parked[1:]
+	return pkt
+}
+
+// isDataChunk reports whether pkt is a CMD_DATA packet of a transfer.
+func isDataChunk(pkt []byte) bool {
+	return len(pkt) >= 8 && binary.LittleEndian.Uint16(pkt[0:2]) == CMD_DATA
+}
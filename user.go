@@ -1,8 +1,13 @@
 package zkteco
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -14,40 +19,157 @@ type User struct {
 	Password string `json:"password"`
 	Role     int    `json:"role"`
 	CardNo   int    `json:"card_no"`
+
+	DeviceSerial string `json:"device_serial,omitempty"` // see WithRecordSerial
+	Raw          []byte `json:"raw,omitempty"`           // record bytes; see WithRawRecords
 }
 
 // GetUsers retrieves all users from the device.
 func (z *ZKTeco) GetUsers() ([]User, error) {
-	cmdData := []byte{FCT_USER}
-	allData, err := z.commandData(CMD_USER_TEMP_RRQ, cmdData)
+	return z.GetUsersContext(context.Background())
+}
+
+// GetUsersContext is like GetUsers but aborts the transfer when ctx is
+// canceled, leaving the connection usable.
+func (z *ZKTeco) GetUsersContext(ctx context.Context) (_ []User, err error) {
+	defer z.recoverInternal("getUsersContext", &err)
+	users, _, err := z.GetUsersWithReport(ctx)
+	return users, err
+}
+
+// GetUsersWithReport is like GetUsersContext but also returns a ParseReport
+// describing the records that could not be parsed and were skipped, so
+// data-quality issues are visible.
+func (z *ZKTeco) GetUsersWithReport(ctx context.Context) (_ []User, _ *ParseReport, err error) {
+	defer z.recoverInternal("getUsersWithReport", &err)
+	var allData []byte
+	err = z.whileDisabled(func() (err error) {
+		allData, err = z.commandData(ctx, CMD_USER_TEMP_RRQ, []byte{FCT_USER})
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("getUsers: %w", err)
+		return nil, nil, fmt.Errorf("getUsers: %w", err)
 	}
 
-	if len(allData) <= 8 {
-		return nil, nil
+	users, report := parseUsers(allData, z.Profile(), z.rawRecords)
+	for i := range users {
+		users[i].DeviceSerial = z.serial
 	}
+	return users, report, nil
+}
 
-	data := allData[8:]
+// ErrUserNotFound is returned when the device has no such user.
+var ErrUserNotFound = errors.New("user not found")
+
+// VerifyUserPassword reports whether password is the device password (PIN)
+// of the user with the given UID, e.g. for kiosk apps. The protocol has no
+// command to check a password on the device, so the user table is read and
+// compared on the host, in constant time; the stored passwords are not
+// exposed to the caller. Users without a password never match.
+func (z *ZKTeco) VerifyUserPassword(uid int, password string) (_ bool, err error) {
+	defer z.recoverInternal("verifyUserPassword", &err)
+	return z.verifyUserPassword(func(u User) bool { return u.UID == uid }, password)
+}
+
+// VerifyUserIDPassword is like VerifyUserPassword but identifies the user by
+// user ID (the enrollment number shown on the terminal).
+func (z *ZKTeco) VerifyUserIDPassword(userID string, password string) (_ bool, err error) {
+	defer z.recoverInternal("verifyUserIDPassword", &err)
+	return z.verifyUserPassword(func(u User) bool { return u.UserID == userID }, password)
+}
+
+func (z *ZKTeco) verifyUserPassword(match func(User) bool, password string) (bool, error) {
+	users, err := z.GetUsers()
+	if err != nil {
+		return false, fmt.Errorf("verifyUserPassword: %w", err)
+	}
+	for _, u := range users {
+		if match(u) {
+			ok := subtle.ConstantTimeCompare([]byte(u.Password), []byte(password)) == 1
+			return ok && u.Password != "", nil
+		}
+	}
+	return false, fmt.Errorf("verifyUserPassword: %w", ErrUserNotFound)
+}
+
+// ParseUsers parses a raw user table as returned by the device (including the
+// 8-byte packet header) using the record layout of profile p. Records that
+// cannot be parsed are skipped and described in the report.
+func ParseUsers(allData []byte, p Profile) ([]User, *ParseReport) {
+	return parseUsers(allData, p, false)
+}
+
+// parseUsers implements ParseUsers, keeping a copy of each record in
+// User.Raw if raw is set.
+func parseUsers(allData []byte, p Profile, raw bool) ([]User, *ParseReport) {
+	report := &ParseReport{}
+
+	// 72-byte records start after the 8-byte header; legacy 28-byte
+	// records after the header and a 4-byte size prefix
+	recordSize, skip, parse := 72, 8, parseUserRecord
+	if p.UserRecordSize == 28 {
+		recordSize, skip, parse = 28, 12, parseLegacyUserRecord
+	}
+	if len(allData) <= skip {
+		return nil, report
+	}
+	data := allData[skip:]
 
-	recordSize := 72
 	var users []User
 
 	for i := 0; i+recordSize <= len(data); i += recordSize {
 		rec := data[i : i+recordSize]
-		user := parseUserRecord(rec)
-		if user != nil {
-			users = append(users, *user)
+		user, err := parse(rec)
+		if errors.Is(err, errEmptyRecord) {
+			continue
+		}
+		if err != nil {
+			report.add(skip+i, rec, err)
+			continue
 		}
+		if raw {
+			user.Raw = bytes.Clone(rec)
+		}
+		users = append(users, *user)
+		report.Parsed++
 	}
 
-	return users, nil
+	return users, report
+}
+
+// parseLegacyUserRecord parses a 28-byte user record.
+// Legacy firmwares store the user ID as a number rather than a string.
+func parseLegacyUserRecord(rec []byte) (*User, error) {
+	if len(rec) < 28 {
+		return nil, errShortRecord(len(rec), 28)
+	}
+
+	uid := int(binary.LittleEndian.Uint16(rec[0:2]))
+	role := int(rec[2])
+	password := strings.TrimRight(string(rec[3:8]), "\x00")
+	name := strings.TrimRight(string(rec[8:16]), "\x00")
+	cardNo := int(binary.LittleEndian.Uint32(rec[16:20]))
+	userID := strconv.FormatUint(uint64(binary.LittleEndian.Uint32(rec[24:28])), 10)
+
+	return &User{
+		UID:      uid,
+		UserID:   userID,
+		Name:     name,
+		Password: password,
+		Role:     role,
+		CardNo:   cardNo,
+	}, nil
+}
+
+// ParseUserRecord parses a 72-byte user record as found in the user table.
+func ParseUserRecord(rec []byte) (*User, error) {
+	return parseUserRecord(rec)
 }
 
 // parseUserRecord parses a 72-byte user record.
-func parseUserRecord(rec []byte) *User {
+func parseUserRecord(rec []byte) (*User, error) {
 	if len(rec) < 72 {
-		return nil
+		return nil, errShortRecord(len(rec), 72)
 	}
 
 	uid := int(binary.LittleEndian.Uint16(rec[1:3]))
@@ -64,11 +186,22 @@ func parseUserRecord(rec []byte) *User {
 		Password: password,
 		Role:     role,
 		CardNo:   cardNo,
-	}
+	}, nil
 }
 
 // SetUser creates or updates a user on the device.
-func (z *ZKTeco) SetUser(uid int, userID string, name string, password string, role int, cardNo int) error {
+func (z *ZKTeco) SetUser(uid int, userID string, name string, password string, role int, cardNo int) (err error) {
+	defer z.recoverInternal("setUser", &err)
+	return z.setUser(uid, userID, name, password, role, cardNo, 1)
+}
+
+// setUser writes a user record with the access group group. Users are
+// written in group 1, the default group; group 0 grants no access.
+func (z *ZKTeco) setUser(uid int, userID string, name string, password string, role int, cardNo int, group byte) error {
+	if z.Profile().UserRecordSize == 28 {
+		return z.setLegacyUser(uid, userID, name, password, role, cardNo, group)
+	}
+
 	data := make([]byte, 72)
 
 	data[0] = byte(uid & 0xFF)
@@ -89,7 +222,7 @@ func (z *ZKTeco) SetUser(uid int, userID string, name string, password string, r
 
 	binary.LittleEndian.PutUint32(data[35:39], uint32(cardNo))
 
-	data[39] = 1
+	data[39] = group
 
 	if len(userID) > 9 {
 		userID = userID[:9]
@@ -107,13 +240,154 @@ func (z *ZKTeco) SetUser(uid int, userID string, name string, password string, r
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("setUser: error response %d", pkt.Command)
+		return fmt.Errorf("setUser: error response %s", commandString(pkt.Command))
+	}
+	return nil
+}
+
+// setLegacyUser writes a 28-byte user record for firmwares before 6.60.
+// The user ID must be numeric on these devices.
+func (z *ZKTeco) setLegacyUser(uid int, userID string, name string, password string, role int, cardNo int, group byte) error {
+	numericID, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		return fmt.Errorf("setUser: legacy firmware requires a numeric user ID: %q", userID)
+	}
+
+	data := make([]byte, 28)
+
+	binary.LittleEndian.PutUint16(data[0:2], uint16(uid))
+	data[2] = byte(role)
+
+	if len(password) > 5 {
+		password = password[:5]
+	}
+	copy(data[3:8], []byte(password))
+
+	if len(name) > 8 {
+		name = name[:8]
+	}
+	copy(data[8:16], []byte(name))
+
+	binary.LittleEndian.PutUint32(data[16:20], uint32(cardNo))
+	data[21] = group
+	binary.LittleEndian.PutUint32(data[24:28], uint32(numericID))
+
+	resp, err := z.command(CMD_SET_USER, data, "general")
+	if err != nil {
+		return fmt.Errorf("setUser: %w", err)
+	}
+
+	pkt, err := parsePacket(resp)
+	if err != nil {
+		return err
+	}
+	if pkt.Command != CMD_ACK_OK {
+		return fmt.Errorf("setUser: error response %s", commandString(pkt.Command))
+	}
+	return nil
+}
+
+// Disabled reports whether the user is blocked with DisableUser.
+func (u User) Disabled() bool {
+	return u.Role&USER_DISABLED != 0
+}
+
+// DisableUser blocks the user with the given UID from verifying on the
+// terminal, without deleting their fingerprint templates, card or password,
+// until EnableUser. It sets the USER_DISABLED bit of the role, and also moves
+// the user to access group 0 for firmwares that ignore the bit.
+func (z *ZKTeco) DisableUser(uid int) (err error) {
+	defer z.recoverInternal("disableUser", &err)
+	if err := z.setUserEnabled(uid, false); err != nil {
+		return fmt.Errorf("disableUser: %w", err)
+	}
+	return nil
+}
+
+// EnableUser lifts a DisableUser block, restoring the user's role and access
+// group.
+func (z *ZKTeco) EnableUser(uid int) (err error) {
+	defer z.recoverInternal("enableUser", &err)
+	if err := z.setUserEnabled(uid, true); err != nil {
+		return fmt.Errorf("enableUser: %w", err)
+	}
+	return nil
+}
+
+func (z *ZKTeco) setUserEnabled(uid int, enabled bool) error {
+	u, err := z.findUser(uid)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return z.setUser(u.UID, u.UserID, u.Name, u.Password, u.Role&^USER_DISABLED, u.CardNo, 1)
+	}
+	return z.setUser(u.UID, u.UserID, u.Name, u.Password, u.Role|USER_DISABLED, u.CardNo, 0)
+}
+
+// findUser returns the user with the given UID, or ErrUserNotFound.
+func (z *ZKTeco) findUser(uid int) (*User, error) {
+	users, err := z.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	for i := range users {
+		if users[i].UID == uid {
+			return &users[i], nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+// UserPatch holds the fields of a user record UpdateUser changes. Nil fields
+// keep their current value.
+type UserPatch struct {
+	UserID   *string
+	Name     *string
+	Password *string
+	Role     *int // a DisableUser block is kept
+	CardNo   *int
+}
+
+// UpdateUser changes the fields of changes on the user with the given UID,
+// keeping the others. Unlike SetUser, which writes the whole record, it reads
+// the current record first, so e.g. renaming a user does not wipe their card
+// or password. It returns ErrUserNotFound for unknown users.
+func (z *ZKTeco) UpdateUser(uid int, changes UserPatch) (err error) {
+	defer z.recoverInternal("updateUser", &err)
+	u, err := z.findUser(uid)
+	if err != nil {
+		return fmt.Errorf("updateUser: %w", err)
+	}
+	if changes.UserID != nil {
+		u.UserID = *changes.UserID
+	}
+	if changes.Name != nil {
+		u.Name = *changes.Name
+	}
+	if changes.Password != nil {
+		u.Password = *changes.Password
+	}
+	if changes.Role != nil {
+		u.Role = *changes.Role | u.Role&USER_DISABLED
+	}
+	if changes.CardNo != nil {
+		u.CardNo = *changes.CardNo
+	}
+
+	var group byte = 1
+	if u.Disabled() {
+		group = 0
+	}
+	if err := z.setUser(u.UID, u.UserID, u.Name, u.Password, u.Role, u.CardNo, group); err != nil {
+		return fmt.Errorf("updateUser: %w", err)
 	}
 	return nil
 }
 
 // RemoveUser removes a user by UID.
-func (z *ZKTeco) RemoveUser(uid int) error {
+func (z *ZKTeco) RemoveUser(uid int) (err error) {
+	defer z.recoverInternal("removeUser", &err)
 	data := []byte{byte(uid & 0xFF), byte((uid >> 8) & 0xFF)}
 	resp, err := z.command(CMD_DELETE_USER, data, "general")
 	if err != nil {
@@ -124,13 +398,14 @@ func (z *ZKTeco) RemoveUser(uid int) error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("removeUser: error response %d", pkt.Command)
+		return fmt.Errorf("removeUser: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // ClearAllUsers clears ALL data on the device.
-func (z *ZKTeco) ClearAllUsers() error {
+func (z *ZKTeco) ClearAllUsers() (err error) {
+	defer z.recoverInternal("clearAllUsers", &err)
 	resp, err := z.command(CMD_CLEAR_DATA, nil, "general")
 	if err != nil {
 		return fmt.Errorf("clearAllUsers: %w", err)
@@ -140,13 +415,14 @@ func (z *ZKTeco) ClearAllUsers() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("clearAllUsers: error response %d", pkt.Command)
+		return fmt.Errorf("clearAllUsers: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
 
 // ClearAdmin removes admin privileges from all users.
-func (z *ZKTeco) ClearAdmin() error {
+func (z *ZKTeco) ClearAdmin() (err error) {
+	defer z.recoverInternal("clearAdmin", &err)
 	resp, err := z.command(CMD_CLEAR_ADMIN, nil, "general")
 	if err != nil {
 		return fmt.Errorf("clearAdmin: %w", err)
@@ -156,7 +432,7 @@ func (z *ZKTeco) ClearAdmin() error {
 		return err
 	}
 	if pkt.Command != CMD_ACK_OK {
-		return fmt.Errorf("clearAdmin: error response %d", pkt.Command)
+		return fmt.Errorf("clearAdmin: error response %s", commandString(pkt.Command))
 	}
 	return nil
 }
@@ -0,0 +1,113 @@
+package zkteco
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+)
+
+// UserChangedEvent is an enrollment event with the record of the user it
+// changed, as delivered by Listener.OnUserChanged.
+type UserChangedEvent struct {
+	Event     RealTimeEvent  // the EF_ENROLLUSER or EF_ENROLLFINGER event
+	User      *User          // the user now on the device, nil if not found
+	Templates map[int][]byte // the user's fingerprint templates by finger
+}
+
+// OnUserChanged registers fn for user and fingerprint enrollments
+// (EF_ENROLLUSER, EF_ENROLLFINGER), delivered with the enrolled user's
+// record and templates read back from the device, so a directory kept in
+// sync from events needs no periodic full downloads. The lookup runs in the
+// listen loop with the events unregistered: events already sent by the
+// device are delivered once it is done, while punches made during the
+// lookup are only in the attendance log (see Replay). An error reading the
+// user stops Listen like a handler error.
+func (l *Listener) OnUserChanged(fn func(UserChangedEvent)) *Listener {
+	return l.Handle(EF_ENROLLUSER|EF_ENROLLFINGER, func(event RealTimeEvent) error {
+		changed, pending, err := l.lookupUser(event)
+		if err != nil {
+			return fmt.Errorf("user changed: %w", err)
+		}
+		fn(changed)
+		for _, e := range pending {
+			if err := l.dispatch(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lookupUser reads the user of an enrollment event, with the events
+// unregistered. It returns the events that arrived before the device
+// acknowledged the unregistration.
+func (l *Listener) lookupUser(event RealTimeEvent) (UserChangedEvent, []RealTimeEvent, error) {
+	z := l.zk
+	changed := UserChangedEvent{Event: event}
+	mask := l.Mask()
+
+	pending, err := z.suspendEvents(mask)
+	if err != nil {
+		return changed, pending, err
+	}
+
+	users, err := z.GetUsers()
+	if err == nil {
+		for i := range users {
+			if users[i].UserID == event.UserID {
+				changed.User = &users[i]
+				break
+			}
+		}
+	}
+	if err == nil && changed.User != nil {
+		changed.Templates = make(map[int][]byte)
+		err = z.whileDisabled(func() error {
+			z.readFingerprints(changed.User.UID, changed.Templates)
+			return nil
+		})
+	}
+	if regErr := z.registerEvents(mask); err == nil {
+		err = regErr
+	}
+	return changed, pending, err
+}
+
+// suspendEvents unregisters all events. Events of mask the device sends
+// before acknowledging are decoded and returned instead of being mistaken
+// for the acknowledgement.
+func (z *ZKTeco) suspendEvents(mask int) ([]RealTimeEvent, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	data := make([]byte, 4)
+	z.syncReplyID()
+	pkt, nextReplyID := z.newPacket(CMD_REG_EVENT, data)
+	if err := z.sendData(pkt); err != nil {
+		return nil, fmt.Errorf("unregister events: %w", err)
+	}
+
+	var pending []RealTimeEvent
+	for {
+		resp, err := z.recvData(z.callContext(context.Background()))
+		if err != nil {
+			return pending, fmt.Errorf("unregister events: %w", err)
+		}
+		if len(resp) < 8 {
+			continue
+		}
+		if binary.LittleEndian.Uint16(resp[0:2]) == CMD_REG_EVENT {
+			if len(resp) >= 6 {
+				if eventType := int(binary.LittleEndian.Uint16(resp[4:6])); eventType&mask != 0 {
+					pending = append(pending, z.decodeRealTimeEvent(resp, eventType))
+				}
+			}
+			continue
+		}
+		z.replyID = nextReplyID
+		z.lastData = resp
+		if cmd := binary.LittleEndian.Uint16(resp[0:2]); cmd != CMD_ACK_OK {
+			return pending, fmt.Errorf("unregister events: error response %s", commandString(cmd))
+		}
+		return pending, nil
+	}
+}
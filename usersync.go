@@ -0,0 +1,158 @@
+package zkteco
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Kinds of UserChange.
+const (
+	UserAdd    = "add"
+	UserUpdate = "update"
+	UserRemove = "remove"
+)
+
+// UserChange is one change that brings the user table of a device in line
+// with a desired list of users.
+type UserChange struct {
+	Kind     string `json:"kind"` // UserAdd, UserUpdate or UserRemove
+	User     User   `json:"user"`
+	Previous *User  `json:"previous,omitempty"` // the device record, for updates and removals
+}
+
+func (c UserChange) String() string {
+	switch c.Kind {
+	case UserAdd:
+		return fmt.Sprintf("+ %s %q (uid %d)", c.User.UserID, c.User.Name, c.User.UID)
+	case UserRemove:
+		return fmt.Sprintf("- %s %q (uid %d)", c.User.UserID, c.User.Name, c.User.UID)
+	}
+	var diffs []string
+	p, u := c.Previous, c.User
+	if p.Name != u.Name {
+		diffs = append(diffs, fmt.Sprintf("name %q -> %q", p.Name, u.Name))
+	}
+	if p.Password != u.Password {
+		diffs = append(diffs, "password changed")
+	}
+	if p.Role != u.Role {
+		diffs = append(diffs, fmt.Sprintf("role %d -> %d", p.Role, u.Role))
+	}
+	if p.CardNo != u.CardNo {
+		diffs = append(diffs, fmt.Sprintf("card %d -> %d", p.CardNo, u.CardNo))
+	}
+	return fmt.Sprintf("~ %s (uid %d): %s", u.UserID, u.UID, strings.Join(diffs, ", "))
+}
+
+// DiffUserLists returns the changes that turn the user list current into
+// desired. Users are matched by user ID, and existing users keep their UID;
+// added users without a UID get the next free one. An empty desired password
+// keeps the current one. Users missing from desired
+// are removed only if remove is set. Changes are sorted by kind (removals
+// first, so their UIDs can be reused) and user ID.
+func DiffUserLists(current, desired []User, remove bool) []UserChange {
+	byID := make(map[string]User, len(current))
+	maxUID := 0
+	for _, u := range current {
+		byID[u.UserID] = u
+		maxUID = max(maxUID, u.UID)
+	}
+
+	var changes []UserChange
+	wanted := make(map[string]bool, len(desired))
+	for _, u := range desired {
+		wanted[u.UserID] = true
+		cur, ok := byID[u.UserID]
+		if !ok {
+			continue
+		}
+		u.UID = cur.UID
+		if u.Password == "" {
+			u.Password = cur.Password
+		}
+		if u.Name != cur.Name || u.Password != cur.Password || u.Role != cur.Role || u.CardNo != cur.CardNo {
+			prev := cur
+			changes = append(changes, UserChange{Kind: UserUpdate, User: u, Previous: &prev})
+		}
+	}
+	if remove {
+		for _, cur := range current {
+			if !wanted[cur.UserID] {
+				prev := cur
+				changes = append(changes, UserChange{Kind: UserRemove, User: cur, Previous: &prev})
+			}
+		}
+	}
+	for _, u := range desired {
+		if _, ok := byID[u.UserID]; ok {
+			continue
+		}
+		if u.UID == 0 {
+			maxUID++
+			u.UID = maxUID
+		}
+		changes = append(changes, UserChange{Kind: UserAdd, User: u})
+	}
+
+	order := map[string]int{UserRemove: 0, UserUpdate: 1, UserAdd: 2}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return order[changes[i].Kind] < order[changes[j].Kind]
+		}
+		return changes[i].User.UserID < changes[j].User.UserID
+	})
+	return changes
+}
+
+// DiffUsers compares the user table of the device with desired and returns
+// the changes ApplyUserChanges would make, without changing anything. See
+// DiffUserLists.
+func (z *ZKTeco) DiffUsers(desired []User, remove bool) (_ []UserChange, err error) {
+	defer z.recoverInternal("diffUsers", &err)
+	current, err := z.GetUsers()
+	if err != nil {
+		return nil, fmt.Errorf("diffUsers: %w", err)
+	}
+	return DiffUserLists(current, desired, remove), nil
+}
+
+// ApplyUserChanges makes the changes, in order, with the device disabled,
+// and refreshes its data. It stops at the first failure and returns the
+// number of changes made.
+func (z *ZKTeco) ApplyUserChanges(changes []UserChange) (applied int, err error) {
+	defer z.recoverInternal("applyUserChanges", &err)
+	apply := func() error {
+		for _, c := range changes {
+			var err error
+			u := c.User
+			switch c.Kind {
+			case UserAdd, UserUpdate:
+				err = z.SetUser(u.UID, u.UserID, u.Name, u.Password, u.Role, u.CardNo)
+			case UserRemove:
+				err = z.RemoveUser(u.UID)
+			default:
+				err = fmt.Errorf("unknown change %q", c.Kind)
+			}
+			if err != nil {
+				return fmt.Errorf("%s %s: %w", c.Kind, u.UserID, err)
+			}
+			applied++
+		}
+		return nil
+	}
+
+	if z.isDisabled() {
+		err = apply()
+		if err == nil {
+			err = z.RefreshData()
+		}
+	} else {
+		err = z.WithDeviceDisabled(context.Background(), apply)
+	}
+	if err != nil {
+		return applied, fmt.Errorf("applyUserChanges: %w", err)
+	}
+	return applied, nil
+}
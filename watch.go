@@ -0,0 +1,62 @@
+package zkteco
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OptionChange reports a device option whose value changed between two polls.
+type OptionChange struct {
+	Key        string    `json:"key"`
+	Old        string    `json:"old"`
+	New        string    `json:"new"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// WatchOption polls the device option key every interval until ctx is
+// canceled and calls onChange whenever its value differs from the previous
+// poll, e.g. to notice that someone changed the IP address or enabled face
+// mode on the terminal itself. See WatchOptions.
+func (z *ZKTeco) WatchOption(ctx context.Context, key string, interval time.Duration, onChange func(OptionChange)) error {
+	return z.WatchOptions(ctx, []string{key}, interval, onChange)
+}
+
+// WatchOptions is like WatchOption for several keys, read in one batch per
+// poll. The first poll records the current values without reporting them.
+// It returns ctx.Err() once ctx is canceled, or the first error reading the
+// options. Other goroutines may use the client meanwhile (see ZKTeco).
+func (z *ZKTeco) WatchOptions(ctx context.Context, keys []string, interval time.Duration, onChange func(OptionChange)) (err error) {
+	defer z.recoverInternal("watchOptions", &err)
+	if interval <= 0 {
+		return fmt.Errorf("watchOptions: interval must be positive")
+	}
+
+	last, err := z.getDeviceOptions(keys)
+	if err != nil {
+		return fmt.Errorf("watchOptions: %w", err)
+	}
+
+	ticker := z.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+
+		values, err := z.getDeviceOptions(keys)
+		if err != nil {
+			return fmt.Errorf("watchOptions: %w", err)
+		}
+		now := z.clock.Now()
+		for i, key := range keys {
+			if values[i] != last[i] {
+				onChange(OptionChange{Key: key, Old: last[i], New: values[i], DetectedAt: now})
+			}
+		}
+		last = values
+	}
+}
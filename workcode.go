@@ -0,0 +1,89 @@
+package zkteco
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// WorkCode is a job code the user can pick on the device keypad after punching.
+type WorkCode struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// workCodeRecordSize is the size of one record in the work code table:
+// id(4) + code(8) + name(24).
+const workCodeRecordSize = 36
+
+// GetWorkCodes retrieves the work code table from the device.
+func (z *ZKTeco) GetWorkCodes() (_ []WorkCode, err error) {
+	defer z.recoverInternal("getWorkCodes", &err)
+	allData, err := z.commandData(context.Background(), CMD_USER_TEMP_RRQ, []byte{FCT_WORKCODE})
+	if err != nil {
+		return nil, fmt.Errorf("getWorkCodes: %w", err)
+	}
+
+	// Skip 8-byte header and 4-byte size prefix
+	if len(allData) <= 12 {
+		return nil, nil
+	}
+	data := allData[12:]
+
+	var codes []WorkCode
+	for i := 0; i+workCodeRecordSize <= len(data); i += workCodeRecordSize {
+		rec := data[i : i+workCodeRecordSize]
+		id := int(binary.LittleEndian.Uint32(rec[0:4]))
+		if id == 0 {
+			continue
+		}
+		codes = append(codes, WorkCode{
+			ID:   id,
+			Name: strings.TrimRight(string(rec[12:36]), "\x00"),
+		})
+	}
+	return codes, nil
+}
+
+// SetWorkCodes replaces the device work code table with codes.
+func (z *ZKTeco) SetWorkCodes(codes []WorkCode) (err error) {
+	defer z.recoverInternal("setWorkCodes", &err)
+	data := make([]byte, 4+len(codes)*workCodeRecordSize)
+	binary.LittleEndian.PutUint32(data[0:4], uint32(len(codes)*workCodeRecordSize))
+
+	for i, wc := range codes {
+		if wc.ID <= 0 {
+			return fmt.Errorf("setWorkCodes: invalid work code ID %d", wc.ID)
+		}
+		rec := data[4+i*workCodeRecordSize : 4+(i+1)*workCodeRecordSize]
+		binary.LittleEndian.PutUint32(rec[0:4], uint32(wc.ID))
+		copy(rec[4:12], strconv.Itoa(wc.ID))
+		name := wc.Name
+		if len(name) > 24 {
+			name = name[:24]
+		}
+		copy(rec[12:36], name)
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if err := z.sendLargeData(data); err != nil {
+		return fmt.Errorf("setWorkCodes: %w", err)
+	}
+	if err := z.ackCommandLocked("setWorkCodes", CMD_USER_TEMP_WRQ, []byte{FCT_WORKCODE}); err != nil {
+		return err
+	}
+	return z.ackCommandLocked("setWorkCodes", CMD_REFRESHDATA, nil)
+}
+
+// SetWorkCodeEnabled turns the work code prompt shown after a punch on or off.
+func (z *ZKTeco) SetWorkCodeEnabled(enabled bool) (err error) {
+	defer z.recoverInternal("setWorkCodeEnabled", &err)
+	value := "0"
+	if enabled {
+		value = "1"
+	}
+	return z.SetDeviceData("WorkCode", value)
+}
@@ -1,16 +1,44 @@
 package zkteco
 
 import (
-	"bufio"
+	"context"
+	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
-	"net"
+	"log/slog"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/0mithun/go-zkteco/protocol"
 )
 
 // ZKTeco is the main client for connecting to ZKTeco devices.
+//
+// A connected client can be shared by several goroutines: each exchange
+// with the device (a command and its reply, a whole data transfer or
+// upload, one read of an event listener) holds the connection, and the
+// others wait for it, so the session is never corrupted. Sequences of
+// calls are not atomic: another goroutine's commands may run between them,
+// including between the disable and the transfer of a WithAutoDisable
+// download. Use an Actor, or a lock of your own, when a sequence must not
+// be interleaved. A listening client lets other calls through between its
+// reads, at most a second apart. Callbacks run during a transfer (of
+// EachAttendance, StreamAttendances, progress functions) hold the
+// connection and must not use the client; event handlers may. A context
+// (of Do and the ...Context methods) applies to the calls it was passed to
+// only. Connect must not overlap other calls; Disconnect may, the other
+// calls then fail as not connected.
 type ZKTeco struct {
+	*client
+	ctx context.Context // bound by Do; nil outside Do
+}
+
+// client is the state of a ZKTeco client, shared by the ZKTeco values Do
+// binds to a context.
+type client struct {
 	host     string
 	port     int
 	protocol string
@@ -22,12 +50,71 @@ type ZKTeco struct {
 	tcpmuxHost      string
 	tcpmuxPort      int
 	tcpmuxSubdomain string
-
-	conn      net.Conn
-	sessionID uint16
-	replyID   uint16
-	lastData  []byte
-	tcpBuffer []byte
+	tcpmuxHeaders   http.Header // see WithTCPMUXHeaders
+
+	// Firmware profile; see WithProfile and WithProfileDetection
+	profile       *Profile
+	profileFixed  bool
+	profileDetect bool
+
+	// Device serial stamped on returned records; see WithRecordSerial
+	recordSerial bool
+	serial       string
+	rawRecords   bool // see WithRawRecords
+
+	readerDirections map[int]Direction // see WithReaderDirections
+	recordTimeWindow *RecordTimeWindow // see WithRecordTimeWindow
+
+	// Re-enable a device left disabled on Disconnect; see WithEnableOnDisconnect
+	enableOnDisconnect bool
+	disabled           bool
+	fastDisconnect     bool // see WithFastDisconnect
+	autoDisable        bool // see WithAutoDisable
+	dryRun             bool // see WithDryRun
+
+	// TCP receive limits; see WithReadBufferSize and WithMaxBufferSize
+	readBufferSize int
+	maxBufferSize  int
+
+	customTransport Transport    // set by WithTransport
+	machineNumber   int          // set by WithMachineNumber
+	dial            DialFunc     // set by WithDialer
+	logger          *slog.Logger // set by WithLogger
+	clock           Clock        // set by WithClock
+
+	busyWait  time.Duration   // see WithBusyWait
+	keepalive time.Duration   // see WithKeepalive
+	adaptive  *latencyTracker // set by WithAdaptiveTimeout
+	inspect   inspector       // see LastPacket and WithTrace
+
+	parked        [][]byte            // event packets received during commands; see divertEvent
+	listeners     int                 // running event listeners, guarded by mu
+	onUnsolicited func(RealTimeEvent) // set by WithUnsolicitedEvents
+
+	onReboot       func(RebootEvent) // set by WithOnReboot
+	rebootReported bool              // onReboot was called for this session
+
+	// Per-connection cache of options and version; see WithCache
+	cacheTTL time.Duration
+	cache    map[string]cachedValue
+
+	// mu is held during each exchange with the device; it guards the
+	// transport and the session state below, the disabled flag and the
+	// cache. The transport is also replaced under transportMu, which lets
+	// interruptOn reach it while a read holds mu; readOwner identifies the
+	// exchange whose context may interrupt the pending read.
+	mu          sync.Mutex
+	transportMu sync.Mutex
+	transport   Transport
+	readOwner   uint64
+	readSeq     uint64
+	sessionID   uint16
+	replyID     uint16
+	lastData    []byte
+
+	lastCommand   uint16        // command of the last exchange; see InternalError
+	lastSent      time.Time     // when the last packet was sent
+	keepaliveStop chan struct{} // stops the WithKeepalive heartbeat
 }
 
 // Option configures a ZKTeco client.
@@ -67,16 +154,118 @@ func WithTCPMUX(host string, port int, subdomain string) Option {
 	}
 }
 
+// WithTCPMUXHeaders adds headers to the HTTP CONNECT request of WithTCPMUX,
+// e.g. a bearer token the endpoint requires. They replace the default
+// Proxy-Connection header if they set it; Host is always the target.
+func WithTCPMUXHeaders(headers http.Header) Option {
+	return func(z *ZKTeco) {
+		if z.tcpmuxHeaders == nil {
+			z.tcpmuxHeaders = make(http.Header)
+		}
+		for key, values := range headers {
+			z.tcpmuxHeaders[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+	}
+}
+
+// WithTCPMUXAuth authenticates the HTTP CONNECT request of WithTCPMUX with
+// Proxy-Authorization Basic credentials, as set with httpUser and
+// httpPassword on an frp tcpmux proxy.
+func WithTCPMUXAuth(user, password string) Option {
+	return WithTCPMUXHeaders(http.Header{
+		"Proxy-Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))},
+	})
+}
+
+// WithRecordSerial reads the device serial number once on Connect and sets
+// it as DeviceSerial on every returned User, Attendance and RealTimeEvent, so
+// records from several devices can be told apart. Connect fails if the
+// serial number cannot be read.
+func WithRecordSerial() Option {
+	return func(z *ZKTeco) {
+		z.recordSerial = true
+	}
+}
+
+// WithRawRecords keeps a copy of the bytes of each record in User.Raw and
+// Attendance.Raw, as read from the device, to debug parsing against unknown
+// firmware layouts or to archive lossless originals. Default is off.
+func WithRawRecords() Option {
+	return func(z *ZKTeco) {
+		z.rawRecords = true
+	}
+}
+
+// WithEnableOnDisconnect controls whether Disconnect re-enables a device that
+// was disabled with DisableDevice and not enabled again, so the terminal is
+// not left locked on "working...". Default is true.
+func WithEnableOnDisconnect(enabled bool) Option {
+	return func(z *ZKTeco) {
+		z.enableOnDisconnect = enabled
+	}
+}
+
+// WithFastDisconnect makes Disconnect send CMD_EXIT without waiting for the
+// device to acknowledge it before closing the connection. Use it when the
+// device is reached through a proxy or tunnel that half-closes the connection
+// on exit, which otherwise makes Disconnect hang for the full timeout.
+func WithFastDisconnect() Option {
+	return func(z *ZKTeco) {
+		z.fastDisconnect = true
+	}
+}
+
+// WithLogger sets the logger the client reports protocol anomalies to, such
+// as the ones a LenientSession profile tolerates. Default is none.
+func WithLogger(logger *slog.Logger) Option {
+	return func(z *ZKTeco) {
+		z.logger = logger
+	}
+}
+
+// warn logs a protocol anomaly to the WithLogger logger, if any.
+func (z *ZKTeco) warn(msg string, args ...any) {
+	if z.logger != nil {
+		z.logger.Warn("zkteco: "+msg, append([]any{"device", z.host}, args...)...)
+	}
+}
+
+// WithReadBufferSize sets the size of the buffer each TCP read uses.
+// Default is 16384 bytes.
+func WithReadBufferSize(size int) Option {
+	return func(z *ZKTeco) {
+		if size > 0 {
+			z.readBufferSize = size
+		}
+	}
+}
+
+// WithMaxBufferSize caps the TCP reassembly buffer, which holds received bytes
+// until a complete packet is framed. Reads that would exceed it fail with
+// ErrBufferOverflow, bounding memory on garbage input. Default is 0 (no cap).
+// It does not limit the size of large data transfers, which are reassembled
+// separately.
+func WithMaxBufferSize(size int) Option {
+	return func(z *ZKTeco) {
+		z.maxBufferSize = size
+	}
+}
+
 // NewZKTeco creates a new ZKTeco client.
 func NewZKTeco(host string, port int, opts ...Option) *ZKTeco {
-	z := &ZKTeco{
+	z := &ZKTeco{client: &client{
 		host:     host,
 		port:     port,
 		protocol: "udp",
 		timeout:  25 * time.Second,
 		password: 0,
 		replyID:  65534,
-	}
+
+		enableOnDisconnect: true,
+		readBufferSize:     16384,
+		machineNumber:      1,
+		clock:              SystemClock,
+	}}
 	for _, opt := range opts {
 		opt(z)
 	}
@@ -88,143 +277,256 @@ func (z *ZKTeco) IsTCP() bool {
 	return z.protocol == "tcp"
 }
 
-// Connect establishes a connection to the ZKTeco device.
-func (z *ZKTeco) Connect() error {
-	var err error
-
-	if z.tcpmuxEnabled {
-		// TCPMUX: connect to proxy, then HTTP CONNECT handshake
-		proxyAddr := fmt.Sprintf("%s:%d", z.tcpmuxHost, z.tcpmuxPort)
-		z.conn, err = net.DialTimeout("tcp", proxyAddr, z.timeout)
-		if err != nil {
-			return fmt.Errorf("dial tcpmux proxy %s: %w", proxyAddr, err)
-		}
+// Connect establishes a connection to the ZKTeco device. If the device is
+// busy with another client, it fails with ErrDeviceBusy, or keeps retrying
+// for the WithBusyWait period.
+func (z *ZKTeco) Connect() (err error) {
+	defer z.recoverInternal("connect", &err)
+	return z.connectRetry()
+}
 
-		if err := z.httpConnectHandshake(); err != nil {
-			z.conn.Close()
-			z.conn = nil
-			return fmt.Errorf("tcpmux handshake: %w", err)
+// connectRetry connects, retrying for the WithBusyWait period while the
+// device is busy, or until the context of z is done.
+func (z *ZKTeco) connectRetry() error {
+	ctx := z.callContext(context.Background())
+	deadline := z.clock.Now().Add(z.busyWait)
+	for {
+		err := z.connect(ctx)
+		if !errors.Is(err, ErrDeviceBusy) || !z.clock.Now().Add(busyRetryInterval).Before(deadline) {
+			return err
 		}
-	} else {
-		addr := fmt.Sprintf("%s:%d", z.host, z.port)
-		if z.IsTCP() {
-			z.conn, err = net.DialTimeout("tcp", addr, z.timeout)
-		} else {
-			z.conn, err = net.DialTimeout("udp", addr, z.timeout)
+		if !sleep(ctx, z.clock, busyRetryInterval) {
+			return err
 		}
+	}
+}
+
+func (z *ZKTeco) connect(ctx context.Context) error {
+	if err := z.handshake(ctx); err != nil {
+		return err
+	}
+
+	if z.profileDetect && !z.profileFixed {
+		z.detectProfile()
+	}
+
+	if z.recordSerial {
+		serial, err := z.SerialNumber()
 		if err != nil {
-			return fmt.Errorf("dial %s %s: %w", z.protocol, addr, err)
+			z.Disconnect()
+			return fmt.Errorf("read serial number: %w", err)
 		}
+		z.serial = serial
+	}
+
+	if z.keepalive > 0 {
+		z.startKeepalive()
+	}
+	return nil
+}
+
+// handshake dials the device and opens a session, giving up when ctx is
+// done.
+func (z *ZKTeco) handshake(ctx context.Context) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	t := z.newTransport()
+	var err error
+	dialTimeout := z.timeout
+	if z.adaptive != nil {
+		dialTimeout = z.adaptive.cfg.Max
+		z.adaptive.reset()
+	}
+	if cd, ok := t.(ContextDialer); ok && ctx.Done() != nil {
+		err = cd.DialContext(ctx, dialTimeout)
+	} else {
+		err = t.Dial(dialTimeout)
+	}
+	if err != nil {
+		return err
 	}
+	z.setTransport(t)
 
 	z.sessionID = 0
 	z.replyID = 65534
-	z.tcpBuffer = nil
+	z.disabled = false
+	z.cache = nil
+	z.rebootReported = false
+	z.parked = nil
 
-	resp, err := z.command(CMD_CONNECT, nil, "general")
+	resp, err := z.commandLocked(ctx, CMD_CONNECT, nil, "general")
 	if err != nil {
-		z.conn.Close()
-		return fmt.Errorf("connect command: %w", err)
+		z.closeTransport()
+		return fmt.Errorf("connect command: %w", busyError(err))
 	}
 
 	pkt, err := parsePacket(resp)
 	if err != nil {
-		z.conn.Close()
+		z.closeTransport()
 		return fmt.Errorf("parse connect response: %w", err)
 	}
 
 	z.sessionID = pkt.SessionID
 
-	if pkt.Command == CMD_ACK_UNAUTH {
-		authKey := makeCommKey(z.password, z.sessionID)
-		resp2, err := z.command(CMD_ACK_AUTH, authKey, "general")
+	if pkt.Command == CMD_ACK_UNAUTH || (z.profileLocked().AuthOnConnect && z.password != 0) {
+		authKey := z.profileLocked().commKey().CommKey(z.password, z.sessionID)
+		resp2, err := z.commandLocked(ctx, CMD_ACK_AUTH, authKey, "general")
 		if err != nil {
-			z.conn.Close()
+			z.closeTransport()
 			return fmt.Errorf("auth command: %w", err)
 		}
 		pkt2, err := parsePacket(resp2)
 		if err != nil {
-			z.conn.Close()
+			z.closeTransport()
 			return fmt.Errorf("parse auth response: %w", err)
 		}
 		if pkt2.Command != CMD_ACK_OK {
-			z.conn.Close()
-			return fmt.Errorf("authentication failed: command=%d", pkt2.Command)
+			z.closeTransport()
+			return fmt.Errorf("authentication failed: %s", commandString(pkt2.Command))
 		}
 	}
-
 	return nil
 }
 
 // Disconnect closes the connection.
-func (z *ZKTeco) Disconnect() error {
-	if z.conn == nil {
+func (z *ZKTeco) Disconnect() (err error) {
+	defer z.recoverInternal("disconnect", &err)
+	if z.enableOnDisconnect && z.isDisabled() {
+		z.EnableDevice()
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if z.transport == nil {
 		return nil
 	}
-	z.command(CMD_EXIT, nil, "general")
+	if z.fastDisconnect {
+		z.syncReplyID()
+		pkt, _ := z.newPacket(CMD_EXIT, nil)
+		z.sendData(pkt)
+	} else {
+		z.commandLocked(z.callContext(context.Background()), CMD_EXIT, nil, "general")
+	}
 	z.sessionID = 0
-	err := z.conn.Close()
-	z.conn = nil
-	return err
+	return z.closeTransport()
 }
 
-// httpConnectHandshake performs HTTP CONNECT through a TCPMUX proxy.
-func (z *ZKTeco) httpConnectHandshake() error {
-	target := fmt.Sprintf("%s.%s:%d", z.tcpmuxSubdomain, z.host, z.port)
+// closeTransport closes the connection without sending CMD_EXIT.
+func (z *ZKTeco) closeTransport() error {
+	z.stopKeepalive()
+	err := z.transport.Close()
+	z.setTransport(nil)
+	return err
+}
 
-	request := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\nProxy-Connection: Keep-Alive\r\n\r\n", target, target)
+// setTransport replaces the transport. The caller holds z.mu.
+func (z *ZKTeco) setTransport(t Transport) {
+	z.transportMu.Lock()
+	z.transport = t
+	z.transportMu.Unlock()
+}
 
-	z.conn.SetWriteDeadline(time.Now().Add(z.timeout))
-	if _, err := z.conn.Write([]byte(request)); err != nil {
-		return fmt.Errorf("send CONNECT request: %w", err)
+// interruptOn makes the pending read of the exchange in progress return at
+// once when ctx is done. The caller holds z.mu and calls stop when the
+// exchange ends; reads of other exchanges are never interrupted, even if
+// ctx ends while stop runs.
+func (z *ZKTeco) interruptOn(ctx context.Context) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+	z.transportMu.Lock()
+	prev := z.readOwner
+	z.readSeq++
+	owner := z.readSeq
+	z.readOwner = owner
+	z.transportMu.Unlock()
+
+	stopAfter := context.AfterFunc(ctx, func() {
+		z.transportMu.Lock()
+		defer z.transportMu.Unlock()
+		if z.readOwner == owner && z.transport != nil {
+			z.transport.SetReadDeadline(interruptDeadline)
+		}
+	})
+	return func() {
+		stopAfter()
+		z.transportMu.Lock()
+		if z.readOwner == owner {
+			z.readOwner = prev
+		}
+		z.transportMu.Unlock()
 	}
+}
 
-	z.conn.SetReadDeadline(time.Now().Add(z.timeout))
-	reader := bufio.NewReader(z.conn)
-	statusLine, err := reader.ReadString('\n')
-	if err != nil {
-		return fmt.Errorf("read proxy response: %w", err)
-	}
+// command sends a command and receives the response. While the device
+// answers CMD_ACK_RETRY, the command is resent for the WithBusyWait period,
+// after which it fails with ErrDeviceBusy.
+func (z *ZKTeco) command(cmd uint16, data []byte, cmdType string) ([]byte, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.commandLocked(z.callContext(context.Background()), cmd, data, cmdType)
+}
 
-	// Read remaining headers until blank line
+// commandLocked is command for callers holding z.mu, giving up when ctx is
+// done.
+func (z *ZKTeco) commandLocked(ctx context.Context, cmd uint16, data []byte, cmdType string) ([]byte, error) {
+	if err := z.profileLocked().supports(cmd); err != nil {
+		return nil, err
+	}
+	stop := z.interruptOn(ctx)
+	defer stop()
+	deadline := z.clock.Now().Add(z.busyWait)
 	for {
-		line, err := reader.ReadString('\n')
-		if err != nil {
-			return fmt.Errorf("read proxy headers: %w", err)
+		resp, err := z.commandOnce(ctx, cmd, data, cmdType)
+		if err != nil || !isBusyResponse(resp) {
+			return resp, err
 		}
-		if strings.TrimSpace(line) == "" {
-			break
+		if !z.clock.Now().Add(busyRetryInterval).Before(deadline) {
+			return nil, fmt.Errorf("command %s: %w", commandString(cmd), ErrDeviceBusy)
+		}
+		if !sleep(ctx, z.clock, busyRetryInterval) {
+			return nil, fmt.Errorf("command %s: %w", commandString(cmd), ctx.Err())
 		}
 	}
-
-	// Check for HTTP 200
-	statusLine = strings.TrimSpace(statusLine)
-	if !strings.Contains(statusLine, " 200 ") {
-		return fmt.Errorf("proxy returned: %s", statusLine)
-	}
-
-	return nil
 }
 
-// command sends a command and receives the response.
-func (z *ZKTeco) command(cmd uint16, data []byte, cmdType string) ([]byte, error) {
-	if len(z.lastData) >= 8 {
-		z.replyID = binary.LittleEndian.Uint16(z.lastData[6:8])
+// commandOnce sends a command and receives the response.
+func (z *ZKTeco) commandOnce(ctx context.Context, cmd uint16, data []byte, cmdType string) (_ []byte, err error) {
+	z.lastCommand = cmd
+	z.syncReplyID()
+	if z.dryRun && writeCommands[cmd] {
+		return z.dryRunCommand(cmd, data), nil
 	}
 
-	pkt, nextReplyID := createHeader(cmd, z.sessionID, z.replyID, data)
+	pkt, nextReplyID := z.newPacket(cmd, data)
+
+	sent := z.clock.Now()
+	var resp []byte
+	defer func() { z.inspect.record(cmd, pkt, resp, err, sent, z.clock.Now()) }()
 
 	if err := z.sendData(pkt); err != nil {
 		return nil, err
 	}
 
-	resp, err := z.recvData()
+	resp, err = z.recvReply(ctx, cmd, nextReplyID, sent)
 	if err != nil {
 		return nil, err
 	}
 
 	z.replyID = nextReplyID
 	z.lastData = resp
+	z.trackDeviceState(cmd, resp)
+	if err := z.checkSessionReset(cmd, resp); err != nil {
+		return nil, err
+	}
+
+	lenient := z.profileLocked().LenientSession
+	if lenient && len(resp) >= 8 {
+		if got := binary.LittleEndian.Uint16(resp[6:8]); got != nextReplyID {
+			z.warn("reply ID mismatch", "command", commandString(cmd), "expected", nextReplyID, "got", got)
+		}
+	}
 
 	if cmdType == "data" {
 		return resp, nil
@@ -233,189 +535,357 @@ func (z *ZKTeco) command(cmd uint16, data []byte, cmdType string) ([]byte, error
 	if z.sessionID != 0 && len(resp) >= 6 {
 		respSessionID := binary.LittleEndian.Uint16(resp[4:6])
 		if respSessionID != z.sessionID {
-			return nil, fmt.Errorf("session mismatch: expected %d got %d", z.sessionID, respSessionID)
+			if !lenient {
+				return nil, fmt.Errorf("session mismatch: expected %d got %d", z.sessionID, respSessionID)
+			}
+			z.warn("session mismatch", "command", commandString(cmd), "expected", z.sessionID, "got", respSessionID)
 		}
 	}
 
 	return resp, nil
 }
 
-// sendData sends raw packet data, wrapping with TCP header if needed.
-func (z *ZKTeco) sendData(data []byte) error {
-	if z.conn == nil {
-		return fmt.Errorf("not connected")
+// RawCommand sends an arbitrary command and returns the reply packet (header
+// and data, without TCP framing) uninterpreted, for exploring undocumented
+// commands. When the device answers with CMD_PREPARE_DATA, the large transfer
+// that follows is read and returned as payload.
+func (z *ZKTeco) RawCommand(cmd uint16, data []byte) (reply, payload []byte, err error) {
+	defer z.recoverInternal("rawCommand", &err)
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	ctx := z.callContext(context.Background())
+	reply, err = z.commandLocked(ctx, cmd, data, "data")
+	if err != nil {
+		return nil, nil, fmt.Errorf("rawCommand: %w", err)
 	}
+	if len(reply) < 2 || binary.LittleEndian.Uint16(reply[0:2]) != CMD_PREPARE_DATA {
+		return reply, nil, nil
+	}
+	allData, err := z.recvLargeData(ctx, reply)
+	if err != nil {
+		return reply, nil, fmt.Errorf("rawCommand: %w", err)
+	}
+	if len(allData) > 8 {
+		payload = allData[8:]
+	}
+	return reply, payload, nil
+}
 
-	z.conn.SetWriteDeadline(time.Now().Add(z.timeout))
-
-	var toSend []byte
-	if z.IsTCP() {
-		toSend = wrapTCP(data)
-	} else {
-		toSend = data
+// syncReplyID continues from the reply ID of the last response, as the
+// device expects. Profiles with LenientSession keep the client's own count
+// instead, for firmwares that do not echo it.
+func (z *ZKTeco) syncReplyID() {
+	if len(z.lastData) >= 8 && !z.profileLocked().LenientSession {
+		z.replyID = binary.LittleEndian.Uint16(z.lastData[6:8])
 	}
+}
 
-	_, err := z.conn.Write(toSend)
-	return err
+// pipelineWait bounds how long commandPipeline waits for each further
+// response once one has arrived. Firmwares that cannot queue commands drop
+// the extra ones rather than answer them late.
+const pipelineWait = 2 * time.Second
+
+// pipelinedCommand is one command of a commandPipeline batch.
+type pipelinedCommand struct {
+	cmd  uint16
+	data []byte
 }
 
-// recvData receives a response, handling TCP framing if needed.
-func (z *ZKTeco) recvData() ([]byte, error) {
-	if z.conn == nil {
-		return nil, fmt.Errorf("not connected")
+// commandPipeline sends all cmds before reading any response, saving a round
+// trip per command. Responses are matched to commands by reply ID and
+// returned in order; a nil entry means the device did not answer that
+// command, and the caller should retry it with command. Packets still in
+// flight after a partial batch are drained so the session stays usable.
+func (z *ZKTeco) commandPipeline(cmds []pipelinedCommand) [][]byte {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	resps := make([][]byte, len(cmds))
+	if z.transport == nil {
+		return resps
+	}
+	z.syncReplyID()
+
+	index := make(map[uint16]int, len(cmds))
+	for i, c := range cmds {
+		pkt, nextReplyID := z.newPacket(c.cmd, c.data)
+		if err := z.sendData(pkt); err != nil {
+			break
+		}
+		index[nextReplyID] = i
+		z.replyID = nextReplyID
 	}
+	// The reply ID is carried by z.replyID from here on
+	z.lastData = nil
 
-	z.conn.SetReadDeadline(time.Now().Add(z.timeout))
+	wait := pipelineWait
+	if z.timeout < wait {
+		wait = z.timeout
+	}
+	pending := len(index)
+	var deadline time.Time // the first response gets the client timeout
+	for pending > 0 {
+		z.transport.SetReadDeadline(deadline)
+		resp, err := z.transport.Recv()
+		if err != nil {
+			break
+		}
+		deadline = time.Now().Add(wait)
 
-	if z.IsTCP() {
-		return z.recvTCP()
+		if len(resp) < 8 {
+			continue
+		}
+		if binary.LittleEndian.Uint16(resp[0:2]) == CMD_REG_EVENT {
+			z.divertEvent(resp)
+			continue
+		}
+		if z.sessionID != 0 && binary.LittleEndian.Uint16(resp[4:6]) != z.sessionID &&
+			!z.profileLocked().LenientSession {
+			continue
+		}
+		i, ok := index[binary.LittleEndian.Uint16(resp[6:8])]
+		if !ok || resps[i] != nil {
+			continue
+		}
+		resps[i] = resp
+		pending--
+	}
+
+	if pending > 0 {
+		z.drain()
 	}
-	return z.recvUDP()
+	return resps
 }
 
-// recvUDP receives a single UDP packet.
-func (z *ZKTeco) recvUDP() ([]byte, error) {
-	buf := make([]byte, 65536)
-	n, err := z.conn.Read(buf)
-	if err != nil {
-		return nil, err
+// trackDeviceState records whether the device is disabled, whichever path
+// sent the enable/disable command.
+func (z *ZKTeco) trackDeviceState(cmd uint16, resp []byte) {
+	if invalidatesCache[cmd] {
+		z.cache = nil
+	}
+	if cmd != CMD_DISABLE_DEVICE && cmd != CMD_ENABLE_DEVICE {
+		return
+	}
+	if pkt, err := parsePacket(resp); err == nil && pkt.Command == CMD_ACK_OK {
+		z.disabled = cmd == CMD_DISABLE_DEVICE
 	}
-	result := make([]byte, n)
-	copy(result, buf[:n])
-	return result, nil
 }
 
-// recvTCP receives a complete TCP-framed packet, handling buffering.
-func (z *ZKTeco) recvTCP() ([]byte, error) {
-	for {
-		if payload, remainder, ok := extractTCPPacket(z.tcpBuffer); ok {
-			z.tcpBuffer = remainder
-			return payload, nil
-		}
+// connected reports whether the client has a connection.
+func (z *ZKTeco) connected() bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.transport != nil
+}
 
-		buf := make([]byte, 16384)
-		n, err := z.conn.Read(buf)
-		if err != nil {
-			return nil, err
+// isDisabled reports whether the client left the connected device disabled.
+func (z *ZKTeco) isDisabled() bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.disabled && z.transport != nil
+}
+
+// reenableOnPanic re-enables a disabled device while a panic unwinds through
+// a helper flow, then continues panicking. It must be deferred directly.
+func (z *ZKTeco) reenableOnPanic() {
+	if r := recover(); r != nil {
+		if z.enableOnDisconnect && z.isDisabled() {
+			z.EnableDevice()
 		}
-		z.tcpBuffer = append(z.tcpBuffer, buf[:n]...)
+		panic(r)
 	}
 }
 
-// extractTCPPacket tries to extract a complete TCP-framed packet from buffer.
-func extractTCPPacket(buf []byte) ([]byte, []byte, bool) {
-	if len(buf) < 8 {
-		return nil, buf, false
-	}
+// newPacket builds the packet for cmd in the current session, with the
+// checksum of the profile, and returns it with the next reply ID.
+func (z *ZKTeco) newPacket(cmd uint16, data []byte) ([]byte, uint16) {
+	return protocol.CreateHeaderWith(z.profileLocked().checksum(), cmd, z.sessionID, z.replyID, data)
+}
 
-	if buf[0] != 0x50 || buf[1] != 0x50 || buf[2] != 0x82 || buf[3] != 0x7D {
-		return nil, buf, false
+// sendData sends one packet.
+func (z *ZKTeco) sendData(data []byte) error {
+	if z.transport == nil {
+		return fmt.Errorf("not connected")
 	}
+	z.lastSent = z.clock.Now()
+	return z.transport.Send(data)
+}
 
-	payloadLen := int(binary.LittleEndian.Uint32(buf[4:8]))
-	totalLen := 8 + payloadLen
+// recvData receives the next packet, waiting up to the client timeout or
+// until ctx is done.
+func (z *ZKTeco) recvData(ctx context.Context) ([]byte, error) {
+	if z.transport == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	if err := z.armRead(ctx, time.Time{}); err != nil {
+		return nil, err
+	}
+	return z.transport.Recv()
+}
 
-	if len(buf) < totalLen {
-		return nil, buf, false
+// recvReplyTo is like recvData but skips the stray packets of a UDP
+// connection, waiting for a packet of the exchange replyID.
+func (z *ZKTeco) recvReplyTo(ctx context.Context, replyID uint16) ([]byte, error) {
+	if z.transport == nil {
+		return nil, fmt.Errorf("not connected")
+	}
+	if err := z.armRead(ctx, time.Time{}); err != nil {
+		return nil, err
+	}
+	for {
+		resp, err := z.transport.Recv()
+		if err != nil || !z.strayPacket(replyID, resp) {
+			return resp, err
+		}
 	}
+}
 
-	payload := make([]byte, payloadLen)
-	copy(payload, buf[8:totalLen])
+// TransferError reports a large data transfer that failed part way, with
+// how many of the announced bytes had been received.
+type TransferError struct {
+	Received int
+	Total    int
+	Err      error
+}
 
-	var remainder []byte
-	if len(buf) > totalLen {
-		remainder = make([]byte, len(buf)-totalLen)
-		copy(remainder, buf[totalLen:])
-	}
+func (e *TransferError) Error() string {
+	return fmt.Sprintf("receive chunk: %d of %d bytes received: %v", e.Received, e.Total, e.Err)
+}
 
-	return payload, remainder, true
+func (e *TransferError) Unwrap() error {
+	return e.Err
 }
 
 // recvLargeData receives chunked large data after CMD_PREPARE_DATA.
-func (z *ZKTeco) recvLargeData(prepareResp []byte) ([]byte, error) {
+// If ctx is canceled mid-transfer, the transfer is aborted with CMD_FREE_DATA
+// and the session stays usable.
+func (z *ZKTeco) recvLargeData(ctx context.Context, prepareResp []byte) ([]byte, error) {
+	var allData []byte
+	err := z.recvLargeDataChunks(ctx, prepareResp, func(chunk []byte) error {
+		allData = append(allData, chunk...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allData, nil
+}
+
+// recvLargeDataChunks receives chunked large data after CMD_PREPARE_DATA and
+// hands each chunk to onChunk as it arrives. The first chunk keeps its 8-byte
+// packet header; later chunks are passed without it. The chunk is only valid
+// during the call. If ctx is canceled or onChunk returns an error, the
+// transfer is aborted with CMD_FREE_DATA and the session stays usable.
+func (z *ZKTeco) recvLargeDataChunks(ctx context.Context, prepareResp []byte, onChunk func([]byte) error) error {
 	if len(prepareResp) < 12 {
-		return nil, fmt.Errorf("PREPARE_DATA response too short: %d bytes", len(prepareResp))
+		return fmt.Errorf("PREPARE_DATA response too short: %d bytes", len(prepareResp))
 	}
 
 	totalSize := int(binary.LittleEndian.Uint32(prepareResp[8:12]))
 	if totalSize <= 0 {
-		return nil, nil
+		return nil
 	}
 
-	var allData []byte
+	// Unblock a pending read as soon as ctx is canceled; the interruption
+	// is stopped before aborting the transfer
+	stop := z.interruptOn(ctx)
+	defer stop()
+
 	received := 0
 	first := true
+	// Over UDP, events and stray replies arriving between the chunks are
+	// skipped; the final ACK is matched by the reply ID of the transfer.
+	replyID := binary.LittleEndian.Uint16(prepareResp[6:8])
 
 	for received < totalSize {
 		var chunk []byte
-		var err error
-
-		if z.IsTCP() {
-			chunk, err = z.readNextTCPPayload()
-		} else {
-			buf := make([]byte, 65536)
-			z.conn.SetReadDeadline(time.Now().Add(z.timeout))
-			n, readErr := z.conn.Read(buf)
-			if readErr != nil {
-				err = readErr
-			} else {
-				chunk = buf[:n]
-			}
+		err := z.armRead(ctx, time.Time{})
+		if err == nil {
+			chunk, err = z.transport.Recv()
+		}
+		if err == nil && !isDataChunk(chunk) && z.strayPacket(replyID, chunk) {
+			continue
 		}
 
 		if err != nil {
-			return nil, fmt.Errorf("receive chunk: %w", err)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				stop()
+				z.freeData()
+				err = ctxErr
+			}
+			return &TransferError{Received: received, Total: totalSize, Err: err}
 		}
 
 		if first {
-			allData = append(allData, chunk...)
 			if len(chunk) > 8 {
 				received += len(chunk) - 8
 			}
 			first = false
 		} else {
 			if len(chunk) > 8 {
-				allData = append(allData, chunk[8:]...)
-				received += len(chunk) - 8
-			} else {
-				allData = append(allData, chunk...)
-				received += len(chunk)
+				chunk = chunk[8:]
 			}
+			received += len(chunk)
+		}
+
+		if err := onChunk(chunk); err != nil {
+			stop()
+			z.freeData()
+			return err
 		}
 	}
 
 	// Consume final ACK
-	finalResp, err := z.recvData()
+	finalResp, err := z.recvReplyTo(ctx, replyID)
 	if err != nil {
-		return nil, fmt.Errorf("receive final ACK: %w", err)
+		return fmt.Errorf("receive final ACK: %w", err)
 	}
 	z.lastData = finalResp
 
-	return allData, nil
+	return nil
 }
 
-// readNextTCPPayload reads the next complete TCP-framed payload
-func (z *ZKTeco) readNextTCPPayload() ([]byte, error) {
+// drainTimeout is how long freeData waits for in-flight chunks to arrive
+// before discarding them.
+const drainTimeout = 500 * time.Millisecond
+
+// freeData aborts an in-flight data transfer: it discards pending chunks,
+// sends CMD_FREE_DATA and waits for the device to acknowledge it, whether
+// or not the context of the transfer is done. The caller holds z.mu.
+func (z *ZKTeco) freeData() error {
+	z.drain()
+
+	resp, err := z.commandLocked(context.Background(), CMD_FREE_DATA, nil, "data")
 	for attempts := 0; attempts < 50; attempts++ {
-		if payload, remainder, ok := extractTCPPacket(z.tcpBuffer); ok {
-			z.tcpBuffer = remainder
-			return payload, nil
+		if err != nil {
+			return fmt.Errorf("free data: %w", err)
+		}
+		if pkt, perr := parsePacket(resp); perr == nil && pkt.Command == CMD_ACK_OK {
+			z.lastData = resp
+			return nil
 		}
+		// Late data chunk still in the stream; skip it
+		resp, err = z.recvData(context.Background())
+	}
+	return fmt.Errorf("free data: no acknowledgement")
+}
 
-		buf := make([]byte, 16384)
-		z.conn.SetReadDeadline(time.Now().Add(z.timeout))
-		n, err := z.conn.Read(buf)
-		if err != nil {
-			return nil, err
+// drain discards in-flight packets until the connection has been quiet for
+// drainTimeout.
+func (z *ZKTeco) drain() {
+	for {
+		z.transport.SetReadDeadline(time.Now().Add(drainTimeout))
+		if _, err := z.transport.Recv(); err != nil {
+			return
 		}
-		z.tcpBuffer = append(z.tcpBuffer, buf[:n]...)
 	}
-	return nil, fmt.Errorf("readNextTCPPayload: exceeded max attempts")
 }
 
 // commandData sends a command expecting a large data response.
-func (z *ZKTeco) commandData(cmd uint16, data []byte) ([]byte, error) {
-	resp, err := z.command(cmd, data, "data")
+func (z *ZKTeco) commandData(ctx context.Context, cmd uint16, data []byte) ([]byte, error) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	ctx = z.callContext(ctx)
+	resp, err := z.commandLocked(ctx, cmd, data, "data")
 	if err != nil {
 		return nil, err
 	}
@@ -426,12 +896,97 @@ func (z *ZKTeco) commandData(cmd uint16, data []byte) ([]byte, error) {
 	}
 
 	if pkt.Command == CMD_PREPARE_DATA {
-		return z.recvLargeData(resp)
+		return z.recvLargeData(ctx, resp)
 	}
 
 	if pkt.Command == CMD_ACK_DATA || pkt.Command == CMD_ACK_OK {
 		return resp, nil
 	}
 
-	return nil, fmt.Errorf("unexpected response command: %d", pkt.Command)
+	return nil, fmt.Errorf("unexpected response command: %s", commandString(pkt.Command))
+}
+
+// ackCommand sends a command and checks that the device replied CMD_ACK_OK.
+// name prefixes the returned errors.
+func (z *ZKTeco) ackCommand(name string, cmd uint16, data []byte) error {
+	resp, err := z.command(cmd, data, "general")
+	return checkAck(name, resp, err)
+}
+
+// ackCommandLocked is ackCommand for callers holding z.mu.
+func (z *ZKTeco) ackCommandLocked(name string, cmd uint16, data []byte) error {
+	resp, err := z.commandLocked(z.callContext(context.Background()), cmd, data, "general")
+	return checkAck(name, resp, err)
+}
+
+// checkAck checks that the reply resp of command name is CMD_ACK_OK.
+func checkAck(name string, resp []byte, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	pkt, err := parsePacket(resp)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if pkt.Command != CMD_ACK_OK {
+		return fmt.Errorf("%s: error response %s", name, commandString(pkt.Command))
+	}
+	return nil
+}
+
+// maxUploadChunk is the largest CMD_DATA payload sent in one packet.
+const maxUploadChunk = 1024
+
+// sendLargeData uploads data to the device buffer with CMD_PREPARE_DATA and
+// CMD_DATA, ready to be committed by a following write command. The caller
+// holds z.mu until the commit, so no other transfer reuses the buffer.
+func (z *ZKTeco) sendLargeData(data []byte) error {
+	if err := z.ackCommandLocked("free data", CMD_FREE_DATA, nil); err != nil {
+		return err
+	}
+
+	size := make([]byte, 4)
+	binary.LittleEndian.PutUint32(size, uint32(len(data)))
+	if err := z.ackCommandLocked("prepare data", CMD_PREPARE_DATA, size); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(data); start += maxUploadChunk {
+		end := start + maxUploadChunk
+		if end > len(data) {
+			end = len(data)
+		}
+		if err := z.ackCommandLocked("send data", CMD_DATA, data[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commandDataChunks is like commandData but streams the response to onChunk
+// instead of buffering it. Small responses that arrive in a single packet are
+// passed whole.
+func (z *ZKTeco) commandDataChunks(ctx context.Context, cmd uint16, data []byte, onChunk func([]byte) error) error {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	ctx = z.callContext(ctx)
+	resp, err := z.commandLocked(ctx, cmd, data, "data")
+	if err != nil {
+		return err
+	}
+
+	pkt, err := parsePacket(resp)
+	if err != nil {
+		return err
+	}
+
+	if pkt.Command == CMD_PREPARE_DATA {
+		return z.recvLargeDataChunks(ctx, resp, onChunk)
+	}
+
+	if pkt.Command == CMD_ACK_DATA || pkt.Command == CMD_ACK_OK {
+		return onChunk(resp)
+	}
+
+	return fmt.Errorf("unexpected response command: %s", commandString(pkt.Command))
 }